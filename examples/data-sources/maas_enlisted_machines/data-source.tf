@@ -0,0 +1 @@
+data "maas_enlisted_machines" "new" {}
@@ -0,0 +1,4 @@
+data "maas_ip_addresses" "reserved" {
+  subnet = "10.88.88.0/24"
+  types  = ["User reserved", "Sticky"]
+}
@@ -0,0 +1,14 @@
+# Audit what was done to a machine over the last week.
+data "maas_node_events" "audit" {
+  hostname      = "machine-01"
+  level         = "AUDIT"
+  created_after = timeadd(plantimestamp(), "-168h")
+  limit         = 500
+}
+
+output "machine_audit_log" {
+  value = [
+    for event in data.maas_node_events.audit.events :
+    "${event.created} ${event.username}: ${event.description}"
+  ]
+}
@@ -0,0 +1,17 @@
+# Read the hardware details gathered during the last commissioning of a machine.
+data "maas_node_script_results" "hardware" {
+  machine      = "machine-01"
+  result_type  = "commissioning"
+  script_names = ["50-maas-01-commissioning"]
+}
+
+locals {
+  hardware = jsondecode(data.maas_node_script_results.hardware.results[0].stdout)
+}
+
+# Check the last testing run, truncating the outputs to 4KiB.
+data "maas_node_script_results" "testing" {
+  machine         = "machine-01"
+  result_type     = "testing"
+  max_output_size = 4096
+}
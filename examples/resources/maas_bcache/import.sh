@@ -0,0 +1,2 @@
+# BCaches can be imported with the machine identifier (system ID, hostname, or FQDN) and the bcache identifier (ID or name). e.g.
+$ terraform import maas_bcache.osd machine-06:bcache0
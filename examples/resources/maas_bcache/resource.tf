@@ -0,0 +1,7 @@
+resource "maas_bcache" "osd" {
+  machine        = maas_machine.machine.id
+  name           = "bcache0"
+  backing_device = maas_block_device.sdb.id
+  cache_set      = maas_bcache_cache_set.nvme.id
+  cache_mode     = "writeback"
+}
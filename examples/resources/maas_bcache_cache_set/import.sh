@@ -0,0 +1,2 @@
+# BCache cache sets can be imported with the machine identifier (system ID, hostname, or FQDN) and the cache set ID. e.g.
+$ terraform import maas_bcache_cache_set.nvme machine-06:3
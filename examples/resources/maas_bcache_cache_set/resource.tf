@@ -0,0 +1,4 @@
+resource "maas_bcache_cache_set" "nvme" {
+  machine      = maas_machine.machine.id
+  cache_device = maas_block_device.nvme0n1.id
+}
@@ -20,3 +20,13 @@ resource "maas_block_device" "vdb" {
     mount_point    = "/storage"
   }
 }
+
+resource "maas_block_device" "vdc" {
+  machine        = maas_machine.virsh_vm2.id
+  name           = "vdc"
+  id_path        = "/dev/vdc"
+  size_gigabytes = 100
+  fs_type        = "xfs"
+  mount_point    = "/scratch"
+  mount_options  = "noatime"
+}
@@ -0,0 +1,19 @@
+# Add the virsh VMs of a KVM host, and commission them.
+resource "maas_chassis" "kvm" {
+  chassis_type  = "virsh"
+  hostname      = "qemu+ssh://ubuntu@10.10.0.5/system"
+  prefix_filter = "compute-"
+  accept_all    = true
+  zone          = maas_zone.rack1.name
+  pool          = maas_resource_pool.compute.name
+}
+
+# Add the VMs of a VMware vCenter, and delete them from MAAS when the resource is destroyed.
+resource "maas_chassis" "vcenter" {
+  chassis_type               = "vmware"
+  hostname                   = "vcenter.example.com"
+  username                   = "administrator@vsphere.local"
+  password                   = var.vcenter_password
+  protocol                   = "https"
+  delete_machines_on_destroy = true
+}
@@ -0,0 +1,2 @@
+# IP addresses can be imported with the reserved IP address. e.g.
+$ terraform import maas_ip_address.vip 10.88.88.10
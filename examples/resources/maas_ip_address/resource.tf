@@ -0,0 +1,10 @@
+resource "maas_ip_address" "vip" {
+  subnet     = maas_subnet.tf_subnet.cidr
+  ip_address = "10.88.88.10"
+  hostname   = "kube-apiserver"
+}
+
+resource "maas_ip_address" "load_balancer" {
+  subnet      = maas_subnet.tf_subnet.id
+  mac_address = "52:54:00:a1:b2:c3"
+}
@@ -92,3 +92,20 @@ resource "maas_machine" "ipmi_machine" {
   })
   hostname = "ipmiTestMachineNoPxe"
 }
+
+# Release the machine and erase its disks before deleting it, in case it was deployed.
+resource "maas_machine" "erased_on_destroy" {
+  power_type = "ipmi"
+  power_parameters = jsonencode({
+    power_address = "10.10.10.27"
+    power_user    = "admin"
+    power_pass    = "password"
+  })
+  hostname   = "ipmiTestMachineErased"
+  on_destroy = "release_then_delete"
+
+  release_params {
+    erase        = true
+    secure_erase = true
+  }
+}
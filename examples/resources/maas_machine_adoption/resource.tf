@@ -0,0 +1,22 @@
+data "maas_enlisted_machines" "new" {}
+
+# Adopt every machine that enlisted itself with a Redfish BMC.
+resource "maas_machine_adoption" "redfish" {
+  for_each = {
+    for machine in data.maas_enlisted_machines.new.machines : machine.pxe_mac_address => machine
+    if machine.power_type == "redfish"
+  }
+
+  pxe_mac_address = each.key
+  hostname        = "compute-${replace(each.key, ":", "")}"
+  zone            = "rack1"
+  pool            = "compute"
+  power_type      = "redfish"
+  power_parameters = jsonencode({
+    power_address = each.value.bmc_address
+    power_user    = "admin"
+    power_pass    = var.redfish_password
+  })
+  testing_scripts = ["none"]
+  keep_on_destroy = true
+}
@@ -0,0 +1,26 @@
+# Enlist and commission the machines of a rack from a CSV inventory file.
+#
+# hostname,pxe_mac_address,power_type,power_address,power_user,power_pass,zone,pool,tags
+# rack1-node1,00:11:22:33:44:01,redfish,10.10.10.1,admin,password,rack1,compute,rack1;gpu
+# rack1-node2,00:11:22:33:44:02,redfish,10.10.10.2,admin,password,rack1,compute,rack1
+resource "maas_machine_inventory" "rack1" {
+  format      = "csv"
+  file        = "${path.module}/rack1.csv"
+  parallelism = 8
+}
+
+# Enlist IPMI machines from an inline YAML inventory.
+resource "maas_machine_inventory" "rack2" {
+  format = "yaml"
+  content = yamlencode([
+    for i in range(1, 4) : {
+      hostname      = "rack2-node${i}"
+      power_type    = "ipmi"
+      power_address = "10.10.20.${i}"
+      power_user    = "admin"
+      power_pass    = var.ipmi_password
+      tags          = ["rack2"]
+    }
+  ])
+  testing_scripts = ["none"]
+}
@@ -0,0 +1,56 @@
+resource "maas_machine_network" "network" {
+  machine = maas_machine.machine.id
+
+  physical {
+    name        = "eno1"
+    mac_address = "52:54:00:a1:b2:01"
+  }
+
+  physical {
+    name        = "eno2"
+    mac_address = "52:54:00:a1:b2:02"
+  }
+
+  physical {
+    name        = "eno3"
+    mac_address = "52:54:00:a1:b2:03"
+
+    link {
+      subnet = "10.10.0.0/24"
+      mode   = "DHCP"
+    }
+  }
+
+  bond {
+    name      = "bond0"
+    parents   = ["eno1", "eno2"]
+    bond_mode = "802.3ad"
+    mtu       = 9000
+  }
+
+  vlan {
+    parent = "bond0"
+    vid    = 100
+  }
+
+  bridge {
+    name   = "br0"
+    parent = "bond0"
+
+    link {
+      subnet          = "10.20.0.0/24"
+      mode            = "STATIC"
+      ip_address      = "10.20.0.10"
+      default_gateway = true
+    }
+  }
+
+  bridge {
+    name   = "br100"
+    parent = "bond0.100"
+
+    link {
+      subnet = "10.100.0.0/24"
+    }
+  }
+}
@@ -0,0 +1,66 @@
+resource "maas_machine_storage" "storage" {
+  machine = maas_machine.machine.id
+
+  disk {
+    name   = "os"
+    serial = "S4EVNF0M123456"
+
+    partition {
+      size_gigabytes = 1
+      bootable       = true
+      fs_type        = "fat32"
+      mount_point    = "/boot/efi"
+    }
+
+    partition {
+      size_gigabytes = 100
+      fs_type        = "ext4"
+      mount_point    = "/"
+    }
+  }
+
+  disk {
+    name = "data1"
+    wwn  = "0x5000c500a1b2c3d4"
+  }
+
+  disk {
+    name = "data2"
+    wwn  = "0x5000c500a1b2c3d5"
+  }
+
+  disk {
+    name               = "cache"
+    tags               = ["ssd"]
+    max_size_gigabytes = 500
+  }
+
+  raid {
+    name    = "md0"
+    level   = "1"
+    devices = ["data1", "data2"]
+  }
+
+  bcache_cache_set {
+    name   = "nvme"
+    device = "cache"
+  }
+
+  bcache {
+    name           = "bcache0"
+    backing_device = "md0"
+    cache_set      = "nvme"
+  }
+
+  volume_group {
+    name    = "data"
+    devices = ["bcache0"]
+
+    logical_volume {
+      name           = "srv"
+      size_gigabytes = 500
+      fs_type        = "xfs"
+      mount_point    = "/srv"
+    }
+  }
+}
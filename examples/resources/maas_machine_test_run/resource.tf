@@ -0,0 +1,25 @@
+# Burn in the machines of a rack before they are made available for deployment.
+resource "maas_machine_test_run" "burn_in" {
+  machines = [
+    maas_machine.node1.id,
+    maas_machine.node2.id,
+  ]
+  testing_scripts = [
+    "smartctl-validate",
+    "memtester",
+    "stress-ng-cpu-long",
+  ]
+  script_parameters = {
+    "stress-ng-cpu-long_runtime" = "3600"
+  }
+  mark_broken_on_failure = true
+
+  # Run the tests again whenever the firmware version changes.
+  triggers = {
+    firmware = var.firmware_version
+  }
+
+  timeouts {
+    create = "2h"
+  }
+}
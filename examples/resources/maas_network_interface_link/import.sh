@@ -0,0 +1,2 @@
+# Network interface links can be imported with the machine identifier (system ID, hostname, or FQDN), the network interface identifier (MAC address, name, or ID) and the link ID. e.g.
+$ terraform import maas_network_interface_link.virsh_vm1_nic1 machine-06:eth0:42
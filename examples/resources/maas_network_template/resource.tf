@@ -0,0 +1,51 @@
+resource "maas_network_template" "rack" {
+  name = "rack"
+
+  role {
+    name       = "uplink"
+    vendor     = "Mellanox Technologies"
+    link_speed = 25000
+    count      = 2
+  }
+
+  role {
+    name         = "management"
+    name_pattern = "^eno1$"
+
+    link {
+      subnet = "10.10.0.0/24"
+      mode   = "DHCP"
+    }
+  }
+
+  bond {
+    name      = "bond0"
+    parents   = ["uplink"]
+    bond_mode = "802.3ad"
+    mtu       = 9000
+  }
+
+  vlan {
+    parent = "bond0"
+    vid    = 100
+  }
+
+  vlan {
+    parent = "bond0"
+    vid    = 200
+
+    link {
+      subnet = "10.200.0.0/24"
+    }
+  }
+
+  bridge {
+    name   = "br100"
+    parent = "bond0.100"
+
+    link {
+      subnet          = "10.100.0.0/24"
+      default_gateway = true
+    }
+  }
+}
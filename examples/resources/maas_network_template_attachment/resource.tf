@@ -0,0 +1,4 @@
+resource "maas_network_template_attachment" "rack" {
+  template = maas_network_template.rack.content
+  machines = [for machine in maas_machine.rack : machine.id]
+}
@@ -0,0 +1,2 @@
+# Partitions can be imported with the machine identifier (system ID, hostname, or FQDN), the block device identifier (ID or name) and the partition identifier (ID or name). e.g.
+$ terraform import maas_partition.srv machine-06:sdb:sdb-part1
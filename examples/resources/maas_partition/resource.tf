@@ -0,0 +1,10 @@
+resource "maas_partition" "srv" {
+  machine        = maas_machine.machine.id
+  block_device   = "sdb"
+  size_gigabytes = 100
+  fs_type        = "ext4"
+  label          = "srv"
+  mount_point    = "/srv"
+  mount_options  = "noatime"
+  tags           = ["data"]
+}
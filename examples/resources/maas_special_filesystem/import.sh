@@ -0,0 +1,2 @@
+# Special filesystems can be imported with the machine identifier (system ID, hostname, or FQDN) and the mount point. e.g.
+$ terraform import maas_special_filesystem.scratch machine-06:/scratch
@@ -0,0 +1,6 @@
+resource "maas_special_filesystem" "scratch" {
+  machine       = maas_machine.machine.id
+  fs_type       = "tmpfs"
+  mount_point   = "/scratch"
+  mount_options = "size=8G"
+}
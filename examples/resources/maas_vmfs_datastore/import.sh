@@ -0,0 +1,2 @@
+# VMFS datastores can be imported with the machine identifier (system ID, hostname, or FQDN) and the datastore identifier (ID or name). e.g.
+$ terraform import maas_vmfs_datastore.datastore1 machine-06/datastore1
@@ -0,0 +1,8 @@
+resource "maas_vmfs_datastore" "datastore1" {
+  machine = maas_machine.esxi.id
+  name    = "datastore1"
+  block_devices = [
+    maas_block_device.sdb.id,
+    maas_block_device.sdc.id,
+  ]
+}
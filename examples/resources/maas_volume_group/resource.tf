@@ -28,3 +28,17 @@ resource "maas_volume_group" "vg1" {
   block_devices = [maas_block_device.vdb1.id]
   partitions    = [maas_block_device.vdb2.partitions.0.id]
 }
+
+resource "maas_volume_group" "vg2" {
+  name    = "volume group 2"
+  machine = maas_machine.virsh_vm2.id
+
+  block_device_selector {
+    serial = "S4EVNF0M123456"
+  }
+
+  partition_selector {
+    model     = "Samsung SSD 870"
+    partition = 1
+  }
+}
@@ -0,0 +1,106 @@
+package maas
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/canonical/gomaasclient/client"
+)
+
+// getAPIClient returns the API client backing the gomaasclient endpoints.
+// It is only used for MAAS operations that are not exposed by gomaasclient yet.
+func getAPIClient(c *client.Client) (*client.APIClient, error) {
+	machine, ok := c.Machine.(*client.Machine)
+	if !ok {
+		return nil, fmt.Errorf("unable to get the MAAS API client")
+	}
+
+	return &machine.APIClient, nil
+}
+
+// machineOperation calls the given POST operation on a machine, and decodes the
+// response into result unless it is nil.
+func machineOperation(c *client.Client, systemID string, op string, params url.Values, result any) error {
+	apiClient, err := getAPIClient(c)
+	if err != nil {
+		return err
+	}
+
+	return apiClient.GetSubObject("machines").GetSubObject(systemID).Post(op, params, func(data []byte) error {
+		if result == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+// machinesOperation calls the given POST operation on the machines collection, and
+// decodes the response into result unless it is nil.
+func machinesOperation(c *client.Client, op string, params url.Values, result any) error {
+	apiClient, err := getAPIClient(c)
+	if err != nil {
+		return err
+	}
+
+	return apiClient.GetSubObject("machines").Post(op, params, func(data []byte) error {
+		if result == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+// ipAddressesOperation calls the given POST operation on the IP addresses, and
+// decodes the response into result unless it is nil.
+func ipAddressesOperation(c *client.Client, op string, params url.Values, result any) error {
+	apiClient, err := getAPIClient(c)
+	if err != nil {
+		return err
+	}
+
+	return apiClient.GetSubObject("ipaddresses").Post(op, params, func(data []byte) error {
+		if result == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+// nodeResults calls GET on the script results of a node, and decodes the response
+// into result. The resultSet selects a single result set (e.g. "current-testing"),
+// all the result sets are returned if it's empty.
+func nodeResults(c *client.Client, systemID string, resultSet string, params url.Values, result any) error {
+	apiClient, err := getAPIClient(c)
+	if err != nil {
+		return err
+	}
+
+	results := apiClient.GetSubObject("nodes").GetSubObject(systemID).GetSubObject("results")
+	if resultSet != "" {
+		results = results.GetSubObject(resultSet)
+	}
+
+	return results.Get("", params, func(data []byte) error {
+		return json.Unmarshal(data, result)
+	})
+}
+
+// nodeObject returns the API client of an object of a node, e.g. the path
+// "vmfs-datastore", "3" for nodes/{system_id}/vmfs-datastore/3/.
+func nodeObject(c *client.Client, systemID string, path ...string) (*client.APIClient, error) {
+	apiClient, err := getAPIClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	object := apiClient.GetSubObject("nodes").GetSubObject(systemID)
+	for _, name := range path {
+		object = object.GetSubObject(name)
+	}
+
+	return &object, nil
+}
@@ -0,0 +1,127 @@
+package maas
+
+import (
+	"context"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/canonical/gomaasclient/entity/node"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+)
+
+func dataSourceMAASEnlistedMachines() *schema.Resource {
+	return &schema.Resource{
+		Description: "Lists the MAAS machines that enlisted themselves and are in the `New` status, so that they can be adopted with the `maas_machine_adoption` resource.",
+		ReadContext: dataSourceEnlistedMachinesRead,
+
+		Schema: map[string]*schema.Schema{
+			"machines": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "A list of the enlisted machines.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"architecture": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The architecture type of the machine.",
+						},
+						"bmc_address": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The BMC address discovered during the enlistment, if any.",
+						},
+						"hostname": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The machine hostname.",
+						},
+						"mac_addresses": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The MAC addresses of the machine network interfaces.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"power_parameters": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Sensitive:   true,
+							Description: "Serialized JSON string containing the power parameters discovered during the enlistment.",
+						},
+						"power_type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The power management type discovered during the enlistment, if any.",
+						},
+						"pxe_mac_address": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The MAC address of the machine's PXE boot NIC.",
+						},
+						"system_id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The system ID of the machine.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceEnlistedMachinesRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machines, err := client.Machines.Get(&entity.MachinesParams{Status: []string{"new"}})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	items := []map[string]any{}
+
+	for _, machine := range machines {
+		if machine.Status != node.StatusNew {
+			continue
+		}
+
+		powerParams, err := client.Machine.GetPowerParameters(machine.SystemID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		powerParamsJSON, err := structure.FlattenJsonToString(powerParams)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		bmcAddress, _ := powerParams["power_address"].(string)
+
+		macAddresses := make([]string, len(machine.InterfaceSet))
+		for i, networkInterface := range machine.InterfaceSet {
+			macAddresses[i] = networkInterface.MACAddress
+		}
+
+		items = append(items, map[string]any{
+			"architecture":     machine.Architecture,
+			"bmc_address":      bmcAddress,
+			"hostname":         machine.Hostname,
+			"mac_addresses":    macAddresses,
+			"power_parameters": powerParamsJSON,
+			"power_type":       machine.PowerType,
+			"pxe_mac_address":  machine.BootInterface.MACAddress,
+			"system_id":        machine.SystemID,
+		})
+	}
+
+	if err := d.Set("machines", items); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("maas_enlisted_machines")
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package maas
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceMAASIPAddresses() *schema.Resource {
+	return &schema.Resource{
+		Description: "Lists the IP addresses known to MAAS in a subnet: the reserved, sticky, automatically assigned and discovered addresses. It requires an admin user, since the addresses of all the users are listed.",
+		ReadContext: dataSourceIPAddressesRead,
+
+		Schema: map[string]*schema.Schema{
+			"ip_addresses": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The IP addresses of the subnet, in ascending order.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"created": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The creation time of the IP address.",
+						},
+						"ip_address": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The IP address.",
+						},
+						"mac_addresses": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The MAC addresses of the interfaces the IP address is assigned to.",
+						},
+						"owner": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The username of the owner of the IP address, if any.",
+						},
+						"type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The allocation type of the IP address, e.g. `User reserved`, `Sticky`, `Auto`, `DHCP` or `Discovered`.",
+						},
+					},
+				},
+			},
+			"subnet": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The identifier (CIDR or ID) of the subnet.",
+			},
+			"types": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Only return the IP addresses of these allocation types, e.g. `User reserved`. All the types are returned if it's not set.",
+			},
+		},
+	}
+}
+
+func dataSourceIPAddressesRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	subnet, err := getSubnet(client, d.Get("subnet").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The discovered IP addresses are only listed on request. Listing the IP
+	// addresses of all the users requires an admin user.
+	var ipAddresses []entity.IPAddress
+
+	for _, params := range []*entity.IPAddressesParams{{All: true}, {All: true, Discovered: true}} {
+		found, err := client.IPAddresses.Get(params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		for _, ipAddress := range found {
+			if !slices.ContainsFunc(ipAddresses, func(v entity.IPAddress) bool { return v.IP.Equal(ipAddress.IP) }) {
+				ipAddresses = append(ipAddresses, ipAddress)
+			}
+		}
+	}
+
+	types := convertToStringSlice(d.Get("types").(*schema.Set).List())
+
+	ipAddresses = slices.DeleteFunc(ipAddresses, func(ipAddress entity.IPAddress) bool {
+		return ipAddress.Subnet.ID != subnet.ID || (len(types) > 0 && !slices.Contains(types, ipAddress.AllocTypeName))
+	})
+	slices.SortFunc(ipAddresses, func(a, b entity.IPAddress) int {
+		return bytes.Compare(a.IP.To16(), b.IP.To16())
+	})
+
+	items := make([]map[string]any, len(ipAddresses))
+	for i, ipAddress := range ipAddresses {
+		macAddresses := []string{}
+		for _, n := range ipAddress.InterfaceSet {
+			macAddresses = append(macAddresses, n.MACAddress)
+		}
+
+		items[i] = map[string]any{
+			"created":       ipAddress.Created,
+			"ip_address":    ipAddress.IP.String(),
+			"mac_addresses": macAddresses,
+			"owner":         ipAddress.Owner.UserName,
+			"type":          ipAddress.AllocTypeName,
+		}
+	}
+
+	if err := d.Set("ip_addresses", items); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(strconv.Itoa(subnet.ID))
+
+	return nil
+}
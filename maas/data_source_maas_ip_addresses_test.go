@@ -0,0 +1,45 @@
+package maas_test
+
+import (
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMAASIPAddresses_basic(t *testing.T) {
+	cidr := testutils.GenerateRandomCIDR()
+	ipAddress := testutils.GetNetworkPrefixFromCIDR(cidr) + ".10"
+	macAddress := testutils.RandomMAC()
+	hostname := acctest.RandomWithPrefix("tf-vip")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASIPAddressDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASIPAddress(cidr, ipAddress, macAddress, hostname) + `
+data "maas_ip_addresses" "test" {
+  subnet = maas_subnet.test.id
+  types  = ["User reserved"]
+
+  depends_on = [maas_ip_address.static, maas_ip_address.next]
+}
+`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.maas_ip_addresses.test", "ip_addresses.#", "2"),
+					resource.TestCheckResourceAttrPair("data.maas_ip_addresses.test", "id", "maas_subnet.test", "id"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.maas_ip_addresses.test", "ip_addresses.*", map[string]string{
+						"ip_address":      ipAddress,
+						"mac_addresses.0": macAddress,
+						"type":            "User reserved",
+					}),
+					resource.TestCheckTypeSetElemAttrPair("data.maas_ip_addresses.test", "ip_addresses.*.ip_address", "maas_ip_address.next", "ip_address"),
+				),
+			},
+		},
+	})
+}
@@ -2,7 +2,10 @@ package maas
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
@@ -27,7 +30,7 @@ func dataSourceMAASMachine() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ExactlyOneOf: []string{"hostname", "pxe_mac_address"},
+				ExactlyOneOf: []string{"hostname", "owner_data", "pxe_mac_address"},
 				Description:  "The machine hostname.",
 			},
 			"min_hwe_kernel": {
@@ -35,6 +38,16 @@ func dataSourceMAASMachine() *schema.Resource {
 				Computed:    true,
 				Description: "The minimum kernel version allowed to run on this machine.",
 			},
+			"owner_data": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"hostname", "owner_data", "pxe_mac_address"},
+				Description:  "The machine owner data. If set, the machine is found by its owner data, which must contain all of the given key/value pairs and match exactly one machine.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"pool": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -55,7 +68,7 @@ func dataSourceMAASMachine() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ExactlyOneOf: []string{"hostname", "pxe_mac_address"},
+				ExactlyOneOf: []string{"hostname", "owner_data", "pxe_mac_address"},
 				Description:  "The MAC address of the machine's PXE boot NIC.",
 			},
 			"status": {
@@ -75,15 +88,19 @@ func dataSourceMAASMachine() *schema.Resource {
 func dataSourceMachineRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
 	client := meta.(*ClientConfig).Client
 
-	var identifier string
+	var (
+		machine *entity.Machine
+		err     error
+	)
 
 	if v, ok := d.GetOk("hostname"); ok {
-		identifier = v.(string)
+		machine, err = getMachine(client, v.(string))
 	} else if v, ok := d.GetOk("pxe_mac_address"); ok {
-		identifier = v.(string)
+		machine, err = getMachine(client, v.(string))
+	} else {
+		machine, err = getMachineByOwnerData(client, d.Get("owner_data").(map[string]any))
 	}
 
-	machine, err := getMachine(client, identifier)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -110,6 +127,7 @@ func dataSourceMachineRead(ctx context.Context, d *schema.ResourceData, meta any
 		"power_parameters": powerParamsJSON,
 		"pxe_mac_address":  machine.BootInterface.MACAddress,
 		"status":           machine.StatusName,
+		"owner_data":       getMachineOwnerData(machine),
 	}
 	if err := setTerraformState(d, tfState); err != nil {
 		return diag.FromErr(err)
@@ -117,3 +135,24 @@ func dataSourceMachineRead(ctx context.Context, d *schema.ResourceData, meta any
 
 	return nil
 }
+
+func getMachineByOwnerData(client *client.Client, ownerData map[string]any) (*entity.Machine, error) {
+	machines, err := client.Machines.Get(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []entity.Machine
+
+	for _, machine := range machines {
+		if machineOwnerDataMatches(&machine, ownerData) {
+			found = append(found, machine)
+		}
+	}
+
+	if len(found) != 1 {
+		return nil, fmt.Errorf("owner data %v matched %d machines, expected exactly one", ownerData, len(found))
+	}
+
+	return &found[0], nil
+}
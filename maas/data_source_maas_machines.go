@@ -24,6 +24,14 @@ func dataSourceMAASMachines() *schema.Resource {
 							Computed:    true,
 							Description: "The machine hostname.",
 						},
+						"owner_data": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Description: "The machine owner data.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
 						"system_id": {
 							Type:        schema.TypeString,
 							Computed:    true,
@@ -32,6 +40,14 @@ func dataSourceMAASMachines() *schema.Resource {
 					},
 				},
 			},
+			"owner_data": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Only list the machines with owner data containing all of the given key/value pairs.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 		},
 	}
 }
@@ -44,11 +60,18 @@ func dataSourceMachinesRead(ctx context.Context, d *schema.ResourceData, meta in
 		return diag.FromErr(err)
 	}
 
+	ownerData := d.Get("owner_data").(map[string]interface{})
+
 	items := []map[string]interface{}{}
 	for _, machine := range machines {
+		if !machineOwnerDataMatches(&machine, ownerData) {
+			continue
+		}
+
 		items = append(items, map[string]interface{}{
-			"system_id": machine.SystemID,
-			"hostname":  machine.Hostname,
+			"system_id":  machine.SystemID,
+			"hostname":   machine.Hostname,
+			"owner_data": getMachineOwnerData(&machine),
 		})
 	}
 
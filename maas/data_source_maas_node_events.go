@@ -0,0 +1,213 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	eventlevel "github.com/canonical/gomaasclient/entity/event"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// eventCreatedLayout is the layout of the creation time of the MAAS events.
+const eventCreatedLayout = "Mon, 02 Jan. 2006 15:04:05"
+
+// eventsPageSize is the maximum number of events returned by MAAS in a single query.
+const eventsPageSize = 1000
+
+func dataSourceMAASNodeEvents() *schema.Resource {
+	return &schema.Resource{
+		Description: "Provides the MAAS event log of nodes, newest first.",
+		ReadContext: dataSourceNodeEventsRead,
+
+		Schema: map[string]*schema.Schema{
+			"created_after": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+				Description:  "Only return the events created after this time, in RFC 3339 format.",
+			},
+			"created_before": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+				Description:  "Only return the events created before this time, in RFC 3339 format.",
+			},
+			"events": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The events, newest first.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"created": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The creation time of the event, in RFC 3339 format.",
+						},
+						"description": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The description of the event.",
+						},
+						"hostname": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The hostname of the node.",
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The event ID.",
+						},
+						"level": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The level of the event.",
+						},
+						"system_id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The system ID of the node.",
+						},
+						"type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The type of the event.",
+						},
+						"username": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The user who triggered the event, if any.",
+						},
+					},
+				},
+			},
+			"hostname": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the events of the node with this hostname.",
+			},
+			"level": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"AUDIT", "DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL"}, false)),
+				Description:      "Only return the events of this level or higher. Valid options are: `AUDIT`, `DEBUG`, `INFO`, `WARNING`, `ERROR` and `CRITICAL`. `AUDIT` only returns the audit events. MAAS defaults to `INFO`.",
+			},
+			"limit": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      100,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "The maximum number of events to return. Defaults to `100`.",
+			},
+			"mac_address": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsMACAddress,
+				Description:  "Only return the events of the node with this MAC address.",
+			},
+			"system_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the events of the node with this system ID.",
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the events of the nodes in this zone.",
+			},
+		},
+	}
+}
+
+func dataSourceNodeEventsRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	params := entity.EventParams{
+		Hostname:   d.Get("hostname").(string),
+		ID:         d.Get("system_id").(string),
+		Level:      eventlevel.LogLevel(d.Get("level").(string)),
+		MACAddress: d.Get("mac_address").(string),
+		Zone:       d.Get("zone").(string),
+	}
+
+	var createdAfter, createdBefore time.Time
+
+	if v, ok := d.GetOk("created_after"); ok {
+		createdAfter, _ = time.Parse(time.RFC3339, v.(string))
+	}
+
+	if v, ok := d.GetOk("created_before"); ok {
+		createdBefore, _ = time.Parse(time.RFC3339, v.(string))
+	}
+
+	events, err := getNodeEvents(client, params, createdAfter, createdBefore, d.Get("limit").(int))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("events", events); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("maas_node_events")
+
+	return nil
+}
+
+// getNodeEvents pages through the MAAS events matching the params, newest first,
+// until limit events created in the given time window are found. A zero time
+// leaves the window open on that side.
+func getNodeEvents(client *client.Client, params entity.EventParams, createdAfter time.Time, createdBefore time.Time, limit int) ([]map[string]any, error) {
+	result := []map[string]any{}
+
+	for {
+		params.Limit = fmt.Sprintf("%d", eventsPageSize)
+
+		events, err := client.Events.Get(&params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events.Events {
+			created, err := time.Parse(eventCreatedLayout, event.Created)
+			if err != nil {
+				return nil, fmt.Errorf("event (%d): %w", event.ID, err)
+			}
+
+			if !createdAfter.IsZero() && !created.After(createdAfter) {
+				// The events are returned newest first, the remaining ones are older
+				return result, nil
+			}
+
+			if !createdBefore.IsZero() && !created.Before(createdBefore) {
+				continue
+			}
+
+			result = append(result, map[string]any{
+				"created":     created.Format(time.RFC3339),
+				"description": event.Description,
+				"hostname":    event.Hostname,
+				"id":          event.ID,
+				"level":       string(event.Level),
+				"system_id":   event.Node,
+				"type":        event.Type,
+				"username":    event.UserName,
+			})
+
+			if len(result) == limit {
+				return result, nil
+			}
+		}
+
+		if len(events.Events) < eventsPageSize {
+			return result, nil
+		}
+
+		params.Before = fmt.Sprintf("%d", events.Events[len(events.Events)-1].ID)
+	}
+}
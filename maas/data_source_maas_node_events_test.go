@@ -0,0 +1,41 @@
+package maas_test
+
+import (
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMAASNodeEvents_basic(t *testing.T) {
+	checks := []resource.TestCheckFunc{
+		resource.TestCheckResourceAttr("data.maas_node_events.test", "events.#", "5"),
+		resource.TestCheckResourceAttrSet("data.maas_node_events.test", "events.0.id"),
+		resource.TestCheckResourceAttrSet("data.maas_node_events.test", "events.0.type"),
+		resource.TestCheckResourceAttrSet("data.maas_node_events.test", "events.0.created"),
+		resource.TestCheckResourceAttrSet("data.maas_node_events.test", "events.0.system_id"),
+	}
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:   func() { testutils.PreCheck(t, nil) },
+		Providers:  testutils.TestAccProviders,
+		ErrorCheck: func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMAASNodeEvents(),
+				Check:  resource.ComposeTestCheckFunc(checks...),
+			},
+		},
+	})
+}
+
+func testAccDataSourceMAASNodeEvents() string {
+	return `
+data "maas_node_events" "test" {
+  level         = "DEBUG"
+  created_after = "2000-01-01T00:00:00Z"
+  limit         = 5
+}
+`
+}
@@ -0,0 +1,218 @@
+package maas
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceMAASNodeScriptResults() *schema.Resource {
+	return &schema.Resource{
+		Description: "Provides details about the latest commissioning, testing, installation or release script results of a MAAS machine.",
+		ReadContext: dataSourceNodeScriptResultsRead,
+
+		Schema: map[string]*schema.Schema{
+			"base64_encode": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Return the `output`, `stdout`, `stderr` and `result` of the scripts base64 encoded. Useful for binary output. Defaults to `false`.",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The identifier (system ID, hostname, FQDN or PXE MAC address) of the machine.",
+			},
+			"max_output_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The maximum size (in bytes) of the `output`, `stdout`, `stderr` and `result` of the scripts, larger outputs are truncated. Defaults to `0`, which doesn't truncate the outputs.",
+			},
+			"result_type": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"commissioning", "testing", "installation", "release"}, false)),
+				Description:      "The type of the script results. Valid options are: `commissioning`, `testing`, `installation` and `release`.",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The script results, ordered by ID.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ended": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The time the script ended.",
+						},
+						"exit_status": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The exit status of the script, `-1` if the script did not run to completion.",
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The script result ID.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the script.",
+						},
+						"output": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The combined standard output and error of the script.",
+						},
+						"parameters": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Serialized JSON string containing the parameters the script ran with.",
+						},
+						"result": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The YAML result file written by the script, if any.",
+						},
+						"runtime": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The runtime of the script.",
+						},
+						"started": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The time the script started.",
+						},
+						"status": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The status of the script (e.g. `Passed`, `Failed` or `Timed out`).",
+						},
+						"stderr": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The standard error of the script.",
+						},
+						"stdout": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The standard output of the script.",
+						},
+					},
+				},
+			},
+			"script_names": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Only return the results of the scripts with the given names or tags.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The overall status of the script results.",
+			},
+		},
+	}
+}
+
+func dataSourceNodeScriptResultsRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	resultType := d.Get("result_type").(string)
+
+	resultSet, err := getNodeLatestScriptResultSet(client, machine.SystemID, resultType, convertToStringSlice(d.Get("script_names")))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	encodeBase64 := d.Get("base64_encode").(bool)
+	maxSize := d.Get("max_output_size").(int)
+
+	results := make([]map[string]any, len(resultSet.Results))
+
+	for i, result := range resultSet.Results {
+		params, ok := result.Parameters.(map[string]any)
+		if !ok {
+			params = map[string]any{}
+		}
+
+		parameters, err := structure.FlattenJsonToString(params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		item := map[string]any{
+			"ended":       result.Ended,
+			"exit_status": result.exitStatus(),
+			"id":          result.ID,
+			"name":        result.Name,
+			"parameters":  parameters,
+			"runtime":     result.Runtime,
+			"started":     result.Started,
+			"status":      result.StatusName,
+		}
+
+		outputs := map[string]string{
+			"output": result.Output,
+			"result": result.Result,
+			"stderr": result.Stderr,
+			"stdout": result.Stdout,
+		}
+		for k, v := range outputs {
+			item[k], err = formatScriptOutput(v, encodeBase64, maxSize)
+			if err != nil {
+				return diag.FromErr(fmt.Errorf("script (%s) %s: %w", result.Name, k, err))
+			}
+		}
+
+		results[i] = item
+	}
+
+	tfState := map[string]any{
+		"results": results,
+		"status":  resultSet.StatusName,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(fmt.Sprintf("%s/%d", machine.SystemID, resultSet.ID))
+
+	return nil
+}
+
+// formatScriptOutput decodes a script output returned by MAAS, truncates it to
+// maxSize bytes unless it's 0, and optionally encodes it back to base64.
+func formatScriptOutput(output string, encodeBase64 bool, maxSize int) (string, error) {
+	data, err := decodeScriptOutput(output)
+	if err != nil {
+		return "", err
+	}
+
+	if maxSize > 0 && len(data) > maxSize {
+		data = data[:maxSize]
+	}
+
+	if encodeBase64 {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	return string(data), nil
+}
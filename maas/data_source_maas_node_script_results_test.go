@@ -0,0 +1,47 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMAASNodeScriptResults_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_SCRIPT_RESULTS_MACHINE")
+
+	checks := []resource.TestCheckFunc{
+		resource.TestCheckResourceAttr("data.maas_node_script_results.test", "results.#", "1"),
+		resource.TestCheckResourceAttr("data.maas_node_script_results.test", "results.0.name", "50-maas-01-commissioning"),
+		resource.TestCheckResourceAttr("data.maas_node_script_results.test", "results.0.status", "Passed"),
+		resource.TestCheckResourceAttr("data.maas_node_script_results.test", "results.0.exit_status", "0"),
+		resource.TestCheckResourceAttrSet("data.maas_node_script_results.test", "results.0.output"),
+		resource.TestCheckResourceAttr("data.maas_node_script_results.test", "status", "Passed"),
+	}
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:   func() { testutils.PreCheck(t, []string{"TF_ACC_SCRIPT_RESULTS_MACHINE"}) },
+		Providers:  testutils.TestAccProviders,
+		ErrorCheck: func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMAASNodeScriptResults(machine),
+				Check:  resource.ComposeTestCheckFunc(checks...),
+			},
+		},
+	})
+}
+
+func testAccDataSourceMAASNodeScriptResults(machine string) string {
+	return fmt.Sprintf(`
+data "maas_node_script_results" "test" {
+  machine         = %q
+  result_type     = "commissioning"
+  script_names    = ["50-maas-01-commissioning"]
+  max_output_size = 1024
+}
+`, machine)
+}
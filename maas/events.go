@@ -0,0 +1,201 @@
+package maas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	eventlevel "github.com/canonical/gomaasclient/entity/event"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
+)
+
+// machineEventsWatcher reports the MAAS events of a machine while waiting for
+// long running operations, like commissioning and deployment.
+type machineEventsWatcher struct {
+	client    *client.Client
+	systemID  string
+	lastID    int
+	lastEvent *entity.Event
+}
+
+// newMachineEventsWatcher creates a watcher reporting only the events created
+// after the watcher.
+func newMachineEventsWatcher(client *client.Client, systemID string) *machineEventsWatcher {
+	w := &machineEventsWatcher{client: client, systemID: systemID}
+
+	events, err := client.Events.Get(&entity.EventParams{ID: systemID, Limit: "1"})
+	if err != nil {
+		log.Printf("[DEBUG] Unable to get machine (%s) events: %s\n", systemID, err)
+		return w
+	}
+
+	for _, event := range events.Events {
+		w.lastID = max(w.lastID, event.ID)
+	}
+
+	return w
+}
+
+// fetch returns the machine events created since the last fetch, oldest first.
+// Errors are only logged, since events are informational and must not fail the wait.
+func (w *machineEventsWatcher) fetch() []entity.Event {
+	const limit = 100
+
+	result := []entity.Event{}
+
+	for {
+		events, err := w.client.Events.Get(&entity.EventParams{ID: w.systemID, After: fmt.Sprintf("%d", w.lastID), Limit: fmt.Sprintf("%d", limit)})
+		if err != nil {
+			log.Printf("[DEBUG] Unable to get machine (%s) events: %s\n", w.systemID, err)
+			return result
+		}
+
+		sort.Slice(events.Events, func(i, j int) bool {
+			return events.Events[i].ID < events.Events[j].ID
+		})
+
+		for _, event := range events.Events {
+			if event.ID <= w.lastID {
+				continue
+			}
+
+			result = append(result, event)
+			w.lastID = event.ID
+			w.lastEvent = &event
+		}
+
+		if len(events.Events) < limit {
+			return result
+		}
+	}
+}
+
+// poll logs and returns the machine events created since the last poll.
+func (w *machineEventsWatcher) poll(ctx context.Context) []entity.Event {
+	events := w.fetch()
+	for _, event := range events {
+		tflog.Info(ctx, fmt.Sprintf("Machine (%s) event: %s", w.systemID, formatMachineEvent(&event)), map[string]any{
+			"system_id": w.systemID,
+			"hostname":  event.Hostname,
+			"created":   event.Created,
+			"level":     string(event.Level),
+		})
+	}
+
+	return events
+}
+
+// wrapError adds the last reported event to a wait error.
+func (w *machineEventsWatcher) wrapError(err error) error {
+	if w.lastEvent == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w (last machine event at %s: %s)", err, w.lastEvent.Created, formatMachineEvent(w.lastEvent))
+}
+
+func formatMachineEvent(event *entity.Event) string {
+	if event.Description == "" {
+		return event.Type
+	}
+
+	return fmt.Sprintf("%s - %s", event.Type, event.Description)
+}
+
+// waitForCloudInit waits for the machine cloud-init to finish, based on the events
+// reported by the watcher. The watcher must be created before the machine is deployed,
+// so that no cloud-init event is missed.
+func waitForCloudInit(ctx context.Context, w *machineEventsWatcher, timeout time.Duration) error {
+	log.Printf("[DEBUG] Waiting for machine (%s) cloud-init to finish\n", w.systemID)
+
+	// The events created during the deployment were already reported while waiting for the deployment
+	pending := w.fetch()
+	tracker := &cloudInitTracker{}
+	stateConf := &retry.StateChangeConf{
+		Pending: []string{"running"},
+		Target:  []string{"finished"},
+		Refresh: func() (any, string, error) {
+			events := append(pending, w.poll(ctx)...)
+			pending = nil
+
+			for _, event := range events {
+				finished, err := tracker.observe(&event)
+				if err != nil {
+					return nil, "", fmt.Errorf("machine (%s) %w", w.systemID, err)
+				}
+
+				if finished {
+					return event, "finished", nil
+				}
+			}
+
+			return w.systemID, "running", nil
+		},
+		Timeout:    timeout,
+		MinTimeout: 10 * time.Second,
+	}
+
+	if _, err := stateConf.WaitForStateContext(ctx); err != nil {
+		var timeoutErr *retry.TimeoutError
+		if errors.As(err, &timeoutErr) {
+			return w.wrapError(err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// cloudInitEventPattern matches the cloud-init progress reported by a machine, e.g.
+// "finish: modules-final: SUCCESS: running modules for final".
+var cloudInitEventPattern = regexp.MustCompile(`\b(start|finish): '?([\w/.-]+)'?(?:: (SUCCESS|WARN|FAIL)\b)?`)
+
+// cloudInitTracker follows the cloud-init of a deployed machine through its events,
+// oldest first. The events created before the machine is deployed come from the
+// ephemeral environment, so they are ignored.
+type cloudInitTracker struct {
+	deployed bool
+}
+
+// observe returns whether the event reports the end of the final cloud-init stage,
+// or an error if it reports a cloud-init failure.
+func (t *cloudInitTracker) observe(event *entity.Event) (bool, error) {
+	if !t.deployed {
+		t.deployed = isDeployedMachineEvent(event)
+		return false, nil
+	}
+
+	if !isCloudInitEvent(event) {
+		return false, nil
+	}
+
+	var action, stage, result string
+	if m := cloudInitEventPattern.FindStringSubmatch(event.Description); m != nil {
+		action, stage, result = m[1], m[2], m[3]
+	}
+
+	if result == "FAIL" || event.Level == eventlevel.ERROR || event.Level == eventlevel.CRITICAL {
+		return false, fmt.Errorf("cloud-init failed: %s", event.Description)
+	}
+
+	return action == "finish" && stage == "modules-final", nil
+}
+
+func isDeployedMachineEvent(event *entity.Event) bool {
+	return event.Type == "Deployed" || (event.Type == "Node changed status" && strings.HasSuffix(event.Description, "to 'Deployed'"))
+}
+
+func isCloudInitEvent(event *entity.Event) bool {
+	text := strings.ToLower(event.Type + " " + event.Description)
+
+	return strings.Contains(text, "cloudinit") || strings.Contains(text, "cloud-init")
+}
@@ -0,0 +1,209 @@
+package maas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	eventlevel "github.com/canonical/gomaasclient/entity/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEvents serves the events of a single machine like the MAAS events API:
+// newest first, limited, and after an event ID when requested.
+type testEvents struct {
+	events   []entity.Event
+	requests int
+}
+
+func (e *testEvents) Get(params *entity.EventParams) (*entity.EventsResp, error) {
+	e.requests++
+
+	after, _ := strconv.Atoi(params.After)
+	limit, _ := strconv.Atoi(params.Limit)
+
+	var events []entity.Event
+
+	for _, event := range e.events {
+		if event.ID > after {
+			events = append(events, event)
+		}
+	}
+
+	// The oldest events after the given ID, or the newest ones
+	if params.After == "" {
+		slices.Reverse(events)
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	slices.SortFunc(events, func(a, b entity.Event) int { return b.ID - a.ID })
+
+	return &entity.EventsResp{Events: events, Count: len(events)}, nil
+}
+
+func (e *testEvents) add(n int) {
+	for range n {
+		id := len(e.events) + 1
+		e.events = append(e.events, entity.Event{
+			ID:          id,
+			Type:        "Node changed status",
+			Description: fmt.Sprintf("event %d", id),
+			Created:     "Sun, 18 Oct. 2026 20:00:00",
+		})
+	}
+}
+
+func testMachineEventsWatcher(events *testEvents) *machineEventsWatcher {
+	return newMachineEventsWatcher(&client.Client{Events: events}, "abc123")
+}
+
+func TestMachineEventsWatcherFetch(t *testing.T) {
+	events := &testEvents{}
+	events.add(3)
+
+	// The events created before the watcher are not reported
+	w := testMachineEventsWatcher(events)
+	assert.Empty(t, w.fetch())
+
+	// The events are paged through, oldest first
+	events.add(250)
+	events.requests = 0
+
+	fetched := w.fetch()
+	assert.Len(t, fetched, 250)
+	assert.Equal(t, 4, fetched[0].ID)
+	assert.Equal(t, 253, fetched[249].ID)
+	assert.Equal(t, 3, events.requests)
+
+	assert.Empty(t, w.fetch())
+}
+
+func TestMachineEventsWatcherPoll(t *testing.T) {
+	events := &testEvents{}
+	w := testMachineEventsWatcher(events)
+
+	events.add(2)
+
+	polled := w.poll(context.Background())
+	assert.Equal(t, []int{1, 2}, []int{polled[0].ID, polled[1].ID})
+	assert.Equal(t, 2, w.lastEvent.ID)
+	assert.Empty(t, w.poll(context.Background()))
+}
+
+func TestMachineEventsWatcherWrapError(t *testing.T) {
+	errTimeout := errors.New("timeout while waiting for state to become 'Deployed'")
+
+	events := &testEvents{}
+	w := testMachineEventsWatcher(events)
+	assert.Equal(t, errTimeout, w.wrapError(errTimeout))
+
+	events.add(1)
+	w.poll(context.Background())
+
+	err := w.wrapError(errTimeout)
+	assert.EqualError(t, err, "timeout while waiting for state to become 'Deployed' (last machine event at Sun, 18 Oct. 2026 20:00:00: Node changed status - event 1)")
+	assert.ErrorIs(t, err, errTimeout)
+}
+
+// testCloudInitEvents is a recorded deployment: the ephemeral environment reports
+// its own cloud-init stages before the machine is deployed.
+func testCloudInitEvents() []entity.Event {
+	return []entity.Event{
+		{ID: 1, Type: "Deploying", Description: ""},
+		{ID: 2, Type: "Configuring OS", Description: "'cloudinit' finish: modules-final: SUCCESS: running modules for final"},
+		{ID: 3, Type: "Installing OS", Description: "'curtin' finish: cmd-install: SUCCESS: curtin command install"},
+		{ID: 4, Type: "Rebooting", Description: ""},
+		{ID: 5, Type: "Node changed status", Description: "From 'Deploying' to 'Deployed'"},
+		{ID: 6, Type: "Configuring OS", Description: "'cloudinit' start: modules-config: running modules for config"},
+		{ID: 7, Type: "Configuring OS", Description: "'cloudinit' finish: modules-config: SUCCESS: running modules for config"},
+		{ID: 8, Type: "Configuring OS", Description: "'cloudinit' start: modules-final: running modules for final"},
+		{ID: 9, Type: "Configuring OS", Description: "'cloudinit' finish: modules-final/config-scripts-user: SUCCESS: config-scripts-user ran successfully"},
+		{ID: 10, Type: "Configuring OS", Description: "'cloudinit' finish: modules-final: SUCCESS: running modules for final"},
+	}
+}
+
+func TestCloudInitTracker(t *testing.T) {
+	testCases := []struct {
+		name     string
+		update   func(events []entity.Event) []entity.Event
+		finished int
+		err      string
+	}{
+		{
+			name:     "final stage after deployment",
+			update:   func(events []entity.Event) []entity.Event { return events },
+			finished: 10,
+		},
+		{
+			name:   "still running",
+			update: func(events []entity.Event) []entity.Event { return events[:9] },
+		},
+		{
+			name: "failed user data",
+			update: func(events []entity.Event) []entity.Event {
+				events[8].Description = "'cloudinit' finish: modules-final/config-scripts-user: FAIL: running config-scripts-user with frequency once-per-instance"
+				return events
+			},
+			err: "cloud-init failed: 'cloudinit' finish: modules-final/config-scripts-user: FAIL: running config-scripts-user with frequency once-per-instance",
+		},
+		{
+			name: "error level",
+			update: func(events []entity.Event) []entity.Event {
+				events[6].Level = eventlevel.ERROR
+				return events
+			},
+			err: "cloud-init failed: 'cloudinit' finish: modules-config: SUCCESS: running modules for config",
+		},
+		{
+			name: "failures are only words",
+			update: func(events []entity.Event) []entity.Event {
+				events[7].Description = "'cloudinit' start: modules-final: running modules for final, failsafe disabled"
+				return events
+			},
+			finished: 10,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tracker := &cloudInitTracker{}
+			finished := 0
+
+			var err error
+
+			for _, event := range testCase.update(testCloudInitEvents()) {
+				var done bool
+				if done, err = tracker.observe(&event); err != nil || done {
+					finished = event.ID
+					break
+				}
+			}
+
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.finished, finished)
+		})
+	}
+}
+
+func TestWaitForCloudInit(t *testing.T) {
+	events := &testEvents{}
+	w := testMachineEventsWatcher(events)
+
+	events.events = testCloudInitEvents()
+
+	assert.NoError(t, waitForCloudInit(context.Background(), w, time.Minute))
+}
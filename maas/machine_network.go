@@ -0,0 +1,851 @@
+package maas
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Network interface kinds of a machine network layout, listed in dependency order:
+// an interface only has parents of a previous kind.
+const (
+	networkKindPhysical = "physical"
+	networkKindBond     = "bond"
+	networkKindVLAN     = "vlan"
+	networkKindBridge   = "bridge"
+)
+
+var networkKinds = []string{networkKindPhysical, networkKindBond, networkKindVLAN, networkKindBridge}
+
+// networkInterfaceResources are the resources managing a single interface of each kind.
+var networkInterfaceResources = map[string]func() *schema.Resource{
+	networkKindPhysical: resourceMAASNetworkInterfacePhysical,
+	networkKindBond:     resourceMAASNetworkInterfaceBond,
+	networkKindVLAN:     resourceMAASNetworkInterfaceVLAN,
+	networkKindBridge:   resourceMAASNetworkInterfaceBridge,
+}
+
+// networkInterfaceParams are the settings specific to a kind of interface, named
+// after the MAAS API parameters.
+var networkInterfaceParams = map[string][]string{
+	networkKindBond:   {"bond_downdelay", "bond_lacp_rate", "bond_miimon", "bond_mode", "bond_num_grat_arp", "bond_updelay", "bond_xmit_hash_policy"},
+	networkKindBridge: {"bridge_fd", "bridge_stp", "bridge_type"},
+}
+
+// networkLink is a subnet link of an interface of a machine network layout.
+type networkLink struct {
+	ID             int
+	Subnet         string
+	Mode           string
+	IPAddress      string
+	DefaultGateway bool
+}
+
+// networkInterface is an interface of a machine network layout. Physical interfaces
+// are identified by their MAC address, VLAN interfaces are named `<parent>.<vid>`.
+type networkInterface struct {
+	ID         int
+	Kind       string
+	Name       string
+	MACAddress string
+	Parents    []string
+	VID        int
+	MTU        int
+	Tags       []string
+	VLAN       int
+	Params     map[string]string
+	Links      []*networkLink
+}
+
+// machineNetworkLayout is the network layout of a machine, as described by a
+// maas_machine_network resource. The IDs are only known for existing objects.
+type machineNetworkLayout struct {
+	Interfaces []*networkInterface
+}
+
+func (l *machineNetworkLayout) networkInterface(name string) *networkInterface {
+	return findStorageObject(l.Interfaces, func(o *networkInterface) string { return o.Name }, name)
+}
+
+// keys returns the names of the interfaces of the layout, in dependency order.
+func (l *machineNetworkLayout) keys() []string {
+	var keys []string
+
+	for _, kind := range networkKinds {
+		for _, o := range l.Interfaces {
+			if o.Kind == kind {
+				keys = append(keys, o.Name)
+			}
+		}
+	}
+
+	return keys
+}
+
+func (l *machineNetworkLayout) dependencies(key string) []string {
+	if o := l.networkInterface(key); o != nil {
+		return o.Parents
+	}
+
+	return nil
+}
+
+// structure returns the part of an interface that cannot be changed without
+// recreating it, or nil if the interface does not exist.
+func (l *machineNetworkLayout) structure(key string) any {
+	o := l.networkInterface(key)
+	if o == nil {
+		return nil
+	}
+
+	// The MAC address of a physical interface identifies it, the one of the other
+	// interfaces is a setting
+	if o.Kind == networkKindPhysical {
+		return []any{o.Kind, strings.ToLower(o.MACAddress)}
+	}
+
+	return []any{o.Kind, sortedStrings(o.Parents), o.VID}
+}
+
+// validate checks that the names are unique and the parents are valid.
+func (l *machineNetworkLayout) validate() error {
+	parentOf := map[string]string{}
+
+	for _, o := range l.Interfaces {
+		if l.networkInterface(o.Name) != o {
+			return fmt.Errorf("interface name %q is duplicated", o.Name)
+		}
+
+		allowed := map[string][]string{
+			networkKindBond:   {networkKindPhysical},
+			networkKindVLAN:   {networkKindPhysical, networkKindBond},
+			networkKindBridge: {networkKindPhysical, networkKindBond, networkKindVLAN},
+		}[o.Kind]
+
+		for _, name := range o.Parents {
+			parent := l.networkInterface(name)
+			if parent == nil {
+				return fmt.Errorf("%s %q: %q is not an interface of the layout", o.Kind, o.Name, name)
+			}
+
+			if !slices.Contains(allowed, parent.Kind) {
+				return fmt.Errorf("%s %q: %q cannot be used, only a %s can be used", o.Kind, o.Name, name, strings.Join(allowed, ", "))
+			}
+
+			// The parents of a bond or a bridge are enslaved, a VLAN only tags its parent
+			if o.Kind == networkKindVLAN {
+				continue
+			}
+
+			if other, ok := parentOf[name]; ok {
+				return fmt.Errorf("%s %q: %q is already a parent of %q", o.Kind, o.Name, name, other)
+			}
+
+			parentOf[name] = o.Name
+
+			if len(parent.Links) > 0 {
+				return fmt.Errorf("%s %q: %q cannot have links, it is a parent of the %s", o.Kind, o.Name, name, o.Kind)
+			}
+		}
+
+		for _, link := range o.Links {
+			if link.IPAddress != "" && link.Mode != "STATIC" {
+				return fmt.Errorf("%s %q: ip_address can only be set on a link in the STATIC mode", o.Kind, o.Name)
+			}
+
+			if link.DefaultGateway && link.Mode != "AUTO" && link.Mode != "STATIC" {
+				return fmt.Errorf("%s %q: default_gateway can only be set on a link in the AUTO or STATIC mode", o.Kind, o.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// settingsChanged returns whether the settings of the interface differ from the
+// desired ones. The unset MTU, VLAN and MAC address are left unchanged.
+func (o *networkInterface) settingsChanged(desired *networkInterface) bool {
+	switch {
+	case desired.MTU != 0 && desired.MTU != o.MTU:
+		return true
+	case desired.VLAN != 0 && desired.VLAN != o.VLAN:
+		return true
+	case desired.MACAddress != "" && !strings.EqualFold(desired.MACAddress, o.MACAddress):
+		return true
+	case !slices.Equal(sortedStrings(desired.Tags), sortedStrings(o.Tags)):
+		return true
+	}
+
+	for k, v := range desired.Params {
+		if o.Params[k] != v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// linksChanged returns whether the links of the interface differ from the desired
+// ones. The IP address is only compared for static links.
+func (o *networkInterface) linksChanged(desired *networkInterface) bool {
+	if len(o.Links) != len(desired.Links) {
+		return true
+	}
+
+	for i, link := range desired.Links {
+		current := o.Links[i]
+
+		switch {
+		case current.Subnet != link.Subnet || !strings.EqualFold(current.Mode, link.Mode):
+			return true
+		case link.Mode == "STATIC" && link.IPAddress != "" && current.IPAddress != link.IPAddress:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *machineNetworkLayout) defaultGateways() []string {
+	var gateways []string
+
+	for _, o := range l.Interfaces {
+		for i, link := range o.Links {
+			if link.DefaultGateway {
+				gateways = append(gateways, fmt.Sprintf("%s/%d", o.Name, i))
+			}
+		}
+	}
+
+	return sortedStrings(gateways)
+}
+
+// sortLike orders the interfaces of the layout like the ones of the given layout.
+func (l *machineNetworkLayout) sortLike(o *machineNetworkLayout) {
+	sortByName(l.Interfaces, o.Interfaces, func(n *networkInterface) string { return n.Name })
+}
+
+func (l *machineNetworkLayout) clone() *machineNetworkLayout {
+	c := &machineNetworkLayout{}
+
+	for _, o := range l.Interfaces {
+		n := *o
+		n.Parents = slices.Clone(o.Parents)
+		n.Tags = slices.Clone(o.Tags)
+		n.Params = maps.Clone(o.Params)
+		n.Links = nil
+
+		for _, link := range o.Links {
+			copied := *link
+			n.Links = append(n.Links, &copied)
+		}
+
+		c.Interfaces = append(c.Interfaces, &n)
+	}
+
+	return c
+}
+
+// machineNetworkApplier changes the network of a machine from the current layout
+// to a desired one. The current layout is kept up to date with every change, so
+// that a failed change can be rolled back.
+type machineNetworkApplier struct {
+	client  *client.Client
+	machine *entity.Machine
+	current *machineNetworkLayout
+}
+
+func newMachineNetworkApplier(client *client.Client, machine *entity.Machine, current *machineNetworkLayout) *machineNetworkApplier {
+	return &machineNetworkApplier{client: client, machine: machine, current: current}
+}
+
+// apply changes the machine network to the desired layout. The interfaces that
+// changed, and the ones depending on them, are deleted in reverse dependency order
+// and created again in dependency order. Settings and links are changed in place.
+// If a change fails, the previous layout is restored.
+func (a *machineNetworkApplier) apply(desired *machineNetworkLayout) error {
+	if err := desired.validate(); err != nil {
+		return err
+	}
+
+	previous := a.current.clone()
+
+	err := a.converge(desired)
+	if err == nil {
+		a.current.sortLike(desired)
+		return nil
+	}
+
+	log.Printf("[DEBUG] Machine (%s) rolling back the network layout: %s\n", a.machine.SystemID, err)
+
+	if rollbackErr := a.converge(previous); rollbackErr != nil {
+		return fmt.Errorf("%w\nAdditionally, the network layout could not be rolled back: %w", err, rollbackErr)
+	}
+
+	a.current.sortLike(previous)
+
+	return err
+}
+
+// destroy deletes the bonds, VLANs and bridges of the current layout, and
+// disconnects its physical interfaces.
+func (a *machineNetworkApplier) destroy() error {
+	return a.converge(&machineNetworkLayout{})
+}
+
+func (a *machineNetworkApplier) converge(desired *machineNetworkLayout) error {
+	// Find the interfaces to recreate, including the ones depending on them
+	recreate := map[string]bool{}
+
+	for _, key := range a.current.keys() {
+		if !reflect.DeepEqual(a.current.structure(key), desired.structure(key)) {
+			recreate[key] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, key := range desired.keys() {
+			if recreate[key] {
+				continue
+			}
+
+			for _, dep := range desired.dependencies(key) {
+				if recreate[dep] || a.current.structure(dep) == nil {
+					recreate[key] = true
+					changed = true
+
+					break
+				}
+			}
+		}
+	}
+
+	// Remove the links changed in place first, so that their IP addresses can be
+	// reused and the interfaces becoming parents are disconnected
+	for _, o := range a.current.Interfaces {
+		if d := desired.networkInterface(o.Name); d != nil && !recreate[o.Name] && o.linksChanged(d) {
+			if err := a.unlinkAll(o); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys := a.current.keys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if recreate[keys[i]] {
+			if err := a.delete(keys[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range desired.keys() {
+		d := desired.networkInterface(key)
+
+		o := a.current.networkInterface(key)
+		if o == nil {
+			if err := a.create(d); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if o.settingsChanged(d) {
+			if err := a.update(o, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range desired.keys() {
+		o, d := a.current.networkInterface(key), desired.networkInterface(key)
+		if !o.linksChanged(d) {
+			continue
+		}
+
+		if err := a.unlinkAll(o); err != nil {
+			return err
+		}
+
+		for _, link := range d.Links {
+			if err := a.link(o, link); err != nil {
+				return err
+			}
+		}
+	}
+
+	return a.setDefaultGateways(desired)
+}
+
+// params returns the API parameters of the settings of an interface.
+func (a *machineNetworkApplier) params(o *networkInterface) url.Values {
+	params := url.Values{}
+	params.Set("name", o.Name)
+	params.Set("tags", strings.Join(o.Tags, ","))
+
+	if o.MTU != 0 {
+		params.Set("mtu", strconv.Itoa(o.MTU))
+	}
+
+	if o.VLAN != 0 {
+		params.Set("vlan", strconv.Itoa(o.VLAN))
+	}
+
+	if o.MACAddress != "" {
+		params.Set("mac_address", o.MACAddress)
+	}
+
+	for k, v := range o.Params {
+		params.Set(k, v)
+	}
+
+	return params
+}
+
+// resourceData returns the settings of an interface as the data of the resource
+// managing a single interface of its kind, to build the API parameters the same way.
+func (o *networkInterface) resourceData() (*schema.ResourceData, error) {
+	r := networkInterfaceResources[o.Kind]()
+	d := r.Data(nil)
+
+	tfState := map[string]any{
+		"mtu":  o.MTU,
+		"tags": o.Tags,
+	}
+
+	// The name, VLAN and MAC address of a VLAN interface follow its parent
+	if o.Kind != networkKindVLAN {
+		tfState["name"] = o.Name
+		tfState["mac_address"] = o.MACAddress
+		tfState["vlan"] = o.VLAN
+	}
+
+	for k, v := range o.Params {
+		var err error
+
+		switch r.Schema[k].Type {
+		case schema.TypeInt:
+			tfState[k], err = strconv.Atoi(v)
+		case schema.TypeBool:
+			tfState[k], err = strconv.ParseBool(v)
+		default:
+			tfState[k] = v
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: invalid %s: %w", o.Kind, o.Name, k, err)
+		}
+	}
+
+	return d, setTerraformState(d, tfState)
+}
+
+// resetsSettings returns whether the desired settings reset some of the current
+// ones to their zero value, which the typed API parameters omit.
+func (o *networkInterface) resetsSettings(desired *networkInterface) bool {
+	if len(desired.Tags) == 0 && len(o.Tags) > 0 {
+		return true
+	}
+
+	for k, v := range desired.Params {
+		if v != o.Params[k] && (v == "" || v == "0" || v == "false") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *machineNetworkApplier) parentIDs(o *networkInterface) []int {
+	ids := make([]int, len(o.Parents))
+	for i, name := range o.Parents {
+		ids[i] = a.current.networkInterface(name).ID
+	}
+
+	return ids
+}
+
+func (a *machineNetworkApplier) create(o *networkInterface) error {
+	log.Printf("[DEBUG] Machine (%s) creating %s interface %s\n", a.machine.SystemID, o.Kind, o.Name)
+
+	created := &networkInterface{Kind: o.Kind, Name: o.Name, MACAddress: o.MACAddress, Parents: o.Parents, VID: o.VID}
+
+	d, err := o.resourceData()
+	if err != nil {
+		return err
+	}
+
+	var n *entity.NetworkInterface
+
+	switch o.Kind {
+	case networkKindPhysical:
+		n, err = a.createPhysical(o, d)
+	case networkKindBond:
+		n, err = a.client.NetworkInterfaces.CreateBond(a.machine.SystemID, getNetworkInterfaceBondParams(d, a.parentIDs(o)))
+	case networkKindVLAN:
+		n, err = a.createVLAN(o, d)
+	case networkKindBridge:
+		n, err = a.client.NetworkInterfaces.CreateBridge(a.machine.SystemID, getNetworkInterfaceBridgeParams(d, a.parentIDs(o)[0]))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create %s %q: %w", o.Kind, o.Name, err)
+	}
+
+	created.ID = n.ID
+	created.readSettings(n)
+	a.current.Interfaces = append(a.current.Interfaces, created)
+
+	return nil
+}
+
+// createPhysical configures the physical interface with the MAC address, created
+// if the machine does not have it. Its existing links are removed.
+func (a *machineNetworkApplier) createPhysical(o *networkInterface, d *schema.ResourceData) (*entity.NetworkInterface, error) {
+	existing, err := findNetworkInterfacePhysical(a.client, a.machine.SystemID, o.MACAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return a.client.NetworkInterfaces.CreatePhysical(a.machine.SystemID, getNetworkInterfacePhysicalParams(d))
+	}
+
+	for _, link := range existing.Links {
+		if _, err := a.client.NetworkInterface.UnlinkSubnet(a.machine.SystemID, existing.ID, link.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	current := &networkInterface{ID: existing.ID, Kind: networkKindPhysical}
+	current.readSettings(existing)
+
+	return a.updateSettings(current, o)
+}
+
+func (a *machineNetworkApplier) createVLAN(o *networkInterface, d *schema.ResourceData) (*entity.NetworkInterface, error) {
+	parent, err := a.client.NetworkInterface.Get(a.machine.SystemID, a.current.networkInterface(o.Parents[0]).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The VLAN is on the fabric of the parent
+	vlans, err := a.client.VLANs.Get(parent.VLAN.FabricID)
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(vlans, func(v entity.VLAN) bool { return v.VID == o.VID })
+	if i < 0 {
+		return nil, fmt.Errorf("VLAN %d was not found on the fabric (%d) of %q", o.VID, parent.VLAN.FabricID, parent.Name)
+	}
+
+	return a.client.NetworkInterfaces.CreateVLAN(a.machine.SystemID, getNetworkInterfaceVLANParams(d, parent.ID, vlans[i].ID))
+}
+
+func (a *machineNetworkApplier) update(o *networkInterface, desired *networkInterface) error {
+	log.Printf("[DEBUG] Machine (%s) updating %s interface %s\n", a.machine.SystemID, o.Kind, o.Name)
+
+	networkInterface, err := a.updateSettings(o, desired)
+	if err != nil {
+		return fmt.Errorf("failed to update %s %q: %w", o.Kind, o.Name, err)
+	}
+
+	o.readSettings(networkInterface)
+
+	return nil
+}
+
+// updateSettings changes the settings of the interface o to the desired ones.
+func (a *machineNetworkApplier) updateSettings(o *networkInterface, desired *networkInterface) (*entity.NetworkInterface, error) {
+	// The typed parameters omit the zero values, so that e.g. the tags or
+	// `bridge_stp` could not be reset. The raw parameters are sent in this case.
+	if o.resetsSettings(desired) {
+		params := a.params(desired)
+		if o.Kind == networkKindVLAN {
+			params.Del("name")
+			params.Del("vlan")
+			params.Del("mac_address")
+		}
+
+		return a.updateInterface(o.ID, params)
+	}
+
+	d, err := desired.resourceData()
+	if err != nil {
+		return nil, err
+	}
+
+	var params *entity.NetworkInterfaceUpdateParams
+
+	switch o.Kind {
+	case networkKindPhysical:
+		params = getNetworkInterfaceUpdateParams(d)
+	case networkKindBond:
+		params = getNetworkInterfaceBondUpdateParams(d, a.parentIDs(o))
+	case networkKindVLAN:
+		params = getNetworkInterfaceVLANUpdateParams(d, a.parentIDs(o)[0], 0)
+	case networkKindBridge:
+		params = getNetworkInterfaceBridgeUpdateParams(d, a.parentIDs(o)[0])
+	}
+
+	return a.client.NetworkInterface.Update(a.machine.SystemID, o.ID, params)
+}
+
+func (a *machineNetworkApplier) delete(key string) error {
+	o := a.current.networkInterface(key)
+
+	log.Printf("[DEBUG] Machine (%s) deleting %s interface %s\n", a.machine.SystemID, o.Kind, o.Name)
+
+	var err error
+
+	// Physical interfaces are only disconnected
+	if o.Kind == networkKindPhysical {
+		_, err = a.client.NetworkInterface.Disconnect(a.machine.SystemID, o.ID)
+	} else {
+		err = a.client.NetworkInterface.Delete(a.machine.SystemID, o.ID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %q: %w", o.Kind, o.Name, err)
+	}
+
+	a.current.Interfaces = slices.DeleteFunc(a.current.Interfaces, func(v *networkInterface) bool { return v == o })
+
+	return nil
+}
+
+func (a *machineNetworkApplier) unlinkAll(o *networkInterface) error {
+	for len(o.Links) > 0 {
+		link := o.Links[len(o.Links)-1]
+		if _, err := a.client.NetworkInterface.UnlinkSubnet(a.machine.SystemID, o.ID, link.ID); err != nil {
+			return fmt.Errorf("failed to unlink %s %q from subnet %s: %w", o.Kind, o.Name, link.Subnet, err)
+		}
+
+		o.Links = o.Links[:len(o.Links)-1]
+	}
+
+	return nil
+}
+
+func (a *machineNetworkApplier) link(o *networkInterface, link *networkLink) error {
+	subnet, err := getSubnet(a.client, link.Subnet)
+	if err != nil {
+		return err
+	}
+
+	params := &entity.NetworkInterfaceLinkParams{Subnet: subnet.ID, Mode: link.Mode}
+	if link.Mode == "STATIC" {
+		params.IPAddress = link.IPAddress
+	}
+
+	networkInterface, err := a.client.NetworkInterface.LinkSubnet(a.machine.SystemID, o.ID, params)
+	if err != nil {
+		return fmt.Errorf("failed to link %s %q to subnet %s: %w", o.Kind, o.Name, link.Subnet, err)
+	}
+
+	for _, l := range networkInterface.Links {
+		if !slices.ContainsFunc(o.Links, func(v *networkLink) bool { return v.ID == l.ID }) {
+			o.Links = append(o.Links, &networkLink{ID: l.ID, Subnet: link.Subnet, Mode: link.Mode, IPAddress: l.IPAddress})
+			break
+		}
+	}
+
+	// The VLAN follows the subnet
+	o.VLAN = networkInterface.VLAN.ID
+
+	return nil
+}
+
+// setDefaultGateways sets the default gateways of the machine to the links of the
+// desired layout marked as default gateway.
+func (a *machineNetworkApplier) setDefaultGateways(desired *machineNetworkLayout) error {
+	if slices.Equal(a.current.defaultGateways(), desired.defaultGateways()) {
+		return nil
+	}
+
+	if _, err := a.client.Machine.ClearDefaultGateways(a.machine.SystemID); err != nil {
+		return fmt.Errorf("failed to clear the default gateways: %w", err)
+	}
+
+	for _, o := range a.current.Interfaces {
+		for _, link := range o.Links {
+			link.DefaultGateway = false
+		}
+	}
+
+	for _, d := range desired.Interfaces {
+		o := a.current.networkInterface(d.Name)
+
+		for i, link := range d.Links {
+			if !link.DefaultGateway {
+				continue
+			}
+
+			if _, err := a.client.NetworkInterface.SetDefaultGateway(a.machine.SystemID, o.ID, o.Links[i].ID); err != nil {
+				return fmt.Errorf("failed to set the default gateway of %s %q: %w", o.Kind, o.Name, err)
+			}
+
+			o.Links[i].DefaultGateway = true
+		}
+	}
+
+	return nil
+}
+
+// updateInterface updates an interface of the machine with raw parameters. Unlike
+// NetworkInterface.Update, the settings can be reset to their zero value.
+func (a *machineNetworkApplier) updateInterface(id int, params url.Values) (*entity.NetworkInterface, error) {
+	object, err := nodeObject(a.client, a.machine.SystemID, "interfaces", strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+
+	networkInterface := new(entity.NetworkInterface)
+	err = object.Put(params, func(data []byte) error {
+		return json.Unmarshal(data, networkInterface)
+	})
+
+	return networkInterface, err
+}
+
+// readSettings updates the settings of the interface with the ones reported by MAAS.
+func (o *networkInterface) readSettings(n *entity.NetworkInterface) {
+	o.MACAddress = n.MACAddress
+	o.MTU = n.EffectiveMTU
+	o.Tags = n.Tags
+	o.VLAN = n.VLAN.ID
+	o.Params = map[string]string{}
+
+	p, _ := n.Params.(map[string]any)
+
+	for _, k := range networkInterfaceParams[o.Kind] {
+		if v, ok := p[k]; ok {
+			o.Params[k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// refresh updates the layout with the current network of the machine. The
+// interfaces that no longer exist are removed from the layout.
+func (l *machineNetworkLayout) refresh(client *client.Client, machine *entity.Machine) error {
+	networkInterfaces, err := client.NetworkInterfaces.Get(machine.SystemID)
+	if err != nil {
+		return err
+	}
+
+	l.read(machine, networkInterfaces)
+
+	return nil
+}
+
+// read updates the layout with the given interfaces of the machine.
+func (l *machineNetworkLayout) read(machine *entity.Machine, networkInterfaces []entity.NetworkInterface) {
+	byID := map[int]*entity.NetworkInterface{}
+	for i := range networkInterfaces {
+		byID[networkInterfaces[i].ID] = &networkInterfaces[i]
+	}
+
+	gateways := []int{machine.DefaultGateways.IPv4.LinkID, machine.DefaultGateways.IPv6.LinkID}
+
+	l.Interfaces = slices.DeleteFunc(l.Interfaces, func(o *networkInterface) bool {
+		n, ok := byID[o.ID]
+		if !ok {
+			return true
+		}
+
+		o.Name = n.Name
+		o.Parents = n.Parents
+		o.readSettings(n)
+
+		if o.Kind == networkKindVLAN {
+			o.VID = n.VLAN.VID
+		}
+
+		known := func(id int) int {
+			return slices.IndexFunc(o.Links, func(v *networkLink) bool { return v.ID == id })
+		}
+
+		// Keep the known links in the order of the layout, the other ones show up last
+		links := slices.Clone(n.Links)
+		slices.SortStableFunc(links, func(a, b entity.NetworkInterfaceLink) int {
+			i, j := known(a.ID), known(b.ID)
+			if i < 0 {
+				i = len(o.Links)
+			}
+
+			if j < 0 {
+				j = len(o.Links)
+			}
+
+			return i - j
+		})
+
+		o.Links = nil
+
+		for _, link := range links {
+			refreshed := &networkLink{
+				ID:             link.ID,
+				Subnet:         link.Subnet.CIDR,
+				Mode:           strings.ToUpper(link.Mode),
+				IPAddress:      link.IPAddress,
+				DefaultGateway: slices.Contains(gateways, link.ID),
+			}
+
+			// Keep the subnet as configured, by CIDR or ID, when it did not change
+			if i := known(link.ID); i >= 0 && o.Links[i].Subnet == strconv.Itoa(link.Subnet.ID) {
+				refreshed.Subnet = o.Links[i].Subnet
+			}
+
+			o.Links = append(o.Links, refreshed)
+		}
+
+		return false
+	})
+}
+
+// observeMachineNetworkLayout returns the layout made of the interfaces of the
+// machine with the given names.
+func observeMachineNetworkLayout(machine *entity.Machine, networkInterfaces []entity.NetworkInterface, names []string) *machineNetworkLayout {
+	layout := &machineNetworkLayout{}
+
+	for _, n := range networkInterfaces {
+		if slices.Contains(names, n.Name) && slices.Contains(networkKinds, n.Type) {
+			layout.Interfaces = append(layout.Interfaces, &networkInterface{ID: n.ID, Kind: n.Type, Name: n.Name})
+		}
+	}
+
+	layout.read(machine, networkInterfaces)
+
+	return layout
+}
+
+// inSync returns whether the layout matches the desired one, so that applying it
+// would not change anything.
+func (l *machineNetworkLayout) inSync(desired *machineNetworkLayout) bool {
+	if !slices.Equal(sortedStrings(l.keys()), sortedStrings(desired.keys())) {
+		return false
+	}
+
+	for _, d := range desired.Interfaces {
+		o := l.networkInterface(d.Name)
+		if !reflect.DeepEqual(l.structure(d.Name), desired.structure(d.Name)) || o.settingsChanged(d) || o.linksChanged(d) {
+			return false
+		}
+	}
+
+	return slices.Equal(l.defaultGateways(), desired.defaultGateways())
+}
@@ -0,0 +1,1366 @@
+package maas
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+)
+
+// Storage object kinds of a machine storage layout, listed in dependency order:
+// an object only references objects of a previous kind.
+const (
+	storageKindDisk          = "disk"
+	storageKindPartition     = "partition"
+	storageKindRAID          = "raid"
+	storageKindCacheSet      = "bcache_cache_set"
+	storageKindBCache        = "bcache"
+	storageKindVolumeGroup   = "volume_group"
+	storageKindLogicalVolume = "logical_volume"
+)
+
+// storageFilesystem is the filesystem of a device of a machine storage layout.
+type storageFilesystem struct {
+	FSType       string
+	Label        string
+	MountPoint   string
+	MountOptions string
+}
+
+// storageMemberFSTypes are the filesystem types MAAS gives to the members of a
+// volume group, a RAID or a bcache. They are managed by these devices.
+var storageMemberFSTypes = []string{"lvm-pv", "raid", "raid-spare", "bcache-backing", "bcache-cache"}
+
+// getStorageFSType returns the filesystem type of a device, or an empty string
+// when the device is a member of another one.
+func getStorageFSType(fsType string) string {
+	if slices.Contains(storageMemberFSTypes, fsType) {
+		return ""
+	}
+
+	return fsType
+}
+
+type storagePartition struct {
+	ID            int
+	SizeGigabytes int
+	Bootable      bool
+	Filesystem    storageFilesystem
+}
+
+// storageDisk is a physical disk of the machine, partitioned or used as a whole.
+// Its partitions are referenced as `<name>-part<N>`.
+type storageDisk struct {
+	ID         int
+	Name       string
+	Selector   storageSelector
+	Filesystem storageFilesystem
+	Partitions []*storagePartition
+}
+
+type storageRAID struct {
+	ID           int
+	DeviceID     int
+	Name         string
+	Level        string
+	Devices      []string
+	SpareDevices []string
+	Filesystem   storageFilesystem
+}
+
+type storageCacheSet struct {
+	ID     int
+	Name   string
+	Device string
+}
+
+type storageBCache struct {
+	ID            int
+	DeviceID      int
+	Name          string
+	BackingDevice string
+	CacheSet      string
+	CacheMode     string
+	Filesystem    storageFilesystem
+}
+
+type storageLogicalVolume struct {
+	ID            int
+	Name          string
+	SizeGigabytes int
+	Filesystem    storageFilesystem
+}
+
+type storageVolumeGroup struct {
+	ID             int
+	Name           string
+	Devices        []string
+	LogicalVolumes []*storageLogicalVolume
+}
+
+// machineStorageLayout is the storage layout of a machine, as described by a
+// maas_machine_storage resource. The IDs are only known for existing objects.
+type machineStorageLayout struct {
+	Disks        []*storageDisk
+	RAIDs        []*storageRAID
+	CacheSets    []*storageCacheSet
+	BCaches      []*storageBCache
+	VolumeGroups []*storageVolumeGroup
+}
+
+func storageKey(kind string, name string) string {
+	return kind + "/" + name
+}
+
+func findStorageObject[T any](objects []*T, name func(*T) string, key string) *T {
+	for _, o := range objects {
+		if name(o) == key {
+			return o
+		}
+	}
+
+	return nil
+}
+
+func (l *machineStorageLayout) disk(name string) *storageDisk {
+	return findStorageObject(l.Disks, func(o *storageDisk) string { return o.Name }, name)
+}
+
+func (l *machineStorageLayout) raid(name string) *storageRAID {
+	return findStorageObject(l.RAIDs, func(o *storageRAID) string { return o.Name }, name)
+}
+
+func (l *machineStorageLayout) cacheSet(name string) *storageCacheSet {
+	return findStorageObject(l.CacheSets, func(o *storageCacheSet) string { return o.Name }, name)
+}
+
+func (l *machineStorageLayout) bcache(name string) *storageBCache {
+	return findStorageObject(l.BCaches, func(o *storageBCache) string { return o.Name }, name)
+}
+
+func (l *machineStorageLayout) volumeGroup(name string) *storageVolumeGroup {
+	return findStorageObject(l.VolumeGroups, func(o *storageVolumeGroup) string { return o.Name }, name)
+}
+
+func (vg *storageVolumeGroup) logicalVolume(name string) *storageLogicalVolume {
+	return findStorageObject(vg.LogicalVolumes, func(o *storageLogicalVolume) string { return o.Name }, name)
+}
+
+// storageRef is a device referenced by another object of the layout.
+type storageRef struct {
+	kind      string
+	name      string
+	partition int
+}
+
+// parseRef returns the object referenced by ref, which is the name of a disk, RAID
+// or bcache, or `<disk>-part<N>` for the N-th partition of a disk.
+func (l *machineStorageLayout) parseRef(ref string) (storageRef, error) {
+	if l.disk(ref) != nil {
+		return storageRef{kind: storageKindDisk, name: ref}, nil
+	}
+
+	if l.raid(ref) != nil {
+		return storageRef{kind: storageKindRAID, name: ref}, nil
+	}
+
+	if l.bcache(ref) != nil {
+		return storageRef{kind: storageKindBCache, name: ref}, nil
+	}
+
+	if i := strings.LastIndex(ref, "-part"); i > 0 {
+		disk := l.disk(ref[:i])
+
+		n, err := strconv.Atoi(ref[i+len("-part"):])
+		if disk != nil && err == nil {
+			if n < 1 || n > len(disk.Partitions) {
+				return storageRef{}, fmt.Errorf("disk %q has no partition %d", disk.Name, n)
+			}
+
+			return storageRef{kind: storageKindDisk, name: disk.Name, partition: n}, nil
+		}
+	}
+
+	return storageRef{}, fmt.Errorf("%q is not a disk, partition, RAID or bcache of the layout", ref)
+}
+
+// dependencies returns the keys of the objects the object identified by key depends on.
+func (l *machineStorageLayout) dependencies(key string) []string {
+	var refs []string
+
+	kind, name, _ := strings.Cut(key, "/")
+
+	switch kind {
+	case storageKindPartition:
+		if r, err := l.parseRef(name); err == nil {
+			return []string{storageKey(storageKindDisk, r.name)}
+		}
+	case storageKindRAID:
+		if o := l.raid(name); o != nil {
+			refs = append(slices.Clone(o.Devices), o.SpareDevices...)
+		}
+	case storageKindCacheSet:
+		if o := l.cacheSet(name); o != nil {
+			refs = []string{o.Device}
+		}
+	case storageKindBCache:
+		if o := l.bcache(name); o != nil {
+			refs = []string{o.BackingDevice}
+
+			return append(l.refKeys(refs), storageKey(storageKindCacheSet, o.CacheSet))
+		}
+	case storageKindVolumeGroup:
+		if o := l.volumeGroup(name); o != nil {
+			refs = o.Devices
+		}
+	case storageKindLogicalVolume:
+		vg, _, _ := strings.Cut(name, "/")
+		return []string{storageKey(storageKindVolumeGroup, vg)}
+	}
+
+	return l.refKeys(refs)
+}
+
+func (l *machineStorageLayout) refKeys(refs []string) []string {
+	keys := []string{}
+
+	for _, ref := range refs {
+		r, err := l.parseRef(ref)
+
+		switch {
+		case err != nil:
+			continue
+		case r.partition > 0:
+			keys = append(keys, storageKey(storageKindPartition, ref))
+		default:
+			keys = append(keys, storageKey(r.kind, r.name))
+		}
+	}
+
+	return keys
+}
+
+// keys returns the keys of all the objects of the layout, in dependency order.
+func (l *machineStorageLayout) keys() []string {
+	var keys []string
+
+	for _, o := range l.Disks {
+		keys = append(keys, storageKey(storageKindDisk, o.Name))
+
+		for i := range o.Partitions {
+			keys = append(keys, storageKey(storageKindPartition, partitionName(o.Name, i+1)))
+		}
+	}
+
+	for _, o := range l.RAIDs {
+		keys = append(keys, storageKey(storageKindRAID, o.Name))
+	}
+
+	for _, o := range l.CacheSets {
+		keys = append(keys, storageKey(storageKindCacheSet, o.Name))
+	}
+
+	for _, o := range l.BCaches {
+		keys = append(keys, storageKey(storageKindBCache, o.Name))
+	}
+
+	for _, vg := range l.VolumeGroups {
+		keys = append(keys, storageKey(storageKindVolumeGroup, vg.Name))
+
+		for _, lv := range vg.LogicalVolumes {
+			keys = append(keys, storageKey(storageKindLogicalVolume, vg.Name+"/"+lv.Name))
+		}
+	}
+
+	return keys
+}
+
+// structure returns the part of an object that cannot be changed without
+// recreating it, or nil if the object does not exist.
+func (l *machineStorageLayout) structure(key string) any {
+	kind, name, _ := strings.Cut(key, "/")
+
+	switch kind {
+	case storageKindDisk:
+		if o := l.disk(name); o != nil {
+			return []any{o.Selector.String()}
+		}
+	case storageKindPartition:
+		// A partition is recreated with the ones before it, since they set its offset
+		if r, err := l.parseRef(name); err == nil && r.partition > 0 {
+			o := l.disk(r.name)
+
+			partitions := make([][2]any, r.partition)
+			for i, p := range o.Partitions[:r.partition] {
+				partitions[i] = [2]any{p.SizeGigabytes, p.Bootable}
+			}
+
+			return []any{o.Selector.String(), partitions}
+		}
+	case storageKindRAID:
+		if o := l.raid(name); o != nil {
+			return []any{o.Level, sortedStrings(o.Devices), sortedStrings(o.SpareDevices)}
+		}
+	case storageKindCacheSet:
+		if o := l.cacheSet(name); o != nil {
+			return []any{o.Device}
+		}
+	case storageKindBCache:
+		if o := l.bcache(name); o != nil {
+			return []any{o.BackingDevice, o.CacheSet, o.CacheMode}
+		}
+	case storageKindVolumeGroup:
+		if o := l.volumeGroup(name); o != nil {
+			return []any{sortedStrings(o.Devices)}
+		}
+	case storageKindLogicalVolume:
+		vgName, lvName, _ := strings.Cut(name, "/")
+		if vg := l.volumeGroup(vgName); vg != nil {
+			if o := vg.logicalVolume(lvName); o != nil {
+				return []any{o.SizeGigabytes}
+			}
+		}
+	}
+
+	return nil
+}
+
+// partitionName returns the reference of the n-th partition of a disk.
+func partitionName(disk string, n int) string {
+	return fmt.Sprintf("%s-part%d", disk, n)
+}
+
+func sortedStrings(s []string) []string {
+	s = slices.Clone(s)
+	sort.Strings(s)
+
+	return s
+}
+
+// validate checks that the names are unique and the references are valid.
+func (l *machineStorageLayout) validate() error {
+	names := map[string]bool{}
+
+	unique := func(kind string, name string) error {
+		if names[name] {
+			return fmt.Errorf("%s name %q is duplicated", kind, name)
+		}
+
+		names[name] = true
+
+		return nil
+	}
+
+	checkRefs := func(kind string, name string, refs []string, allowed ...string) error {
+		for _, ref := range refs {
+			r, err := l.parseRef(ref)
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", kind, name, err)
+			}
+
+			if !slices.Contains(allowed, r.kind) {
+				return fmt.Errorf("%s %q: %q cannot be used, only a %s can be used", kind, name, ref, strings.Join(allowed, ", "))
+			}
+		}
+
+		return nil
+	}
+
+	for _, o := range l.Disks {
+		if err := unique("disk", o.Name); err != nil {
+			return err
+		}
+
+		if o.Selector.String() == "" {
+			return fmt.Errorf("disk %q: at least one of id_path, model, serial, wwn, tags, min_size_gigabytes or max_size_gigabytes must be set", o.Name)
+		}
+
+		if len(o.Partitions) > 0 && o.Filesystem.FSType != "" {
+			return fmt.Errorf("disk %q: a disk with partitions cannot be formatted", o.Name)
+		}
+
+		for _, p := range o.Partitions {
+			if p.Filesystem.MountPoint != "" && p.Filesystem.FSType == "" {
+				return fmt.Errorf("disk %q: fs_type must be specified when mount_point is set", o.Name)
+			}
+		}
+	}
+
+	for _, o := range l.RAIDs {
+		if err := unique("RAID", o.Name); err != nil {
+			return err
+		}
+
+		if err := checkRefs("RAID", o.Name, append(slices.Clone(o.Devices), o.SpareDevices...), storageKindDisk); err != nil {
+			return err
+		}
+
+		if err := verifyRAIDDevicesLevel(o.Level, len(o.Devices), len(o.SpareDevices)); err != nil {
+			return fmt.Errorf("RAID %q: %w", o.Name, err)
+		}
+	}
+
+	for _, o := range l.CacheSets {
+		if l.cacheSet(o.Name) != o {
+			return fmt.Errorf("bcache cache set name %q is duplicated", o.Name)
+		}
+
+		if err := checkRefs("bcache cache set", o.Name, []string{o.Device}, storageKindDisk, storageKindRAID); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range l.BCaches {
+		if err := unique("bcache", o.Name); err != nil {
+			return err
+		}
+
+		if err := checkRefs("bcache", o.Name, []string{o.BackingDevice}, storageKindDisk, storageKindRAID); err != nil {
+			return err
+		}
+
+		if l.cacheSet(o.CacheSet) == nil {
+			return fmt.Errorf("bcache %q: %q is not a bcache cache set of the layout", o.Name, o.CacheSet)
+		}
+	}
+
+	for _, o := range l.VolumeGroups {
+		if l.volumeGroup(o.Name) != o {
+			return fmt.Errorf("volume group name %q is duplicated", o.Name)
+		}
+
+		if err := checkRefs("volume group", o.Name, o.Devices, storageKindDisk, storageKindRAID, storageKindBCache); err != nil {
+			return err
+		}
+
+		for _, lv := range o.LogicalVolumes {
+			if o.logicalVolume(lv.Name) != lv {
+				return fmt.Errorf("volume group %q: logical volume name %q is duplicated", o.Name, lv.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortLike orders the objects of the layout like the ones of the given layout.
+func (l *machineStorageLayout) sortLike(o *machineStorageLayout) {
+	sortByName(l.Disks, o.Disks, func(d *storageDisk) string { return d.Name })
+	sortByName(l.RAIDs, o.RAIDs, func(r *storageRAID) string { return r.Name })
+	sortByName(l.CacheSets, o.CacheSets, func(c *storageCacheSet) string { return c.Name })
+	sortByName(l.BCaches, o.BCaches, func(b *storageBCache) string { return b.Name })
+	sortByName(l.VolumeGroups, o.VolumeGroups, func(vg *storageVolumeGroup) string { return vg.Name })
+
+	for _, vg := range l.VolumeGroups {
+		if ovg := o.volumeGroup(vg.Name); ovg != nil {
+			sortByName(vg.LogicalVolumes, ovg.LogicalVolumes, func(lv *storageLogicalVolume) string { return lv.Name })
+		}
+	}
+}
+
+func sortByName[T any](objects []*T, order []*T, name func(*T) string) {
+	index := func(o *T) int {
+		for i, v := range order {
+			if name(v) == name(o) {
+				return i
+			}
+		}
+
+		return len(order)
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		return index(objects[i]) < index(objects[j])
+	})
+}
+
+// machineStorageApplier changes the storage of a machine from the current layout
+// to a desired one. The current layout is kept up to date with every change, so
+// that a failed change can be rolled back.
+type machineStorageApplier struct {
+	client  *client.Client
+	machine *entity.Machine
+	current *machineStorageLayout
+}
+
+func newMachineStorageApplier(client *client.Client, machine *entity.Machine, current *machineStorageLayout) *machineStorageApplier {
+	return &machineStorageApplier{client: client, machine: machine, current: current}
+}
+
+// apply changes the machine storage to the desired layout. The objects that changed,
+// and the ones depending on them, are deleted in reverse dependency order and created
+// again in dependency order. Filesystem changes are applied in place. If a change
+// fails, the previous layout is restored.
+func (a *machineStorageApplier) apply(desired *machineStorageLayout) error {
+	if err := desired.validate(); err != nil {
+		return err
+	}
+
+	previous := a.current.clone()
+
+	err := a.converge(desired)
+	if err == nil {
+		a.current.sortLike(desired)
+		return nil
+	}
+
+	log.Printf("[DEBUG] Machine (%s) rolling back the storage layout: %s\n", a.machine.SystemID, err)
+
+	if rollbackErr := a.converge(previous); rollbackErr != nil {
+		return fmt.Errorf("%w\nAdditionally, the storage layout could not be rolled back: %w", err, rollbackErr)
+	}
+
+	a.current.sortLike(previous)
+
+	return err
+}
+
+// destroy deletes all the objects of the current layout.
+func (a *machineStorageApplier) destroy() error {
+	if err := a.converge(&machineStorageLayout{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *machineStorageApplier) converge(desired *machineStorageLayout) error {
+	// Find the objects to recreate, including the ones depending on them
+	recreate := map[string]bool{}
+
+	for _, key := range a.current.keys() {
+		if !reflect.DeepEqual(a.current.structure(key), desired.structure(key)) {
+			recreate[key] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, key := range desired.keys() {
+			if recreate[key] {
+				continue
+			}
+
+			for _, dep := range desired.dependencies(key) {
+				if recreate[dep] || a.current.structure(dep) == nil {
+					recreate[key] = true
+					changed = true
+
+					break
+				}
+			}
+		}
+	}
+
+	// Release the filesystems changed in place first, so that their mount points can be reused
+	if err := a.updateFilesystems(desired, recreate, true); err != nil {
+		return err
+	}
+
+	keys := a.current.keys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if recreate[keys[i]] {
+			if err := a.delete(keys[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range desired.keys() {
+		if a.current.structure(key) == nil {
+			if err := a.create(desired, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return a.updateFilesystems(desired, recreate, false)
+}
+
+// updateFilesystems applies the filesystem changes of the objects kept in place.
+// When release is set, only the filesystems and mounts to be replaced are removed.
+func (a *machineStorageApplier) updateFilesystems(desired *machineStorageLayout, recreate map[string]bool, release bool) error {
+	update := func(key string, current *storageFilesystem, target storageFilesystem, ops storageFilesystemOps) error {
+		if recreate[key] {
+			return nil
+		}
+
+		if release {
+			target = releasedStorageFilesystem(*current, target)
+		}
+
+		if err := transitionStorageFilesystem(ops, current, target); err != nil {
+			return fmt.Errorf("%s: %w", strings.Replace(key, "/", " ", 1), err)
+		}
+
+		return nil
+	}
+
+	for _, o := range a.current.Disks {
+		d := desired.disk(o.Name)
+		if d == nil {
+			continue
+		}
+
+		key := storageKey(storageKindDisk, o.Name)
+		if err := update(key, &o.Filesystem, d.Filesystem, a.blockDeviceFilesystemOps(o.ID)); err != nil {
+			return err
+		}
+
+		for i, p := range o.Partitions {
+			if i < len(d.Partitions) {
+				key := storageKey(storageKindPartition, partitionName(o.Name, i+1))
+				if err := update(key, &p.Filesystem, d.Partitions[i].Filesystem, a.partitionFilesystemOps(o.ID, p.ID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, o := range a.current.RAIDs {
+		if d := desired.raid(o.Name); d != nil {
+			if err := update(storageKey(storageKindRAID, o.Name), &o.Filesystem, d.Filesystem, a.blockDeviceFilesystemOps(o.DeviceID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, o := range a.current.BCaches {
+		if d := desired.bcache(o.Name); d != nil {
+			if err := update(storageKey(storageKindBCache, o.Name), &o.Filesystem, d.Filesystem, a.blockDeviceFilesystemOps(o.DeviceID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, vg := range a.current.VolumeGroups {
+		dvg := desired.volumeGroup(vg.Name)
+		if dvg == nil {
+			continue
+		}
+
+		for _, o := range vg.LogicalVolumes {
+			if d := dvg.logicalVolume(o.Name); d != nil {
+				if err := update(storageKey(storageKindLogicalVolume, vg.Name+"/"+o.Name), &o.Filesystem, d.Filesystem, a.blockDeviceFilesystemOps(o.ID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the MAAS ID of a referenced device, and whether it is a partition.
+func (a *machineStorageApplier) resolve(ref string) (string, bool, error) {
+	r, err := a.current.parseRef(ref)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch r.kind {
+	case storageKindRAID:
+		return fmt.Sprintf("%d", a.current.raid(r.name).DeviceID), false, nil
+	case storageKindBCache:
+		return fmt.Sprintf("%d", a.current.bcache(r.name).DeviceID), false, nil
+	}
+
+	disk := a.current.disk(r.name)
+	if r.partition > 0 {
+		return fmt.Sprintf("%d", disk.Partitions[r.partition-1].ID), true, nil
+	}
+
+	return fmt.Sprintf("%d", disk.ID), false, nil
+}
+
+// resolveAll returns the MAAS IDs of the referenced block devices and partitions.
+func (a *machineStorageApplier) resolveAll(refs []string) ([]string, []string, error) {
+	var blockDevices, partitions []string
+
+	for _, ref := range refs {
+		id, partition, err := a.resolve(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if partition {
+			partitions = append(partitions, id)
+		} else {
+			blockDevices = append(blockDevices, id)
+		}
+	}
+
+	return blockDevices, partitions, nil
+}
+
+func (a *machineStorageApplier) create(desired *machineStorageLayout, key string) error {
+	kind, name, _ := strings.Cut(key, "/")
+
+	log.Printf("[DEBUG] Machine (%s) creating storage %s %s\n", a.machine.SystemID, kind, name)
+
+	var err error
+
+	switch kind {
+	case storageKindDisk:
+		err = a.createDisk(desired.disk(name))
+	case storageKindPartition:
+		err = a.createPartition(desired, name)
+	case storageKindRAID:
+		err = a.createRAID(desired.raid(name))
+	case storageKindCacheSet:
+		err = a.createCacheSet(desired.cacheSet(name))
+	case storageKindBCache:
+		err = a.createBCache(desired.bcache(name))
+	case storageKindVolumeGroup:
+		err = a.createVolumeGroup(desired.volumeGroup(name))
+	case storageKindLogicalVolume:
+		vgName, lvName, _ := strings.Cut(name, "/")
+		err = a.createLogicalVolume(a.current.volumeGroup(vgName), desired.volumeGroup(vgName).logicalVolume(lvName))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create %s %q: %w", strings.ReplaceAll(kind, "_", " "), name, err)
+	}
+
+	return nil
+}
+
+func (a *machineStorageApplier) delete(key string) error {
+	kind, name, _ := strings.Cut(key, "/")
+
+	log.Printf("[DEBUG] Machine (%s) deleting storage %s %s\n", a.machine.SystemID, kind, name)
+
+	var err error
+
+	switch kind {
+	case storageKindDisk:
+		err = a.deleteDisk(a.current.disk(name))
+	case storageKindPartition:
+		r, _ := a.current.parseRef(name)
+		disk := a.current.disk(r.name)
+
+		o := disk.Partitions[r.partition-1]
+		if err = a.client.BlockDevicePartition.Delete(a.machine.SystemID, disk.ID, o.ID); err == nil {
+			disk.Partitions = slices.DeleteFunc(disk.Partitions, func(v *storagePartition) bool { return v == o })
+		}
+	case storageKindRAID:
+		o := a.current.raid(name)
+		if err = a.client.RAID.Delete(a.machine.SystemID, o.ID); err == nil {
+			a.current.RAIDs = slices.DeleteFunc(a.current.RAIDs, func(v *storageRAID) bool { return v == o })
+		}
+	case storageKindCacheSet:
+		o := a.current.cacheSet(name)
+		if err = a.client.BCacheCacheSet.Delete(a.machine.SystemID, o.ID); err == nil {
+			a.current.CacheSets = slices.DeleteFunc(a.current.CacheSets, func(v *storageCacheSet) bool { return v == o })
+		}
+	case storageKindBCache:
+		o := a.current.bcache(name)
+		if err = a.client.BCache.Delete(a.machine.SystemID, o.ID); err == nil {
+			a.current.BCaches = slices.DeleteFunc(a.current.BCaches, func(v *storageBCache) bool { return v == o })
+		}
+	case storageKindVolumeGroup:
+		o := a.current.volumeGroup(name)
+		if err = a.client.VolumeGroup.Delete(a.machine.SystemID, o.ID); err == nil {
+			a.current.VolumeGroups = slices.DeleteFunc(a.current.VolumeGroups, func(v *storageVolumeGroup) bool { return v == o })
+		}
+	case storageKindLogicalVolume:
+		vgName, lvName, _ := strings.Cut(name, "/")
+		vg := a.current.volumeGroup(vgName)
+
+		o := vg.logicalVolume(lvName)
+		if err = a.client.VolumeGroup.DeleteLogicalVolume(a.machine.SystemID, vg.ID, o.ID); err == nil {
+			vg.LogicalVolumes = slices.DeleteFunc(vg.LogicalVolumes, func(v *storageLogicalVolume) bool { return v == o })
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %q: %w", strings.ReplaceAll(kind, "_", " "), name, err)
+	}
+
+	return nil
+}
+
+// findMachineDisk returns the physical disk of the machine matching the selector.
+func (a *machineStorageApplier) findMachineDisk(selector storageSelector) (*entity.BlockDevice, error) {
+	id, err := selectStorageDevice(a.machine.PhysicalBlockDeviceSet, selector)
+	if err != nil {
+		return nil, fmt.Errorf("machine (%s): %w", a.machine.SystemID, err)
+	}
+
+	i := slices.IndexFunc(a.machine.PhysicalBlockDeviceSet, func(b entity.BlockDevice) bool { return fmt.Sprintf("%v", b.ID) == id })
+
+	return &a.machine.PhysicalBlockDeviceSet[i], nil
+}
+
+// createDisk takes over a disk of the machine. Its partitions are created separately.
+func (a *machineStorageApplier) createDisk(o *storageDisk) error {
+	blockDevice, err := a.findMachineDisk(o.Selector)
+	if err != nil {
+		return err
+	}
+
+	disk := &storageDisk{ID: blockDevice.ID, Name: o.Name, Selector: o.Selector}
+
+	// The whole disk is managed by the layout, clear what is already on it
+	if err := a.clearDisk(disk.ID); err != nil {
+		return err
+	}
+
+	a.current.Disks = append(a.current.Disks, disk)
+
+	return transitionStorageFilesystem(a.blockDeviceFilesystemOps(disk.ID), &disk.Filesystem, o.Filesystem)
+}
+
+// createPartition creates the partition with the given reference at the end of its
+// disk, after the partitions kept in place.
+func (a *machineStorageApplier) createPartition(desired *machineStorageLayout, name string) error {
+	r, err := desired.parseRef(name)
+	if err != nil {
+		return err
+	}
+
+	disk := a.current.disk(r.name)
+	if len(disk.Partitions) != r.partition-1 {
+		return fmt.Errorf("disk %q has %d partitions, %d expected", disk.Name, len(disk.Partitions), r.partition-1)
+	}
+
+	p := desired.disk(r.name).Partitions[r.partition-1]
+
+	partition, err := a.client.BlockDevicePartitions.Create(a.machine.SystemID, disk.ID, &entity.BlockDevicePartitionParams{
+		Size:     int64(p.SizeGigabytes) * GigaBytes,
+		Bootable: p.Bootable,
+	})
+	if err != nil {
+		return err
+	}
+
+	created := &storagePartition{ID: partition.ID, SizeGigabytes: p.SizeGigabytes, Bootable: p.Bootable}
+	disk.Partitions = append(disk.Partitions, created)
+
+	return transitionStorageFilesystem(a.partitionFilesystemOps(disk.ID, created.ID), &created.Filesystem, p.Filesystem)
+}
+
+func (a *machineStorageApplier) deleteDisk(o *storageDisk) error {
+	if err := a.clearDisk(o.ID); err != nil {
+		return err
+	}
+
+	a.current.Disks = slices.DeleteFunc(a.current.Disks, func(v *storageDisk) bool { return v == o })
+
+	return nil
+}
+
+// clearDisk removes the partitions and the filesystem of a disk. The disks used by
+// a RAID, a bcache or a volume group are refused: the ones of the layout are
+// deleted first, so these are used outside of it.
+func (a *machineStorageApplier) clearDisk(id int) error {
+	blockDevice, err := a.client.BlockDevice.Get(a.machine.SystemID, id)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(storageMemberFSTypes, blockDevice.Filesystem.FSType) {
+		return fmt.Errorf("disk %q is used outside of the layout (%s)", blockDevice.Name, blockDevice.Filesystem.FSType)
+	}
+
+	for _, p := range blockDevice.Partitions {
+		if slices.Contains(storageMemberFSTypes, p.FileSystem.FSType) {
+			return fmt.Errorf("partition %q of disk %q is used outside of the layout (%s)", p.Path, blockDevice.Name, p.FileSystem.FSType)
+		}
+	}
+
+	// Delete the last partitions first, since MAAS renumbers the following partitions
+	sort.Slice(blockDevice.Partitions, func(i, j int) bool {
+		return blockDevice.Partitions[i].ID > blockDevice.Partitions[j].ID
+	})
+
+	for _, p := range blockDevice.Partitions {
+		if err := a.client.BlockDevicePartition.Delete(a.machine.SystemID, id, p.ID); err != nil {
+			return err
+		}
+	}
+
+	fs := storageFilesystem{
+		FSType:     blockDevice.Filesystem.FSType,
+		MountPoint: blockDevice.Filesystem.MountPoint,
+	}
+
+	return transitionStorageFilesystem(a.blockDeviceFilesystemOps(id), &fs, storageFilesystem{})
+}
+
+func (a *machineStorageApplier) createRAID(o *storageRAID) error {
+	blockDevices, partitions, err := a.resolveAll(o.Devices)
+	if err != nil {
+		return err
+	}
+
+	spareDevices, sparePartitions, err := a.resolveAll(o.SpareDevices)
+	if err != nil {
+		return err
+	}
+
+	raid, err := a.client.RAIDs.Create(a.machine.SystemID, &entity.RAIDCreateParams{
+		Name:            o.Name,
+		Level:           fmt.Sprintf("raid-%s", o.Level),
+		BlockDevices:    blockDevices,
+		Partitions:      partitions,
+		SpareDevices:    spareDevices,
+		SparePartitions: sparePartitions,
+	})
+	if err != nil {
+		return err
+	}
+
+	created := &storageRAID{
+		ID:           raid.ID,
+		DeviceID:     raid.VirtualDevice.ID,
+		Name:         o.Name,
+		Level:        o.Level,
+		Devices:      o.Devices,
+		SpareDevices: o.SpareDevices,
+	}
+	a.current.RAIDs = append(a.current.RAIDs, created)
+
+	return transitionStorageFilesystem(a.blockDeviceFilesystemOps(created.DeviceID), &created.Filesystem, o.Filesystem)
+}
+
+func (a *machineStorageApplier) createCacheSet(o *storageCacheSet) error {
+	id, partition, err := a.resolve(o.Device)
+	if err != nil {
+		return err
+	}
+
+	params := &entity.BCacheCacheSetParams{CacheDevice: id}
+	if partition {
+		params = &entity.BCacheCacheSetParams{CachePartition: id}
+	}
+
+	cacheSet, err := a.client.BCacheCacheSets.Create(a.machine.SystemID, params)
+	if err != nil {
+		return err
+	}
+
+	a.current.CacheSets = append(a.current.CacheSets, &storageCacheSet{ID: cacheSet.ID, Name: o.Name, Device: o.Device})
+
+	return nil
+}
+
+func (a *machineStorageApplier) createBCache(o *storageBCache) error {
+	id, partition, err := a.resolve(o.BackingDevice)
+	if err != nil {
+		return err
+	}
+
+	params := &entity.BCacheParams{
+		Name:      o.Name,
+		CacheSet:  fmt.Sprintf("%d", a.current.cacheSet(o.CacheSet).ID),
+		CacheMode: o.CacheMode,
+	}
+	if partition {
+		params.BackingPartition = id
+	} else {
+		params.BackingDevice = id
+	}
+
+	bcache, err := a.client.BCaches.Create(a.machine.SystemID, params)
+	if err != nil {
+		return err
+	}
+
+	created := &storageBCache{
+		ID:            bcache.ID,
+		DeviceID:      bcache.VirtualDevice.ID,
+		Name:          o.Name,
+		BackingDevice: o.BackingDevice,
+		CacheSet:      o.CacheSet,
+		CacheMode:     o.CacheMode,
+	}
+	a.current.BCaches = append(a.current.BCaches, created)
+
+	return transitionStorageFilesystem(a.blockDeviceFilesystemOps(created.DeviceID), &created.Filesystem, o.Filesystem)
+}
+
+func (a *machineStorageApplier) createVolumeGroup(o *storageVolumeGroup) error {
+	blockDevices, partitions, err := a.resolveAll(o.Devices)
+	if err != nil {
+		return err
+	}
+
+	volumeGroup, err := a.client.VolumeGroups.Create(a.machine.SystemID, &entity.VolumeGroupCreateParams{
+		Name:         o.Name,
+		BlockDevices: blockDevices,
+		Partitions:   partitions,
+	})
+	if err != nil {
+		return err
+	}
+
+	a.current.VolumeGroups = append(a.current.VolumeGroups, &storageVolumeGroup{ID: volumeGroup.ID, Name: o.Name, Devices: o.Devices})
+
+	return nil
+}
+
+func (a *machineStorageApplier) createLogicalVolume(vg *storageVolumeGroup, o *storageLogicalVolume) error {
+	logicalVolume, err := a.client.VolumeGroup.CreateLogicalVolume(a.machine.SystemID, vg.ID, &entity.LogicalVolumeParams{
+		Name: o.Name,
+		Size: int64(o.SizeGigabytes) * GigaBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	created := &storageLogicalVolume{ID: logicalVolume.ID, Name: o.Name, SizeGigabytes: o.SizeGigabytes}
+	vg.LogicalVolumes = append(vg.LogicalVolumes, created)
+
+	return transitionStorageFilesystem(a.blockDeviceFilesystemOps(created.ID), &created.Filesystem, o.Filesystem)
+}
+
+// storageFilesystemOps formats and mounts a block device or a partition.
+type storageFilesystemOps struct {
+	format   func(fs storageFilesystem) error
+	unformat func() error
+	mount    func(fs storageFilesystem) error
+	unmount  func() error
+}
+
+func (a *machineStorageApplier) blockDeviceFilesystemOps(id int) storageFilesystemOps {
+	return blockDeviceFilesystemOps(a.client, a.machine.SystemID, id)
+}
+
+func (a *machineStorageApplier) partitionFilesystemOps(blockDeviceID int, id int) storageFilesystemOps {
+	return partitionFilesystemOps(a.client, a.machine.SystemID, blockDeviceID, id)
+}
+
+func blockDeviceFilesystemOps(client *client.Client, systemID string, id int) storageFilesystemOps {
+	return storageFilesystemOps{
+		format: func(fs storageFilesystem) error {
+			_, err := client.BlockDevice.Format(systemID, id, fs.FSType)
+			return err
+		},
+		unformat: func() error {
+			_, err := client.BlockDevice.Unformat(systemID, id)
+			return err
+		},
+		mount: func(fs storageFilesystem) error {
+			_, err := client.BlockDevice.Mount(systemID, id, fs.MountPoint, fs.MountOptions)
+			return err
+		},
+		unmount: func() error {
+			_, err := client.BlockDevice.Unmount(systemID, id)
+			return err
+		},
+	}
+}
+
+func partitionFilesystemOps(client *client.Client, systemID string, blockDeviceID int, id int) storageFilesystemOps {
+	return storageFilesystemOps{
+		format: func(fs storageFilesystem) error {
+			_, err := client.BlockDevicePartition.Format(systemID, blockDeviceID, id, fs.FSType, fs.Label)
+			return err
+		},
+		unformat: func() error {
+			_, err := client.BlockDevicePartition.Unformat(systemID, blockDeviceID, id)
+			return err
+		},
+		mount: func(fs storageFilesystem) error {
+			_, err := client.BlockDevicePartition.Mount(systemID, blockDeviceID, id, fs.MountPoint, fs.MountOptions)
+			return err
+		},
+		unmount: func() error {
+			_, err := client.BlockDevicePartition.Unmount(systemID, blockDeviceID, id)
+			return err
+		},
+	}
+}
+
+// releasedStorageFilesystem returns the filesystem without the format and mount that
+// must be removed to reach the target.
+func releasedStorageFilesystem(current storageFilesystem, target storageFilesystem) storageFilesystem {
+	fsChanged := current.FSType != target.FSType || current.Label != target.Label
+	if fsChanged || current.MountPoint != target.MountPoint || current.MountOptions != target.MountOptions {
+		current.MountPoint = ""
+		current.MountOptions = ""
+	}
+
+	if fsChanged {
+		current.FSType = ""
+		current.Label = ""
+	}
+
+	return current
+}
+
+// transitionStorageFilesystem unmounts, unformats, formats and mounts a device to
+// reach the target filesystem, keeping current up to date with every change.
+func transitionStorageFilesystem(ops storageFilesystemOps, current *storageFilesystem, target storageFilesystem) error {
+	fsChanged := current.FSType != target.FSType || current.Label != target.Label
+	mountChanged := fsChanged || current.MountPoint != target.MountPoint || current.MountOptions != target.MountOptions
+
+	if mountChanged && current.MountPoint != "" {
+		if err := ops.unmount(); err != nil {
+			return fmt.Errorf("failed to unmount: %w", err)
+		}
+
+		current.MountPoint = ""
+		current.MountOptions = ""
+	}
+
+	if fsChanged && current.FSType != "" {
+		if err := ops.unformat(); err != nil {
+			return fmt.Errorf("failed to unformat: %w", err)
+		}
+
+		current.FSType = ""
+		current.Label = ""
+	}
+
+	if fsChanged && target.FSType != "" {
+		if err := ops.format(target); err != nil {
+			return fmt.Errorf("failed to format: %w", err)
+		}
+
+		current.FSType = target.FSType
+		current.Label = target.Label
+	}
+
+	if mountChanged && target.MountPoint != "" {
+		if err := ops.mount(target); err != nil {
+			return fmt.Errorf("failed to mount: %w", err)
+		}
+
+		current.MountPoint = target.MountPoint
+		current.MountOptions = target.MountOptions
+	}
+
+	return nil
+}
+
+func (l *machineStorageLayout) clone() *machineStorageLayout {
+	c := &machineStorageLayout{}
+
+	for _, o := range l.Disks {
+		disk := *o
+		disk.Partitions = nil
+
+		for _, p := range o.Partitions {
+			partition := *p
+			disk.Partitions = append(disk.Partitions, &partition)
+		}
+
+		c.Disks = append(c.Disks, &disk)
+	}
+
+	for _, o := range l.RAIDs {
+		raid := *o
+		c.RAIDs = append(c.RAIDs, &raid)
+	}
+
+	for _, o := range l.CacheSets {
+		cacheSet := *o
+		c.CacheSets = append(c.CacheSets, &cacheSet)
+	}
+
+	for _, o := range l.BCaches {
+		bcache := *o
+		c.BCaches = append(c.BCaches, &bcache)
+	}
+
+	for _, o := range l.VolumeGroups {
+		vg := *o
+		vg.LogicalVolumes = nil
+
+		for _, lv := range o.LogicalVolumes {
+			logicalVolume := *lv
+			vg.LogicalVolumes = append(vg.LogicalVolumes, &logicalVolume)
+		}
+
+		c.VolumeGroups = append(c.VolumeGroups, &vg)
+	}
+
+	return c
+}
+
+// refresh updates the layout with the current storage of the machine. The objects
+// that no longer exist are removed from the layout.
+func (l *machineStorageLayout) refresh(client *client.Client, systemID string) error {
+	refs := map[string]string{}
+
+	for _, o := range l.Disks {
+		refs[fmt.Sprintf("device/%d", o.ID)] = o.Name
+
+		for i, p := range o.Partitions {
+			refs[fmt.Sprintf("partition/%d", p.ID)] = fmt.Sprintf("%s-part%d", o.Name, i+1)
+		}
+	}
+
+	// Unknown devices are named after MAAS, so that they show up in the plan
+	ref := func(kind string, id int, name string) string {
+		if r, ok := refs[fmt.Sprintf("%s/%d", kind, id)]; ok {
+			return r
+		}
+
+		return name
+	}
+
+	var errs []error
+
+	l.Disks = slices.DeleteFunc(l.Disks, func(o *storageDisk) bool {
+		blockDevice, err := client.BlockDevice.Get(systemID, o.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return isNotFoundError(err)
+		}
+
+		sort.Slice(blockDevice.Partitions, func(i, j int) bool {
+			return blockDevice.Partitions[i].ID < blockDevice.Partitions[j].ID
+		})
+
+		o.Filesystem = storageFilesystem{
+			FSType:       getStorageFSType(blockDevice.Filesystem.FSType),
+			MountPoint:   blockDevice.Filesystem.MountPoint,
+			MountOptions: blockDevice.Filesystem.MountOptions,
+		}
+		o.Partitions = nil
+
+		for _, p := range blockDevice.Partitions {
+			o.Partitions = append(o.Partitions, &storagePartition{
+				ID:            p.ID,
+				SizeGigabytes: int(float64(p.Size)/GigaBytes + 0.5),
+				Bootable:      p.Bootable,
+				Filesystem: storageFilesystem{
+					FSType:       getStorageFSType(p.FileSystem.FSType),
+					Label:        p.FileSystem.Label,
+					MountPoint:   p.FileSystem.MountPoint,
+					MountOptions: p.FileSystem.MountOptions,
+				},
+			})
+		}
+
+		return false
+	})
+
+	l.RAIDs = slices.DeleteFunc(l.RAIDs, func(o *storageRAID) bool {
+		raid, err := client.RAID.Get(systemID, o.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return isNotFoundError(err)
+		}
+
+		o.DeviceID = raid.VirtualDevice.ID
+		refs[fmt.Sprintf("device/%d", o.DeviceID)] = o.Name
+
+		o.Level = strings.TrimPrefix(raid.Level, "raid-")
+		o.Devices = raidDeviceRefs(raid.Devices, ref)
+		o.SpareDevices = raidDeviceRefs(raid.SpareDevices, ref)
+		o.Filesystem = storageFilesystem{
+			FSType:       getStorageFSType(raid.VirtualDevice.Filesystem.FSType),
+			MountPoint:   raid.VirtualDevice.Filesystem.MountPoint,
+			MountOptions: raid.VirtualDevice.Filesystem.MountOptions,
+		}
+
+		return false
+	})
+
+	l.CacheSets = slices.DeleteFunc(l.CacheSets, func(o *storageCacheSet) bool {
+		_, err := client.BCacheCacheSet.Get(systemID, o.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return isNotFoundError(err)
+		}
+
+		return false
+	})
+
+	l.BCaches = slices.DeleteFunc(l.BCaches, func(o *storageBCache) bool {
+		bcache, err := client.BCache.Get(systemID, o.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return isNotFoundError(err)
+		}
+
+		o.DeviceID = bcache.VirtualDevice.ID
+		refs[fmt.Sprintf("device/%d", o.DeviceID)] = o.Name
+
+		o.CacheMode = strings.ToLower(bcache.CacheMode)
+		o.Filesystem = storageFilesystem{
+			FSType:       getStorageFSType(bcache.VirtualDevice.Filesystem.FSType),
+			MountPoint:   bcache.VirtualDevice.Filesystem.MountPoint,
+			MountOptions: bcache.VirtualDevice.Filesystem.MountOptions,
+		}
+
+		return false
+	})
+
+	l.VolumeGroups = slices.DeleteFunc(l.VolumeGroups, func(o *storageVolumeGroup) bool {
+		volumeGroup, err := client.VolumeGroup.Get(systemID, o.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return isNotFoundError(err)
+		}
+
+		blockDevices, partitions := findVolumeGroupDevices(volumeGroup)
+
+		o.Devices = nil
+
+		for _, id := range blockDevices {
+			n, _ := strconv.Atoi(id)
+			o.Devices = append(o.Devices, ref("device", n, id))
+		}
+
+		for _, id := range partitions {
+			n, _ := strconv.Atoi(id)
+			o.Devices = append(o.Devices, ref("partition", n, id))
+		}
+
+		logicalVolumes := map[int]entity.VirtualBlockDevice{}
+		for _, lv := range volumeGroup.LogicalVolumes {
+			logicalVolumes[lv.ID] = lv
+		}
+
+		o.LogicalVolumes = slices.DeleteFunc(o.LogicalVolumes, func(lv *storageLogicalVolume) bool {
+			logicalVolume, ok := logicalVolumes[lv.ID]
+			if !ok {
+				return true
+			}
+
+			lv.SizeGigabytes = int(float64(logicalVolume.Size)/GigaBytes + 0.5)
+			lv.Filesystem = storageFilesystem{
+				FSType:       logicalVolume.Filesystem.FSType,
+				MountPoint:   logicalVolume.Filesystem.MountPoint,
+				MountOptions: logicalVolume.Filesystem.MountOptions,
+			}
+
+			return false
+		})
+
+		return false
+	})
+
+	// Missing objects were removed, report the other errors
+	errs = slices.DeleteFunc(errs, isNotFoundError)
+
+	return errors.Join(errs...)
+}
+
+func raidDeviceRefs(devices []entity.RAIDDevice, ref func(kind string, id int, name string) string) []string {
+	refs := []string{}
+
+	for _, device := range devices {
+		kind := "device"
+		if device.Type == "partition" {
+			kind = "partition"
+		}
+
+		refs = append(refs, ref(kind, device.ID, device.Name))
+	}
+
+	return refs
+}
@@ -0,0 +1,104 @@
+package maas
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMachineStorageServer serves the given MAAS objects, by path under the API root.
+func testMachineStorageServer(t *testing.T, objects map[string]any) *client.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, path, _ := strings.Cut(r.URL.Path, "/api/2.0/")
+
+		object, ok := objects[strings.TrimSuffix(path, "/")]
+		if !ok {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(object))
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := client.GetClient(server.URL+"/MAAS/", "consumer:token:secret", "2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestMachineStorageLayoutRefreshMembers(t *testing.T) {
+	c := testMachineStorageServer(t, map[string]any{
+		"nodes/abc123/blockdevices/1": entity.BlockDevice{
+			ID:   1,
+			Name: "sda",
+			Partitions: []entity.BlockDevicePartition{
+				{ID: 12, Size: 20 * GigaBytes, FileSystem: entity.PartitionFileSystem{FSType: "raid"}},
+				{ID: 11, Size: GigaBytes, Bootable: true, FileSystem: entity.PartitionFileSystem{FSType: "fat32", MountPoint: "/boot/efi"}},
+			},
+		},
+		"nodes/abc123/blockdevices/2": entity.BlockDevice{
+			ID:   2,
+			Name: "sdb",
+			Partitions: []entity.BlockDevicePartition{
+				{ID: 21, Size: 20 * GigaBytes, FileSystem: entity.PartitionFileSystem{FSType: "raid"}},
+			},
+		},
+		"nodes/abc123/blockdevices/3": entity.BlockDevice{
+			ID:         3,
+			Name:       "sdc",
+			Filesystem: entity.PartitionFileSystem{FSType: "lvm-pv"},
+		},
+		"nodes/abc123/raid/5": entity.RAID{
+			ID:    5,
+			Level: "raid-1",
+			Devices: []entity.RAIDDevice{
+				{ID: 12, Type: "partition"},
+				{ID: 21, Type: "partition"},
+			},
+			VirtualDevice: entity.BlockDevice{ID: 50, Filesystem: entity.PartitionFileSystem{FSType: "lvm-pv"}},
+		},
+		"nodes/abc123/volume-groups/7": entity.VolumeGroup{
+			ID:      7,
+			Devices: []map[string]any{{"id": 50}, {"id": 3}},
+			LogicalVolumes: []entity.VirtualBlockDevice{
+				{BlockDevice: entity.BlockDevice{ID: 70, Size: 30 * GigaBytes, Filesystem: entity.PartitionFileSystem{FSType: "ext4", MountPoint: "/"}}},
+			},
+		},
+	})
+
+	layout := &machineStorageLayout{
+		Disks: []*storageDisk{
+			{ID: 1, Name: "a", Partitions: []*storagePartition{{ID: 11}, {ID: 12}}},
+			{ID: 2, Name: "b", Partitions: []*storagePartition{{ID: 21}}},
+			{ID: 3, Name: "c"},
+		},
+		RAIDs: []*storageRAID{{ID: 5, Name: "md0"}},
+		VolumeGroups: []*storageVolumeGroup{
+			{ID: 7, Name: "vg0", LogicalVolumes: []*storageLogicalVolume{{ID: 70, Name: "root"}}},
+		},
+	}
+
+	assert.NoError(t, layout.refresh(c, "abc123"))
+
+	// The filesystems of the members are managed by MAAS, they read back empty
+	assert.Equal(t, storageFilesystem{FSType: "fat32", MountPoint: "/boot/efi"}, layout.Disks[0].Partitions[0].Filesystem)
+	assert.Equal(t, storageFilesystem{}, layout.Disks[0].Partitions[1].Filesystem)
+	assert.Equal(t, storageFilesystem{}, layout.Disks[1].Partitions[0].Filesystem)
+	assert.Equal(t, storageFilesystem{}, layout.Disks[2].Filesystem)
+	assert.Equal(t, storageFilesystem{}, layout.RAIDs[0].Filesystem)
+	assert.Equal(t, storageFilesystem{FSType: "ext4", MountPoint: "/"}, layout.VolumeGroups[0].LogicalVolumes[0].Filesystem)
+
+	assert.Equal(t, "1", layout.RAIDs[0].Level)
+	assert.Equal(t, []string{"a-part2", "b-part1"}, layout.RAIDs[0].Devices)
+	assert.ElementsMatch(t, []string{"md0", "c"}, layout.VolumeGroups[0].Devices)
+}
@@ -0,0 +1,242 @@
+package maas
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/canonical/gomaasclient/entity"
+)
+
+// networkTemplate is the interface layout described by a maas_network_template
+// resource, applied to machines by maas_network_template_attachment resources.
+// Bonds, VLANs and bridges reference the roles or the other interfaces by name.
+type networkTemplate struct {
+	Roles   []*networkTemplateRole      `json:"roles"`
+	Bonds   []*networkTemplateInterface `json:"bonds,omitempty"`
+	VLANs   []*networkTemplateInterface `json:"vlans,omitempty"`
+	Bridges []*networkTemplateInterface `json:"bridges,omitempty"`
+}
+
+// networkTemplateRole matches physical interfaces of a machine. The criteria that
+// are set must all match.
+type networkTemplateRole struct {
+	Name        string                 `json:"name"`
+	NamePattern string                 `json:"name_pattern,omitempty"`
+	Vendor      string                 `json:"vendor,omitempty"`
+	Product     string                 `json:"product,omitempty"`
+	LinkSpeed   int                    `json:"link_speed,omitempty"`
+	Count       int                    `json:"count"`
+	MTU         int                    `json:"mtu,omitempty"`
+	Links       []*networkTemplateLink `json:"links,omitempty"`
+}
+
+type networkTemplateInterface struct {
+	Name    string                 `json:"name,omitempty"`
+	Parents []string               `json:"parents"`
+	VID     int                    `json:"vid,omitempty"`
+	MTU     int                    `json:"mtu,omitempty"`
+	Params  map[string]string      `json:"params,omitempty"`
+	Links   []*networkTemplateLink `json:"links,omitempty"`
+}
+
+// networkTemplateLink is the IP assignment policy of an interface on a subnet.
+type networkTemplateLink struct {
+	Subnet         string `json:"subnet"`
+	Mode           string `json:"mode"`
+	DefaultGateway bool   `json:"default_gateway,omitempty"`
+}
+
+func parseNetworkTemplate(content string) (*networkTemplate, error) {
+	t := &networkTemplate{}
+	if err := json.Unmarshal([]byte(content), t); err != nil {
+		return nil, fmt.Errorf("unable to parse network template: %w", err)
+	}
+
+	return t, nil
+}
+
+func (r *networkTemplateRole) matches(n *entity.NetworkInterface) (bool, error) {
+	if n.Type != networkKindPhysical {
+		return false, nil
+	}
+
+	if r.NamePattern != "" {
+		matched, err := regexp.MatchString(r.NamePattern, n.Name)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	switch {
+	case r.Vendor != "" && !strings.EqualFold(r.Vendor, n.Vendor):
+		return false, nil
+	case r.Product != "" && !strings.EqualFold(r.Product, n.Product):
+		return false, nil
+	case r.LinkSpeed != 0 && r.LinkSpeed != n.LinkSpeed:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// layout returns the network layout of a machine with the given interfaces. Each
+// physical interface is assigned to the first role it matches, and each role must
+// match the expected number of interfaces.
+func (t *networkTemplate) layout(networkInterfaces []entity.NetworkInterface) (*machineNetworkLayout, error) {
+	layout := &machineNetworkLayout{}
+	assigned := map[string][]string{}
+
+	for i := range networkInterfaces {
+		n := &networkInterfaces[i]
+
+		for _, r := range t.Roles {
+			matched, err := r.matches(n)
+			if err != nil {
+				return nil, fmt.Errorf("role %q: %w", r.Name, err)
+			}
+
+			if !matched {
+				continue
+			}
+
+			assigned[r.Name] = append(assigned[r.Name], n.Name)
+			layout.Interfaces = append(layout.Interfaces, &networkInterface{
+				Kind:       networkKindPhysical,
+				Name:       n.Name,
+				MACAddress: n.MACAddress,
+				MTU:        r.MTU,
+				Tags:       n.Tags,
+				Params:     map[string]string{},
+				Links:      expandNetworkTemplateLinks(r.Links),
+			})
+
+			break
+		}
+	}
+
+	for _, r := range t.Roles {
+		if len(assigned[r.Name]) != r.Count {
+			return nil, fmt.Errorf("role %q matches %d interfaces (%s), %d expected", r.Name, len(assigned[r.Name]), strings.Join(assigned[r.Name], ", "), r.Count)
+		}
+	}
+
+	// The roles stand for the interfaces they match
+	parents := func(kind string, o *networkTemplateInterface) ([]string, error) {
+		var names []string
+
+		for _, p := range o.Parents {
+			if matched, ok := assigned[p]; ok {
+				names = append(names, sortedStrings(matched)...)
+			} else {
+				names = append(names, p)
+			}
+		}
+
+		if kind != networkKindBond && len(names) != 1 {
+			name := o.Name
+			if kind == networkKindVLAN {
+				name = fmt.Sprintf("%s.%d", o.Parents[0], o.VID)
+			}
+
+			return nil, fmt.Errorf("%s %q: the parent must be a single interface, %q matches %d interfaces", kind, name, o.Parents[0], len(names))
+		}
+
+		return names, nil
+	}
+
+	for _, kind := range networkKinds[1:] {
+		for _, o := range map[string][]*networkTemplateInterface{
+			networkKindBond:   t.Bonds,
+			networkKindVLAN:   t.VLANs,
+			networkKindBridge: t.Bridges,
+		}[kind] {
+			names, err := parents(kind, o)
+			if err != nil {
+				return nil, err
+			}
+
+			n := &networkInterface{
+				Kind:    kind,
+				Name:    o.Name,
+				Parents: names,
+				VID:     o.VID,
+				MTU:     o.MTU,
+				Params:  o.Params,
+				Links:   expandNetworkTemplateLinks(o.Links),
+			}
+
+			if kind == networkKindVLAN {
+				n.Name = fmt.Sprintf("%s.%d", names[0], o.VID)
+			}
+
+			layout.Interfaces = append(layout.Interfaces, n)
+		}
+	}
+
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+
+	return layout, nil
+}
+
+// names returns the names of the interfaces of the template on a machine with the
+// given interfaces, or nil if the template does not apply to the machine.
+func (t *networkTemplate) names(networkInterfaces []entity.NetworkInterface) []string {
+	layout, err := t.layout(networkInterfaces)
+	if err != nil {
+		return nil
+	}
+
+	return layout.keys()
+}
+
+// validate checks the template against placeholder interfaces matching its roles.
+func (t *networkTemplate) validate() error {
+	placeholder := &networkTemplate{Bonds: t.Bonds, VLANs: t.VLANs, Bridges: t.Bridges}
+
+	var networkInterfaces []entity.NetworkInterface
+
+	for _, r := range t.Roles {
+		if _, err := regexp.Compile(r.NamePattern); err != nil {
+			return fmt.Errorf("role %q: %w", r.Name, err)
+		}
+
+		if slices.ContainsFunc(placeholder.Roles, func(v *networkTemplateRole) bool { return v.Name == r.Name }) {
+			return fmt.Errorf("role name %q is duplicated", r.Name)
+		}
+
+		// Each placeholder only matches its role
+		placeholder.Roles = append(placeholder.Roles, &networkTemplateRole{
+			Name:        r.Name,
+			NamePattern: "^" + regexp.QuoteMeta(r.Name) + "-[0-9]+$",
+			Count:       r.Count,
+			MTU:         r.MTU,
+			Links:       r.Links,
+		})
+
+		for i := range r.Count {
+			networkInterfaces = append(networkInterfaces, entity.NetworkInterface{
+				Type: networkKindPhysical,
+				Name: fmt.Sprintf("%s-%d", r.Name, i),
+			})
+		}
+	}
+
+	_, err := placeholder.layout(networkInterfaces)
+
+	return err
+}
+
+func expandNetworkTemplateLinks(links []*networkTemplateLink) []*networkLink {
+	var expanded []*networkLink
+
+	for _, link := range links {
+		expanded = append(expanded, &networkLink{Subnet: link.Subnet, Mode: link.Mode, DefaultGateway: link.DefaultGateway})
+	}
+
+	return expanded
+}
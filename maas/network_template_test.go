@@ -0,0 +1,93 @@
+package maas
+
+import (
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func testNetworkTemplateInterfaces() []entity.NetworkInterface {
+	return []entity.NetworkInterface{
+		{ID: 1, Name: "eno1", Type: "physical", MACAddress: "52:54:00:00:00:01", Vendor: "Intel Corporation", Product: "I350 Gigabit Network Connection", LinkSpeed: 1000},
+		{ID: 2, Name: "enp1s0f1", Type: "physical", MACAddress: "52:54:00:00:00:03", Vendor: "Mellanox Technologies", Product: "MT27710 Family [ConnectX-4 Lx]", LinkSpeed: 25000},
+		{ID: 3, Name: "enp1s0f0", Type: "physical", MACAddress: "52:54:00:00:00:02", Vendor: "Mellanox Technologies", Product: "MT27710 Family [ConnectX-4 Lx]", LinkSpeed: 25000},
+		{ID: 4, Name: "bond9", Type: "bond", Parents: []string{"eno1"}},
+	}
+}
+
+func testNetworkTemplate() *networkTemplate {
+	return &networkTemplate{
+		Roles: []*networkTemplateRole{
+			{Name: "uplink", Vendor: "mellanox technologies", LinkSpeed: 25000, Count: 2},
+			{Name: "management", NamePattern: "^eno[0-9]+$", Count: 1, Links: []*networkTemplateLink{{Subnet: "10.0.0.0/24", Mode: "DHCP"}}},
+		},
+		Bonds: []*networkTemplateInterface{
+			{Name: "bond0", Parents: []string{"uplink"}, Params: map[string]string{"bond_mode": "802.3ad"}},
+		},
+		VLANs: []*networkTemplateInterface{
+			{Parents: []string{"bond0"}, VID: 100},
+		},
+		Bridges: []*networkTemplateInterface{
+			{Name: "br100", Parents: []string{"bond0.100"}, Links: []*networkTemplateLink{{Subnet: "10.100.0.0/24", Mode: "AUTO", DefaultGateway: true}}},
+		},
+	}
+}
+
+func TestNetworkTemplateLayout(t *testing.T) {
+	layout, err := testNetworkTemplate().layout(testNetworkTemplateInterfaces())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"eno1", "enp1s0f1", "enp1s0f0", "bond0", "bond0.100", "br100"}, layout.keys())
+	assert.Equal(t, []string{"enp1s0f0", "enp1s0f1"}, layout.networkInterface("bond0").Parents)
+	assert.Equal(t, []string{"bond0"}, layout.networkInterface("bond0.100").Parents)
+	assert.Equal(t, "52:54:00:00:00:01", layout.networkInterface("eno1").MACAddress)
+	assert.Equal(t, []*networkLink{{Subnet: "10.0.0.0/24", Mode: "DHCP"}}, layout.networkInterface("eno1").Links)
+	assert.Equal(t, []string{"br100/0"}, layout.defaultGateways())
+}
+
+func TestNetworkTemplateLayoutErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		update func(t *networkTemplate)
+		err    string
+	}{
+		{
+			name:   "count mismatch",
+			update: func(t *networkTemplate) { t.Roles[0].Count = 3 },
+			err:    `role "uplink" matches 2 interfaces (enp1s0f1, enp1s0f0), 3 expected`,
+		},
+		{
+			name:   "first role wins",
+			update: func(t *networkTemplate) { t.Roles[0].Vendor, t.Roles[0].LinkSpeed = "", 0 },
+			err:    `role "uplink" matches 3 interfaces (eno1, enp1s0f1, enp1s0f0), 2 expected`,
+		},
+		{
+			name:   "several parents",
+			update: func(t *networkTemplate) { t.VLANs[0].Parents = []string{"uplink"} },
+			err:    `vlan "uplink.100": the parent must be a single interface, "uplink" matches 2 interfaces`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			template := testNetworkTemplate()
+			testCase.update(template)
+
+			_, err := template.layout(testNetworkTemplateInterfaces())
+			assert.EqualError(t, err, testCase.err)
+		})
+	}
+}
+
+func TestNetworkTemplateValidate(t *testing.T) {
+	assert.NoError(t, testNetworkTemplate().validate())
+
+	template := testNetworkTemplate()
+	template.Roles[1].Name = "uplink"
+	assert.EqualError(t, template.validate(), `role name "uplink" is duplicated`)
+
+	template = testNetworkTemplate()
+	template.Bridges[0].Parents = []string{"bond1"}
+	assert.ErrorContains(t, template.validate(), `"bond1"`)
+}
@@ -53,41 +53,55 @@ func Provider() *schema.Provider {
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"maas_boot_source_selection":      resourceMAASBootSourceSelection(),
-			"maas_boot_source":                resourceMAASBootSource(),
-			"maas_configuration":              resourceMAASConfiguration(),
-			"maas_device":                     resourceMAASDevice(),
-			"maas_instance":                   resourceMAASInstance(),
-			"maas_vm_host":                    resourceMAASVMHost(),
-			"maas_vm_host_machine":            resourceMAASVMHostMachine(),
-			"maas_machine":                    resourceMAASMachine(),
-			"maas_network_interface_bridge":   resourceMAASNetworkInterfaceBridge(),
-			"maas_network_interface_bond":     resourceMAASNetworkInterfaceBond(),
-			"maas_network_interface_physical": resourceMAASNetworkInterfacePhysical(),
-			"maas_network_interface_vlan":     resourceMAASNetworkInterfaceVLAN(),
-			"maas_network_interface_link":     resourceMAASNetworkInterfaceLink(),
-			"maas_fabric":                     resourceMAASFabric(),
-			"maas_vlan":                       resourceMAASVLAN(),
-			"maas_vlan_dhcp":                  resourceMAASVLANDHCP(),
-			"maas_static_route":               resourceMAASStaticRoute(),
-			"maas_subnet":                     resourceMAASSubnet(),
-			"maas_subnet_ip_range":            resourceMAASSubnetIPRange(),
-			"maas_dns_domain":                 resourceMAASDNSDomain(),
-			"maas_dns_record":                 resourceMAASDNSRecord(),
-			"maas_space":                      resourceMAASSpace(),
-			"maas_block_device":               resourceMAASBlockDevice(),
-			"maas_block_device_tag":           resourceMAASBlockDeviceTag(),
-			"maas_tag":                        resourceMAASTag(),
-			"maas_network_interface_tag":      resourceMAASNetworkInterfaceTag(),
-			"maas_package_repository":         resourceMAASPackageRepository(),
-			"maas_user":                       resourceMAASUser(),
-			"maas_ssh_keys":                   resourceMAASSSHKeys(),
-			"maas_resource_pool":              resourceMAASResourcePool(),
-			"maas_raid":                       resourceMAASRAID(),
-			"maas_volume_group":               resourceMAASVolumeGroup(),
-			"maas_logical_volume":             resourceMAASLogicalVolume(),
-			"maas_zone":                       resourceMAASZone(),
-			"maas_node_script":                resourceMAASNodeScript(),
+			"maas_boot_source_selection":       resourceMAASBootSourceSelection(),
+			"maas_boot_source":                 resourceMAASBootSource(),
+			"maas_configuration":               resourceMAASConfiguration(),
+			"maas_device":                      resourceMAASDevice(),
+			"maas_instance":                    resourceMAASInstance(),
+			"maas_vm_host":                     resourceMAASVMHost(),
+			"maas_vm_host_machine":             resourceMAASVMHostMachine(),
+			"maas_machine":                     resourceMAASMachine(),
+			"maas_machine_inventory":           resourceMAASMachineInventory(),
+			"maas_machine_adoption":            resourceMAASMachineAdoption(),
+			"maas_machine_test_run":            resourceMAASMachineTestRun(),
+			"maas_machine_storage":             resourceMAASMachineStorage(),
+			"maas_machine_network":             resourceMAASMachineNetwork(),
+			"maas_network_template":            resourceMAASNetworkTemplate(),
+			"maas_network_template_attachment": resourceMAASNetworkTemplateAttachment(),
+			"maas_chassis":                     resourceMAASChassis(),
+			"maas_network_interface_bridge":    resourceMAASNetworkInterfaceBridge(),
+			"maas_network_interface_bond":      resourceMAASNetworkInterfaceBond(),
+			"maas_network_interface_physical":  resourceMAASNetworkInterfacePhysical(),
+			"maas_network_interface_vlan":      resourceMAASNetworkInterfaceVLAN(),
+			"maas_network_interface_link":      resourceMAASNetworkInterfaceLink(),
+			"maas_fabric":                      resourceMAASFabric(),
+			"maas_vlan":                        resourceMAASVLAN(),
+			"maas_vlan_dhcp":                   resourceMAASVLANDHCP(),
+			"maas_static_route":                resourceMAASStaticRoute(),
+			"maas_subnet":                      resourceMAASSubnet(),
+			"maas_subnet_ip_range":             resourceMAASSubnetIPRange(),
+			"maas_ip_address":                  resourceMAASIPAddress(),
+			"maas_dns_domain":                  resourceMAASDNSDomain(),
+			"maas_dns_record":                  resourceMAASDNSRecord(),
+			"maas_space":                       resourceMAASSpace(),
+			"maas_block_device":                resourceMAASBlockDevice(),
+			"maas_block_device_tag":            resourceMAASBlockDeviceTag(),
+			"maas_tag":                         resourceMAASTag(),
+			"maas_network_interface_tag":       resourceMAASNetworkInterfaceTag(),
+			"maas_package_repository":          resourceMAASPackageRepository(),
+			"maas_user":                        resourceMAASUser(),
+			"maas_ssh_keys":                    resourceMAASSSHKeys(),
+			"maas_resource_pool":               resourceMAASResourcePool(),
+			"maas_raid":                        resourceMAASRAID(),
+			"maas_bcache_cache_set":            resourceMAASBCacheCacheSet(),
+			"maas_bcache":                      resourceMAASBCache(),
+			"maas_volume_group":                resourceMAASVolumeGroup(),
+			"maas_vmfs_datastore":              resourceMAASVMFSDatastore(),
+			"maas_partition":                   resourceMAASPartition(),
+			"maas_logical_volume":              resourceMAASLogicalVolume(),
+			"maas_special_filesystem":          resourceMAASSpecialFilesystem(),
+			"maas_zone":                        resourceMAASZone(),
+			"maas_node_script":                 resourceMAASNodeScript(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"maas_boot_resources":             dataSourceMAASBootResources(),
@@ -97,8 +111,12 @@ func Provider() *schema.Provider {
 			"maas_fabric":                     dataSourceMAASFabric(),
 			"maas_vlan":                       dataSourceMAASVLAN(),
 			"maas_subnet":                     dataSourceMAASSubnet(),
+			"maas_ip_addresses":               dataSourceMAASIPAddresses(),
 			"maas_machine":                    dataSourceMAASMachine(),
 			"maas_machines":                   dataSourceMAASMachines(),
+			"maas_enlisted_machines":          dataSourceMAASEnlistedMachines(),
+			"maas_node_script_results":        dataSourceMAASNodeScriptResults(),
+			"maas_node_events":                dataSourceMAASNodeEvents(),
 			"maas_network_interface_physical": dataSourceMAASNetworkInterfacePhysical(),
 			"maas_device":                     dataSourceMAASDevice(),
 			"maas_devices":                    dataSourceMAASDevices(),
@@ -117,6 +135,8 @@ type ClientConfig struct {
 	Client             *client.Client
 	InstallationMethod string
 	MAASVersion        string
+	// AllocationQueue serializes the machine allocations done by the provider.
+	AllocationQueue chan struct{}
 }
 
 func providerConfigure(ctx context.Context, d *schema.ResourceData) (any, diag.Diagnostics) {
@@ -163,5 +183,5 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (any, diag.D
 		return nil, diags
 	}
 
-	return &ClientConfig{Client: c, InstallationMethod: d.Get("installation_method").(string), MAASVersion: v.Version}, diags
+	return &ClientConfig{Client: c, InstallationMethod: d.Get("installation_method").(string), MAASVersion: v.Version, AllocationQueue: make(chan struct{}, 1)}, diags
 }
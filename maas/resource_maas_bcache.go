@@ -0,0 +1,306 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASBCache() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage MAAS bcaches, backed by a block device or a partition and cached by a bcache cache set.",
+		CreateContext: resourceBCacheCreate,
+		ReadContext:   resourceBCacheRead,
+		UpdateContext: resourceBCacheUpdate,
+		DeleteContext: resourceBCacheDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceBCacheImport,
+		},
+		CustomizeDiff: resolveStorageSelectorsDiff("backing_device_selector", "backing_partition_selector"),
+
+		Schema: map[string]*schema.Schema{
+			"backing_device": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"backing_device", "backing_device_selector", "backing_partition", "backing_partition_selector"},
+				Description:  "The ID of the block device backing the bcache (e.g. an HDD).",
+			},
+			"backing_device_selector": storageSelectorSchema("Selects the block device backing the bcache by its stable attributes, rather than by ID.", false, []string{"backing_device", "backing_device_selector", "backing_partition", "backing_partition_selector"}),
+			"backing_partition": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"backing_device", "backing_device_selector", "backing_partition", "backing_partition_selector"},
+				Description:  "The ID of the partition backing the bcache.",
+			},
+			"backing_partition_selector": storageSelectorSchema("Selects the partition backing the bcache by the stable attributes of its disk and its number, rather than by ID.", true, []string{"backing_device", "backing_device_selector", "backing_partition", "backing_partition_selector"}),
+			"block_device_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the bcache virtual block device, which can be used to build volume groups.",
+			},
+			"cache_mode": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "writeback",
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"writeback", "writethrough", "writearound"}, false)),
+				Description:      "The cache mode. Valid options are: `writeback`, `writethrough` and `writearound`. Defaults to `writeback`.",
+			},
+			"cache_set": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The ID of the bcache cache set.",
+			},
+			"fs_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The file system type (e.g. `ext4`). If this is not set, the bcache is unformatted.",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The machine identifier (system ID, hostname, or FQDN) that owns the bcache.",
+			},
+			"mount_options": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Comma separated options used for the bcache mount.",
+			},
+			"mount_point": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"fs_type"},
+				Description:  "The mount point used. If this is not set, the bcache is not mounted.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the bcache.",
+			},
+			"selected_devices": selectedDevicesSchema(),
+			"size_gigabytes": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The bcache size (given in GB).",
+			},
+			"uuid": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The bcache UUID.",
+			},
+		},
+	}
+}
+
+func resourceBCacheImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.Split(d.Id(), ":")
+	if len(idParts) != 2 || idParts[0] == "" || idParts[1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE:BCACHE", d.Id())
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	bcaches, err := client.BCaches.Get(machine.SystemID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bcache := range bcaches {
+		if fmt.Sprintf("%v", bcache.ID) == idParts[1] || bcache.Name == idParts[1] {
+			if err := d.Set("machine", machine.SystemID); err != nil {
+				return nil, err
+			}
+
+			d.SetId(fmt.Sprintf("%v", bcache.ID))
+
+			return []*schema.ResourceData{d}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("bcache (%s) was not found on machine (%s)", idParts[1], machine.SystemID)
+}
+
+func resourceBCacheCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params, err := getBCacheParams(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	bcache, err := client.BCaches.Create(machine.SystemID, params)
+	if err != nil {
+		return diag.Errorf("Could not create bcache: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("%v", bcache.ID))
+
+	// We perform mounting and formatting operations on the virtual block device, rather than a part of the bcache creation
+	if _, err = formatAndMountVirtualBlockDevice(client, &bcache.VirtualDevice, d); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceBCacheRead(ctx, d, meta)
+}
+
+func resourceBCacheRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	bcache, err := client.BCache.Get(machine.SystemID, id)
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	tfState := map[string]any{
+		"backing_device":    "",
+		"backing_partition": "",
+		"block_device_id":   bcache.VirtualDevice.ID,
+		"cache_mode":        strings.ToLower(bcache.CacheMode),
+		"cache_set":         fmt.Sprintf("%v", bcache.CacheSet.ID),
+		"fs_type":           bcache.VirtualDevice.Filesystem.FSType,
+		"machine":           machine.SystemID,
+		"mount_options":     bcache.VirtualDevice.Filesystem.MountOptions,
+		"mount_point":       bcache.VirtualDevice.Filesystem.MountPoint,
+		"name":              bcache.Name,
+		"selected_devices":  map[string]any{},
+		"size_gigabytes":    int(math.Round(float64(bcache.Size) / GigaBytes)),
+		"uuid":              bcache.UUID,
+	}
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "backing_device", "backing_partition")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The backing device is either a block device or a partition, unless it is selected
+	attr := "backing_device"
+	if bcache.BackingDevice.Type == "partition" {
+		attr = "backing_partition"
+	}
+
+	for _, id := range references.attribute(d, attr, []string{fmt.Sprintf("%v", bcache.BackingDevice.ID)}, tfState["selected_devices"].(map[string]any)) {
+		tfState[attr] = id
+	}
+
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.Errorf("Could not set bcache state: %v", err)
+	}
+
+	return nil
+}
+
+func resourceBCacheUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params, err := getBCacheParams(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	bcache, err := client.BCache.Update(machine.SystemID, id, params)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Unmount, unformat, format and mount again only what changed
+	current := storageFilesystem{
+		FSType:       bcache.VirtualDevice.Filesystem.FSType,
+		MountPoint:   bcache.VirtualDevice.Filesystem.MountPoint,
+		MountOptions: bcache.VirtualDevice.Filesystem.MountOptions,
+	}
+	target := storageFilesystem{
+		FSType:       d.Get("fs_type").(string),
+		MountPoint:   d.Get("mount_point").(string),
+		MountOptions: d.Get("mount_options").(string),
+	}
+
+	if err := transitionStorageFilesystem(blockDeviceFilesystemOps(client, machine.SystemID, bcache.VirtualDevice.ID), &current, target); err != nil {
+		return diag.FromErr(fmt.Errorf("bcache (%s): %w", bcache.Name, err))
+	}
+
+	return resourceBCacheRead(ctx, d, meta)
+}
+
+func resourceBCacheDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := client.BCache.Delete(machine.SystemID, id); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func getBCacheParams(client *client.Client, systemID string, d *schema.ResourceData) (*entity.BCacheParams, error) {
+	references, err := getStorageReferences(client, systemID, d, "backing_device", "backing_partition")
+	if err != nil {
+		return nil, err
+	}
+
+	params := &entity.BCacheParams{
+		Name:      d.Get("name").(string),
+		CacheSet:  d.Get("cache_set").(string),
+		CacheMode: d.Get("cache_mode").(string),
+	}
+
+	for attr, param := range map[string]*string{"backing_device": &params.BackingDevice, "backing_partition": &params.BackingPartition} {
+		ids, err := references.ids(d, attr)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ids) > 0 {
+			*param = ids[0]
+		}
+	}
+
+	return params, nil
+}
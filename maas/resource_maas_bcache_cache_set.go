@@ -0,0 +1,226 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceMAASBCacheCacheSet() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage MAAS bcache cache sets, created from a block device or a partition.",
+		CreateContext: resourceBCacheCacheSetCreate,
+		ReadContext:   resourceBCacheCacheSetRead,
+		UpdateContext: resourceBCacheCacheSetUpdate,
+		DeleteContext: resourceBCacheCacheSetDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceBCacheCacheSetImport,
+		},
+		CustomizeDiff: resolveStorageSelectorsDiff("cache_device_selector", "cache_partition_selector"),
+
+		Schema: map[string]*schema.Schema{
+			"cache_device": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"cache_device", "cache_device_selector", "cache_partition", "cache_partition_selector"},
+				Description:  "The ID of the block device used as cache (e.g. an NVMe disk).",
+			},
+			"cache_device_selector": storageSelectorSchema("Selects the block device used as cache by its stable attributes, rather than by ID.", false, []string{"cache_device", "cache_device_selector", "cache_partition", "cache_partition_selector"}),
+			"cache_partition": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"cache_device", "cache_device_selector", "cache_partition", "cache_partition_selector"},
+				Description:  "The ID of the partition used as cache.",
+			},
+			"cache_partition_selector": storageSelectorSchema("Selects the partition used as cache by the stable attributes of its disk and its number, rather than by ID.", true, []string{"cache_device", "cache_device_selector", "cache_partition", "cache_partition_selector"}),
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The machine identifier (system ID, hostname, or FQDN) that owns the cache set.",
+			},
+			"selected_devices": selectedDevicesSchema(),
+		},
+	}
+}
+
+func resourceBCacheCacheSetImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.Split(d.Id(), ":")
+	if len(idParts) != 2 || idParts[0] == "" || idParts[1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE:BCACHE_CACHE_SET_ID", d.Id())
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(idParts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected bcache cache set ID (%q), expected a number", idParts[1])
+	}
+
+	cacheSets, err := client.BCacheCacheSets.Get(machine.SystemID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cacheSet := range cacheSets {
+		if cacheSet.ID == id {
+			if err := d.Set("machine", machine.SystemID); err != nil {
+				return nil, err
+			}
+
+			d.SetId(fmt.Sprintf("%v", cacheSet.ID))
+
+			return []*schema.ResourceData{d}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("bcache cache set (%d) was not found on machine (%s)", id, machine.SystemID)
+}
+
+func resourceBCacheCacheSetCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params, err := getBCacheCacheSetParams(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	cacheSet, err := client.BCacheCacheSets.Create(machine.SystemID, params)
+	if err != nil {
+		return diag.Errorf("Could not create bcache cache set: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("%v", cacheSet.ID))
+
+	return resourceBCacheCacheSetRead(ctx, d, meta)
+}
+
+func resourceBCacheCacheSetRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	cacheSet, err := client.BCacheCacheSet.Get(machine.SystemID, id)
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "cache_device", "cache_partition")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	tfState := map[string]any{
+		"cache_device":     "",
+		"cache_partition":  "",
+		"machine":          machine.SystemID,
+		"selected_devices": map[string]any{},
+	}
+
+	// The cache device is either a block device or a partition, unless it is selected
+	attr := "cache_device"
+	if cacheSet.CacheDevice.Type == "partition" {
+		attr = "cache_partition"
+	}
+
+	for _, id := range references.attribute(d, attr, []string{fmt.Sprintf("%v", cacheSet.CacheDevice.ID)}, tfState["selected_devices"].(map[string]any)) {
+		tfState[attr] = id
+	}
+
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceBCacheCacheSetUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params, err := getBCacheCacheSetParams(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, err := client.BCacheCacheSet.Update(machine.SystemID, id, params); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceBCacheCacheSetRead(ctx, d, meta)
+}
+
+func resourceBCacheCacheSetDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := client.BCacheCacheSet.Delete(machine.SystemID, id); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func getBCacheCacheSetParams(client *client.Client, systemID string, d *schema.ResourceData) (*entity.BCacheCacheSetParams, error) {
+	references, err := getStorageReferences(client, systemID, d, "cache_device", "cache_partition")
+	if err != nil {
+		return nil, err
+	}
+
+	params := &entity.BCacheCacheSetParams{}
+
+	for attr, param := range map[string]*string{"cache_device": &params.CacheDevice, "cache_partition": &params.CachePartition} {
+		ids, err := references.ids(d, attr)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ids) > 0 {
+			*param = ids[0]
+		}
+	}
+
+	return params, nil
+}
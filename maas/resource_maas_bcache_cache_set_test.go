@@ -0,0 +1,106 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASBCacheCacheSet_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASBCacheCacheSetDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASBCacheCacheSet(machine),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("maas_bcache_cache_set.test", "cache_device", "maas_block_device.cache", "id"),
+					resource.TestCheckResourceAttr("maas_bcache_cache_set.test", "cache_partition", ""),
+				),
+			},
+			// Test import
+			{
+				ResourceName:      "maas_bcache_cache_set.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					rs, ok := s.RootModule().Resources["maas_bcache_cache_set.test"]
+					if !ok {
+						return "", fmt.Errorf("resource not found: %s", "maas_bcache_cache_set.test")
+					}
+
+					return fmt.Sprintf("%s:%s", rs.Primary.Attributes["machine"], rs.Primary.ID), nil
+				},
+			},
+			// The imported cache set must exist on the machine
+			{
+				ResourceName:  "maas_bcache_cache_set.test",
+				ImportState:   true,
+				ImportStateId: fmt.Sprintf("%s:0", machine),
+				ExpectError:   regexp.MustCompile(`bcache cache set \(0\) was not found on machine`),
+			},
+		},
+	})
+}
+
+func testAccMAASBCacheCacheSet(machine string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_block_device" "cache" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfcache"
+  size_gigabytes = 10
+  block_size     = 512
+  id_path        = "/dev/tfcache"
+}
+
+resource "maas_bcache_cache_set" "test" {
+  machine      = data.maas_machine.machine.id
+  cache_device = maas_block_device.cache.id
+}
+`, machine)
+}
+
+func testAccCheckMAASBCacheCacheSetDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_bcache_cache_set" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		response, err := conn.BCacheCacheSet.Get(rs.Primary.Attributes["machine"], id)
+		if err == nil {
+			if response != nil && response.ID == id {
+				return fmt.Errorf("BCache cache set %d still exists.", id)
+			}
+		}
+
+		// 404 means destroyed, anything else is an error
+		if !strings.Contains(err.Error(), "404 Not Found") {
+			return err
+		}
+	}
+
+	return nil
+}
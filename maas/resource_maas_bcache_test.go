@@ -0,0 +1,114 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASBCache_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASBCacheDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASBCache(machine, "writeback", "/srv"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_bcache.test", "name", "tfbcache0"),
+					resource.TestCheckResourceAttr("maas_bcache.test", "cache_mode", "writeback"),
+					resource.TestCheckResourceAttr("maas_bcache.test", "fs_type", "ext4"),
+					resource.TestCheckResourceAttr("maas_bcache.test", "mount_point", "/srv"),
+					resource.TestCheckResourceAttrPair("maas_bcache.test", "backing_device", "maas_block_device.backing", "id"),
+					resource.TestCheckResourceAttrPair("maas_bcache.test", "cache_set", "maas_bcache_cache_set.test", "id"),
+					resource.TestCheckResourceAttrSet("maas_bcache.test", "block_device_id"),
+				),
+			},
+			// Test the in-place update
+			{
+				Config: testAccMAASBCache(machine, "writethrough", "/srv/data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_bcache.test", "cache_mode", "writethrough"),
+					resource.TestCheckResourceAttr("maas_bcache.test", "mount_point", "/srv/data"),
+				),
+			},
+			// Test import
+			{
+				ResourceName:      "maas_bcache.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					rs, ok := s.RootModule().Resources["maas_bcache.test"]
+					if !ok {
+						return "", fmt.Errorf("resource not found: %s", "maas_bcache.test")
+					}
+
+					return fmt.Sprintf("%s:%s", rs.Primary.Attributes["machine"], rs.Primary.Attributes["name"]), nil
+				},
+			},
+		},
+	})
+}
+
+func testAccMAASBCache(machine string, cacheMode string, mountPoint string) string {
+	return fmt.Sprintf(`
+%s
+
+resource "maas_block_device" "backing" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfbacking"
+  size_gigabytes = 40
+  block_size     = 512
+  id_path        = "/dev/tfbacking"
+}
+
+resource "maas_bcache" "test" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfbcache0"
+  backing_device = maas_block_device.backing.id
+  cache_set      = maas_bcache_cache_set.test.id
+  cache_mode     = %q
+  fs_type        = "ext4"
+  mount_point    = %q
+}
+`, testAccMAASBCacheCacheSet(machine), cacheMode, mountPoint)
+}
+
+func testAccCheckMAASBCacheDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_bcache" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		response, err := conn.BCache.Get(rs.Primary.Attributes["machine"], id)
+		if err == nil {
+			if response != nil && response.ID == id {
+				return fmt.Errorf("BCache %s (%d) still exists.", response.Name, id)
+			}
+		}
+
+		// 404 means destroyed, anything else is an error
+		if !strings.Contains(err.Error(), "404 Not Found") {
+			return err
+		}
+	}
+
+	return nil
+}
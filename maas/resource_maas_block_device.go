@@ -55,6 +55,7 @@ func resourceMAASBlockDevice() *schema.Resource {
 			},
 		},
 		UseJSONNumber: true,
+		CustomizeDiff: validateBlockDeviceCapacity,
 
 		Schema: map[string]*schema.Schema{
 			"block_size": {
@@ -63,6 +64,12 @@ func resourceMAASBlockDevice() *schema.Resource {
 				Default:     512,
 				Description: "The block size of the block device. Defaults to `512`.",
 			},
+			"fs_type": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"partitions"},
+				Description:   "The file system type (e.g. `ext4`) used to format the whole block device, without a partition table. Conflicts with `partitions`. If this is not set, the block device is unformatted. A block device used by a volume group, a RAID or a bcache is reported as unformatted.",
+			},
 			"id_path": {
 				Type:          schema.TypeString,
 				Optional:      true,
@@ -91,6 +98,18 @@ func resourceMAASBlockDevice() *schema.Resource {
 				AtLeastOneOf:  []string{"model", "id_path"},
 				Description:   "Model of the block device. Used in conjunction with `serial` argument. Conflicts with `id_path`. This argument is computed if it's not given.",
 			},
+			"mount_options": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"mount_point"},
+				Description:  "The options used to mount the whole block device.",
+			},
+			"mount_point": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"fs_type"},
+				Description:  "The mount point of the whole block device. If this is not set, the block device is not mounted.",
+			},
 			"name": {
 				Type:        schema.TypeString,
 				Required:    true,
@@ -234,14 +253,18 @@ func resourceBlockDeviceRead(ctx context.Context, d *schema.ResourceData, meta a
 		return unsetIfNotFoundError(d, err)
 	}
 
+	fs := getBlockDeviceFilesystem(blockDevice)
 	tfState := map[string]any{
-		"partitions": getBlockDevicePartitionsTFState(blockDevice),
-		"model":      blockDevice.Model,
-		"serial":     blockDevice.Serial,
-		"id_path":    blockDevice.IDPath,
-		"tags":       blockDevice.Tags,
-		"uuid":       blockDevice.UUID,
-		"path":       blockDevice.Path,
+		"partitions":    getBlockDevicePartitionsTFState(blockDevice),
+		"model":         blockDevice.Model,
+		"serial":        blockDevice.Serial,
+		"id_path":       blockDevice.IDPath,
+		"tags":          blockDevice.Tags,
+		"uuid":          blockDevice.UUID,
+		"path":          blockDevice.Path,
+		"fs_type":       fs.FSType,
+		"mount_options": fs.MountOptions,
+		"mount_point":   fs.MountPoint,
 	}
 	if err := setTerraformState(d, tfState); err != nil {
 		return diag.FromErr(err)
@@ -278,8 +301,31 @@ func resourceBlockDeviceUpdate(ctx context.Context, d *schema.ResourceData, meta
 		}
 	}
 
-	if err := updateBlockDevicePartitions(client, d, blockDevice); err != nil {
-		return diag.FromErr(err)
+	current := getBlockDeviceFilesystem(blockDevice)
+	target := storageFilesystem{
+		FSType:       d.Get("fs_type").(string),
+		MountPoint:   d.Get("mount_point").(string),
+		MountOptions: d.Get("mount_options").(string),
+	}
+	ops := blockDeviceFilesystemOps(client, machine.SystemID, blockDevice.ID)
+
+	// The whole block device is either formatted or partitioned
+	if target.FSType == "" {
+		if err := transitionStorageFilesystem(ops, &current, target); err != nil {
+			return diag.FromErr(fmt.Errorf("block device (%s): %w", blockDevice.Name, err))
+		}
+
+		if err := updateBlockDevicePartitions(client, d, blockDevice); err != nil {
+			return diag.FromErr(err)
+		}
+	} else {
+		if err := deleteBlockDevicePartitions(client, blockDevice); err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := transitionStorageFilesystem(ops, &current, target); err != nil {
+			return diag.FromErr(fmt.Errorf("block device (%s): %w", blockDevice.Name, err))
+		}
 	}
 
 	return resourceBlockDeviceRead(ctx, d, meta)
@@ -303,15 +349,19 @@ func resourceBlockDeviceDelete(ctx context.Context, d *schema.ResourceData, meta
 		return diag.FromErr(err)
 	}
 
-	// We choose to delete the partitions and tags associated with a physical block device
+	// We choose to delete the filesystem, partitions and tags associated with a physical block device
 	// rather than delete it because it makes more sense for a physical device with permanence
 	// outside of Terraform. Virtual devices are expected to be entirely managed by Terraform.
 
+	// Remove the filesystem of the whole block device
+	current := getBlockDeviceFilesystem(blockDevice)
+	if err := transitionStorageFilesystem(blockDeviceFilesystemOps(client, machine.SystemID, blockDevice.ID), &current, storageFilesystem{}); err != nil {
+		return diag.FromErr(fmt.Errorf("block device (%s): %w", blockDevice.Name, err))
+	}
+
 	// Remove existing partitions
-	for _, part := range blockDevice.Partitions {
-		if err := client.BlockDevicePartition.Delete(blockDevice.SystemID, blockDevice.ID, part.ID); err != nil {
-			return diag.FromErr(err)
-		}
+	if err := deleteBlockDevicePartitions(client, blockDevice); err != nil {
+		return diag.FromErr(err)
 	}
 
 	// Remove existing tags
@@ -343,7 +393,7 @@ func findBlockDevice(client *client.Client, machineID string, identifier string)
 	}
 
 	for _, b := range blockDevices {
-		if fmt.Sprintf("%v", b.ID) == identifier || b.Name == identifier || b.IDPath == identifier || b.Path == identifier {
+		if fmt.Sprintf("%v", b.ID) == identifier || b.Name == identifier || b.Serial == identifier || b.IDPath == identifier || b.Path == identifier {
 			return &b, nil
 		}
 	}
@@ -422,7 +472,7 @@ func getBlockDevicePartitionsTFState(blockDevice *entity.BlockDevice) []map[stri
 			"size_gigabytes": int(math.Round(float64(p.Size) / GigaBytes)),
 			"bootable":       p.Bootable,
 			"tags":           p.Tags,
-			"fs_type":        p.FileSystem.FSType,
+			"fs_type":        getStorageFSType(p.FileSystem.FSType),
 			"id":             p.ID,
 			"label":          p.FileSystem.Label,
 			"mount_point":    p.FileSystem.MountPoint,
@@ -435,16 +485,32 @@ func getBlockDevicePartitionsTFState(blockDevice *entity.BlockDevice) []map[stri
 	return partitions
 }
 
+func getBlockDeviceFilesystem(blockDevice *entity.BlockDevice) storageFilesystem {
+	return storageFilesystem{
+		FSType:       getStorageFSType(blockDevice.Filesystem.FSType),
+		MountPoint:   blockDevice.Filesystem.MountPoint,
+		MountOptions: blockDevice.Filesystem.MountOptions,
+	}
+}
+
+func deleteBlockDevicePartitions(client *client.Client, blockDevice *entity.BlockDevice) error {
+	for _, part := range blockDevice.Partitions {
+		if err := client.BlockDevicePartition.Delete(blockDevice.SystemID, blockDevice.ID, part.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func updateBlockDevicePartitions(client *client.Client, d *schema.ResourceData, blockDevice *entity.BlockDevice) error {
 	p, ok := d.GetOk("partitions")
 	if !ok {
 		return nil
 	}
 	// Remove existing partitions
-	for _, part := range blockDevice.Partitions {
-		if err := client.BlockDevicePartition.Delete(blockDevice.SystemID, blockDevice.ID, part.ID); err != nil {
-			return err
-		}
+	if err := deleteBlockDevicePartitions(client, blockDevice); err != nil {
+		return err
 	}
 	// Create new partitions given by the user
 	partitions := p.([]any)
@@ -3,6 +3,7 @@ package maas_test
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"terraform-provider-maas/maas/testutils"
 	"testing"
 
@@ -123,3 +124,117 @@ func TestAccResourceMAASBlockDevice_basic(t *testing.T) {
 		},
 	})
 }
+
+func TestAccResourceMAASBlockDevice_capacity(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers: testutils.TestAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMAASBlockDeviceCapacity(machine),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`partitions: the partitions require 25 GB but the block device has 20 GB, 5 GB is missing`),
+			},
+		},
+	})
+}
+
+func testAccMAASBlockDeviceCapacity(machine string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = "%s"
+}
+
+resource "maas_block_device" "test" {
+  machine        = data.maas_machine.machine.id
+  name           = "vdz"
+  size_gigabytes = 20
+  id_path        = "/dev/vdz"
+
+  partitions {
+    size_gigabytes = 10
+  }
+
+  partitions {
+    size_gigabytes = 15
+  }
+}
+`, machine)
+}
+
+func TestAccResourceMAASBlockDevice_filesystem(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASBlockDeviceFilesystem(machine, "xfs", "/scratch"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_block_device.test", "fs_type", "xfs"),
+					resource.TestCheckResourceAttr("maas_block_device.test", "mount_point", "/scratch"),
+					resource.TestCheckResourceAttr("maas_block_device.test", "partitions.#", "0"),
+				),
+			},
+			// Unmount the block device
+			{
+				Config: testAccMAASBlockDeviceFilesystem(machine, "xfs", ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_block_device.test", "fs_type", "xfs"),
+					resource.TestCheckResourceAttr("maas_block_device.test", "mount_point", ""),
+				),
+			},
+			// Unformat the block device
+			{
+				Config: testAccMAASBlockDeviceFilesystem(machine, "", ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_block_device.test", "fs_type", ""),
+				),
+			},
+			// The block device joins a volume group, its lvm-pv filesystem is not reported
+			{
+				Config: testAccMAASBlockDeviceFilesystem(machine, "", "") + testAccMAASBlockDeviceVolumeGroup(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_block_device.test", "fs_type", ""),
+					resource.TestCheckTypeSetElemAttrPair("maas_volume_group.test", "block_devices.*", "maas_block_device.test", "id"),
+				),
+			},
+			{
+				Config:   testAccMAASBlockDeviceFilesystem(machine, "", "") + testAccMAASBlockDeviceVolumeGroup(),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func testAccMAASBlockDeviceFilesystem(machine string, fsType string, mountPoint string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = "%s"
+}
+
+resource "maas_block_device" "test" {
+  machine        = data.maas_machine.machine.id
+  name           = "vdy"
+  size_gigabytes = 20
+  id_path        = "/dev/vdy"
+  fs_type        = %q
+  mount_point    = %q
+}
+`, machine, fsType, mountPoint)
+}
+
+func testAccMAASBlockDeviceVolumeGroup() string {
+	return `
+resource "maas_volume_group" "test" {
+  machine       = data.maas_machine.machine.id
+  name          = "vg-block-device"
+  block_devices = [maas_block_device.test.id]
+}
+`
+}
@@ -0,0 +1,401 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// chassisType describes the credentials and options supported by a chassis type,
+// and the power type of the machines added from it.
+type chassisType struct {
+	powerType string
+	required  []string
+	optional  []string
+}
+
+var chassisTypes = map[string]chassisType{
+	"hmcz":     {powerType: "hmcz", required: []string{"username", "password"}},
+	"mscm":     {powerType: "mscm", required: []string{"username", "password"}},
+	"msftocs":  {powerType: "msftocs", required: []string{"username", "password"}, optional: []string{"port"}},
+	"powerkvm": {powerType: "virsh", optional: []string{"password"}},
+	"proxmox":  {powerType: "proxmox", required: []string{"username"}, optional: []string{"password", "token_name", "token_secret", "verify_ssl"}},
+	"recs_box": {powerType: "recs_box", required: []string{"username", "password"}, optional: []string{"port"}},
+	"sm15k":    {powerType: "sm15k", required: []string{"username", "password"}, optional: []string{"power_control"}},
+	"ucsm":     {powerType: "ucsm", required: []string{"username", "password"}},
+	"virsh":    {powerType: "virsh", optional: []string{"password"}},
+	"vmware":   {powerType: "vmware", required: []string{"username", "password"}, optional: []string{"port", "protocol"}},
+}
+
+// chassisCredentials are the chassis type specific arguments, in the order they are validated.
+var chassisCredentials = []string{"username", "password", "port", "power_control", "protocol", "token_name", "token_secret", "verify_ssl"}
+
+func resourceMAASChassis() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to add all the machines of a chassis (virsh, VMware, Proxmox, HMC, MSCM, UCSM, RECS|Box, SeaMicro 15000, PowerKVM...) to MAAS.\n\n**NOTE:** MAAS adds the chassis machines asynchronously. The resource waits until no more new machines are found for the chassis.",
+		CreateContext: resourceChassisCreate,
+		ReadContext:   resourceChassisRead,
+		UpdateContext: resourceChassisUpdate,
+		DeleteContext: resourceChassisDelete,
+
+		Schema: map[string]*schema.Schema{
+			"accept_all": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Commission all the machines added from the chassis.",
+			},
+			"chassis_type": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice(slices.Sorted(maps.Keys(chassisTypes)), false)),
+				Description:      "The chassis type. Valid options are: `hmcz`, `mscm`, `msftocs`, `powerkvm`, `proxmox`, `recs_box`, `sm15k`, `ucsm`, `virsh` and `vmware`.",
+			},
+			"delete_machines_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Delete the machines added from the chassis when the resource is destroyed. Defaults to `false`, which leaves the machines in MAAS.",
+			},
+			"domain": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The domain of the machines added from the chassis.",
+			},
+			"hostname": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The URL, hostname, or IP address used to access the chassis.",
+			},
+			"machines": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "The system IDs of the machines added from the chassis.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "The password used to access the chassis. Required by all the chassis types, except `powerkvm`, `proxmox` and `virsh`.",
+			},
+			"pool": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The resource pool of the machines added from the chassis.",
+			},
+			"port": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IsPortNumber),
+				Description:      "The port used to access the chassis. Only supported by the `msftocs`, `recs_box` and `vmware` chassis types.",
+			},
+			"power_control": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"ipmi", "restapi", "restapi2"}, false)),
+				Description:      "The power control method of the machines. Valid options are: `ipmi`, `restapi` and `restapi2`. Only supported by the `sm15k` chassis type.",
+			},
+			"prefix_filter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Only add the machines whose name starts with this prefix.",
+			},
+			"protocol": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"http", "https"}, false)),
+				Description:      "The protocol used to access the chassis. Valid options are: `http` and `https`. Only supported by the `vmware` chassis type.",
+			},
+			"rack_controller": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The system ID of the rack controller used to access the chassis. By default, all the rack controllers try to add the machines.",
+			},
+			"token_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The name of the API token used to access the chassis. Only supported by the `proxmox` chassis type.",
+			},
+			"token_secret": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "The secret of the API token used to access the chassis. Only supported by the `proxmox` chassis type.",
+			},
+			"username": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The username used to access the chassis. Required by all the chassis types, except `powerkvm` and `virsh`, which do not support it.",
+			},
+			"verify_ssl": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Verify the SSL certificate of the chassis. Only supported by the `proxmox` chassis type.",
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The zone of the machines added from the chassis.",
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+		},
+		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+			t, ok := chassisTypes[d.Get("chassis_type").(string)]
+			if !ok {
+				return nil
+			}
+
+			for _, k := range chassisCredentials {
+				if !d.NewValueKnown(k) {
+					continue
+				}
+
+				_, set := d.GetOk(k)
+
+				switch {
+				case set && !slices.Contains(t.required, k) && !slices.Contains(t.optional, k):
+					return fmt.Errorf("chassis type (%s): '%s' is not supported", d.Get("chassis_type"), k)
+				case !set && slices.Contains(t.required, k):
+					return fmt.Errorf("chassis type (%s): '%s' is required", d.Get("chassis_type"), k)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func resourceChassisCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	// Get the existing machines, to find the ones added from the chassis
+	machines, err := client.Machines.Get(nil)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine.SystemID] = true
+	}
+
+	// Add the chassis
+	if err := machinesOperation(client, "add_chassis", getChassisParams(d), nil); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(d.Get("hostname").(string))
+
+	// Wait for MAAS to add the chassis machines
+	systemIDs, err := waitForChassisMachines(ctx, client, d, known, d.Timeout(schema.TimeoutCreate))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("machines", systemIDs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updateChassisMachines(client, d, systemIDs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceChassisRead(ctx, d, meta)
+}
+
+func resourceChassisRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	systemIDs := []string{}
+
+	for _, systemID := range d.Get("machines").(*schema.Set).List() {
+		if _, err := client.Machine.Get(systemID.(string)); err != nil {
+			if strings.Contains(err.Error(), "404 Not Found") {
+				log.Printf("[DEBUG] Machine (%s) of chassis (%s) was not found\n", systemID, d.Id())
+				continue
+			}
+
+			return diag.FromErr(err)
+		}
+
+		systemIDs = append(systemIDs, systemID.(string))
+	}
+
+	if err := d.Set("machines", systemIDs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceChassisUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if d.HasChanges("domain", "pool", "zone") {
+		systemIDs := convertToStringSlice(d.Get("machines").(*schema.Set).List())
+		if err := updateChassisMachines(client, d, systemIDs); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return resourceChassisRead(ctx, d, meta)
+}
+
+func resourceChassisDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if !d.Get("delete_machines_on_destroy").(bool) {
+		return nil
+	}
+
+	var diags diag.Diagnostics
+
+	for _, systemID := range d.Get("machines").(*schema.Set).List() {
+		if err := client.Machine.Delete(systemID.(string)); err != nil && !strings.Contains(err.Error(), "404 Not Found") {
+			diags = append(diags, diag.Errorf("machine (%s): %s", systemID, err)...)
+		}
+	}
+
+	return diags
+}
+
+func getChassisParams(d *schema.ResourceData) url.Values {
+	params := url.Values{}
+	params.Set("chassis_type", d.Get("chassis_type").(string))
+	params.Set("hostname", d.Get("hostname").(string))
+
+	for _, k := range []string{"domain", "password", "power_control", "prefix_filter", "protocol", "rack_controller", "token_name", "token_secret", "username"} {
+		if v, ok := d.GetOk(k); ok {
+			params.Set(k, v.(string))
+		}
+	}
+
+	if v, ok := d.GetOk("port"); ok {
+		params.Set("port", strconv.Itoa(v.(int)))
+	}
+
+	if d.Get("accept_all").(bool) {
+		params.Set("accept_all", "true")
+	}
+
+	if d.Get("chassis_type").(string) == "proxmox" {
+		params.Set("verify_ssl", strconv.FormatBool(d.Get("verify_ssl").(bool)))
+	}
+
+	return params
+}
+
+// waitForChassisMachines waits for MAAS to add the chassis machines, and returns their
+// system IDs. The chassis machines are the new machines with the power type of the
+// chassis, and a power address with the host of the chassis hostname. MAAS does not report
+// when it is done, so the wait ends when no new machine is found for a minute.
+func waitForChassisMachines(ctx context.Context, client *client.Client, d *schema.ResourceData, known map[string]bool, timeout time.Duration) ([]string, error) {
+	hostname := d.Get("hostname").(string)
+	host := getAddressHost(hostname)
+	powerType := chassisTypes[d.Get("chassis_type").(string)].powerType
+
+	const (
+		pollInterval = 10 * time.Second
+		settleTime   = time.Minute
+	)
+
+	found := []string{}
+	lastFound := time.Now()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		machines, err := client.Machines.Get(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, machine := range machines {
+			if known[machine.SystemID] {
+				continue
+			}
+			// Each machine is only checked once
+			known[machine.SystemID] = true
+
+			if machine.PowerType != powerType {
+				continue
+			}
+
+			powerParams, err := client.Machine.GetPowerParameters(machine.SystemID)
+			if err != nil {
+				return nil, err
+			}
+
+			if address, ok := powerParams["power_address"].(string); ok && strings.EqualFold(getAddressHost(address), host) {
+				log.Printf("[DEBUG] Machine (%s) was added from chassis (%s)\n", machine.SystemID, hostname)
+
+				found = append(found, machine.SystemID)
+				lastFound = time.Now()
+			}
+		}
+
+		if len(found) > 0 && time.Since(lastFound) >= settleTime {
+			return found, nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			if len(found) > 0 {
+				return found, nil
+			}
+
+			return nil, fmt.Errorf("timeout while waiting for machines to be added from chassis (%s)", hostname)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func updateChassisMachines(client *client.Client, d *schema.ResourceData, systemIDs []string) error {
+	params := &entity.MachineUpdateParams{
+		Domain: d.Get("domain").(string),
+		Pool:   d.Get("pool").(string),
+		Zone:   d.Get("zone").(string),
+	}
+	if params.Domain == "" && params.Pool == "" && params.Zone == "" {
+		return nil
+	}
+
+	for _, systemID := range systemIDs {
+		if _, err := client.Machine.Update(systemID, params, map[string]any{}); err != nil {
+			return fmt.Errorf("machine (%s): %w", systemID, err)
+		}
+	}
+
+	return nil
+}
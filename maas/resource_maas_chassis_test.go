@@ -0,0 +1,49 @@
+package maas_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASChassis_credentials(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMAASChassis("vmware", "vcenter.example.com", ""),
+				ExpectError: regexp.MustCompile(`chassis type \(vmware\): 'username' is required`),
+			},
+			{
+				Config:      testAccMAASChassis("virsh", "qemu+ssh://ubuntu@10.0.0.10/system", `username = "ubuntu"`),
+				ExpectError: regexp.MustCompile(`chassis type \(virsh\): 'username' is not supported`),
+			},
+			{
+				Config: testAccMAASChassis("vmware", "vcenter.example.com", `username = "admin"
+  password = "password"
+  protocol = "https"`),
+				// Verify the plan is valid, don't actually add the chassis.
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccMAASChassis(chassisType string, hostname string, credentials string) string {
+	return fmt.Sprintf(`
+resource "maas_chassis" "test" {
+  chassis_type = %q
+  hostname     = %q
+  %s
+}
+`, chassisType, hostname, credentials)
+}
@@ -3,6 +3,12 @@ package maas
 import (
 	"context"
 	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/canonical/gomaasclient/client"
@@ -10,6 +16,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/juju/gomaasapi/v2"
 )
 
 func resourceMAASInstance() *schema.Resource {
@@ -104,6 +111,29 @@ func resourceMAASInstance() *schema.Resource {
 					},
 				},
 			},
+			"allocation_wait": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Wait for a machine matching the allocation constraints to become available, instead of failing when none is available. Allocations done by the provider are queued, so concurrent instances do not compete for the same machines. Parameters defined below.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"poll_interval": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "30s",
+							ValidateDiagFunc: isDuration,
+							Description:      "How often the allocation is retried, as a duration (e.g. `30s`). Defaults to `30s`.",
+						},
+						"timeout": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: isDuration,
+							Description:      "How long to wait for a machine matching the allocation constraints, as a duration (e.g. `30m`).",
+						},
+					},
+				},
+			},
 			"architecture": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -165,6 +195,72 @@ func resourceMAASInstance() *schema.Resource {
 				Computed:    true,
 				Description: "The deployed MAAS machine hostname.",
 			},
+			"interfaces": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The network interfaces of the deployed MAAS machine, as configured by MAAS.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The network interface ID.",
+						},
+						"links": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The subnet links of the network interface.",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"ip_address": {
+										Type:        schema.TypeString,
+										Computed:    true,
+										Description: "The IP address assigned to the link.",
+									},
+									"mode": {
+										Type:        schema.TypeString,
+										Computed:    true,
+										Description: "The link mode.",
+									},
+									"subnet_cidr": {
+										Type:        schema.TypeString,
+										Computed:    true,
+										Description: "The CIDR of the linked subnet.",
+									},
+								},
+							},
+						},
+						"mac_address": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The network interface MAC address.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The network interface name.",
+						},
+						"parents": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The names of the parent network interfaces.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The network interface type (`physical`, `bond`, `bridge` or `vlan`).",
+						},
+						"vid": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The VID of the VLAN the network interface is connected to.",
+						},
+					},
+				},
+			},
 			"ip_addresses": {
 				Type:        schema.TypeSet,
 				Computed:    true,
@@ -178,6 +274,116 @@ func resourceMAASInstance() *schema.Resource {
 				Computed:    true,
 				Description: "The RAM memory size (in GiB) of the deployed MAAS machine.",
 			},
+			"network_bonds": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Specifies a bond interface created on the allocated machine before it is deployed. The parent interfaces are disconnected before the bond is created. Parameters defined below. This argument is processed in [attribute-as-blocks mode](https://www.terraform.io/docs/configuration/attr-as-blocks.html).",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"bond_downdelay": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Specifies the time, in milliseconds, to wait before disabling a slave after a link failure has been detected.",
+						},
+						"bond_lacp_rate": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"fast", "slow"}, false)),
+							Description:      "Option specifying the rate at which to ask the link partner to transmit LACPDU packets in 802.3ad mode. Available options are ``fast`` or ``slow``. (Default: ``slow``).",
+						},
+						"bond_miimon": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "The link monitoring frequency in milliseconds. (Default: 100).",
+						},
+						"bond_mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}, false)),
+							Description:      "The operating mode of the bond. Supported bonding modes are: ``balance-rr``, ``active-backup``, ``balance-xor``, ``broadcast``, ``802.3ad``, ``balance-tlb`` and ``balance-alb``. (Default: ``active-backup``).",
+						},
+						"bond_updelay": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Specifies the time, in milliseconds, to wait before enabling a slave after a link recovery has been detected.",
+						},
+						"bond_xmit_hash_policy": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"layer2", "layer2+3", "layer3+4", "encap2+3", "encap3+4"}, false)),
+							Description:      "The transmit hash policy to use for slave selection in balance-xor, 802.3ad, and tlb modes. Possible values are: ``layer2``, ``layer2+3``, ``layer3+4``, ``encap2+3``, ``encap3+4``. (Default: ``layer2``).",
+						},
+						"links": instanceNetworkLinksSchema(),
+						"mtu":   instanceNetworkMTUSchema(),
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "The bond interface name.",
+						},
+						"parents": {
+							Type:        schema.TypeList,
+							Required:    true,
+							ForceNew:    true,
+							MinItems:    1,
+							Description: "The names of the parent interfaces of the bond.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"network_bridges": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Specifies a bridge interface created on the allocated machine before it is deployed. The parent interface is disconnected before the bridge is created. Parameters defined below. This argument is processed in [attribute-as-blocks mode](https://www.terraform.io/docs/configuration/attr-as-blocks.html).",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"bridge_fd": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Set bridge forward delay to time seconds. (Default: 15).",
+						},
+						"bridge_stp": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Turn spanning tree protocol on or off. (Default: False).",
+						},
+						"bridge_type": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"standard", "ovs"}, false)),
+							Description:      "The type of bridge to create. Possible values are: ``standard``, ``ovs``.",
+						},
+						"links": instanceNetworkLinksSchema(),
+						"mtu":   instanceNetworkMTUSchema(),
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "The bridge interface name.",
+						},
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "The name of the parent interface of the bridge. It can be a physical, bond or VLAN interface.",
+						},
+					},
+				},
+			},
 			"network_interfaces": {
 				Type:        schema.TypeSet,
 				Optional:    true,
@@ -190,8 +396,9 @@ func resourceMAASInstance() *schema.Resource {
 							Optional:         true,
 							ForceNew:         true,
 							ValidateDiagFunc: validation.ToDiagFunc(validation.IsIPAddress),
-							Description:      "Static IP address to be configured on the network interface. If this is set, the `subnet_cidr` is required.\n\n**NOTE:** If both `subnet_cidr` and `ip_address` are not defined, the interface will not be configured on the allocated machine.",
+							Description:      "Static IP address to be configured on the network interface. If this is set, the `subnet_cidr` is required. This cannot be used together with `links`.\n\n**NOTE:** If `subnet_cidr`, `ip_address` and `links` are not defined, the interface will not be configured on the allocated machine.",
 						},
+						"links": instanceNetworkLinksSchema(),
 						"name": {
 							Type:        schema.TypeString,
 							Required:    true,
@@ -202,55 +409,129 @@ func resourceMAASInstance() *schema.Resource {
 							Type:        schema.TypeString,
 							Optional:    true,
 							ForceNew:    true,
-							Description: "An existing subnet CIDR used to configure the network interface. Unless `ip_address` is defined, a free IP address is allocated from the subnet.",
+							Description: "An existing subnet CIDR used to configure the network interface. Unless `ip_address` is defined, a free IP address is allocated from the subnet. This cannot be used together with `links`.",
 						},
 					},
 				},
 			},
+			"network_vlans": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Specifies a VLAN interface created on the allocated machine before it is deployed. The interface is named `<parent>.<vid>`. Parameters defined below. This argument is processed in [attribute-as-blocks mode](https://www.terraform.io/docs/configuration/attr-as-blocks.html).",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"fabric": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "The identifier (name or ID) of the fabric of the VLAN. Defaults to the fabric of the parent interface.",
+						},
+						"links": instanceNetworkLinksSchema(),
+						"mtu":   instanceNetworkMTUSchema(),
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "The name of the parent interface of the VLAN. It can be a physical, bond or bridge interface.",
+						},
+						"vid": {
+							Type:             schema.TypeInt,
+							Required:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.IntBetween(0, 4094)),
+							Description:      "The VID of the VLAN.",
+						},
+					},
+				},
+			},
+			"owner_data": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Key/value owner data set on the allocated machine. Keys removed from this map are removed from the machine. MAAS clears the owner data when the machine is released.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"pool": {
 				Type:        schema.TypeString,
 				Computed:    true,
 				Description: "The deployed MAAS machine pool name.",
 			},
-			"release_params": {
+			"release_params": machineReleaseParamsSchema(),
+			"storage_layout": {
 				Type:        schema.TypeList,
 				Optional:    true,
+				ForceNew:    true,
 				MaxItems:    1,
-				Description: "Parameters used to release the allocated machine when the resource is destroyed.",
+				Description: "Nested argument with the storage layout applied to the allocated machine before it is deployed. If it's not given, the MAAS server default storage layout is used. Defined below.",
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"comment": {
+						"boot_size_gigabytes": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "The size of the boot partition (in GB). Not used by the `blank` and `custom` layouts.",
+						},
+						"cache_device": {
 							Type:        schema.TypeString,
 							Optional:    true,
-							Description: "A comment to be added to the event log when the machine is released.",
+							ForceNew:    true,
+							Description: "The name or ID of the physical block device used as the cache device. Only used by the `bcache` layout.",
 						},
-						"erase": {
-							Type:        schema.TypeBool,
-							Optional:    true,
-							Description: "Erase the disk when releasing.",
+						"cache_mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"writeback", "writethrough", "writearound"}, false)),
+							Description:      "The cache mode of the bcache device. Valid options are: `writeback`, `writethrough` and `writearound`. Only used by the `bcache` layout.",
 						},
-						"force": {
+						"cache_no_part": {
 							Type:        schema.TypeBool,
 							Optional:    true,
-							Description: "Force the release of the machine.",
+							ForceNew:    true,
+							Description: "Whether to use the whole cache device instead of a partition on it. Only used by the `bcache` layout.",
 						},
-						"quick_erase": {
-							Type:        schema.TypeBool,
+						"cache_size_gigabytes": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "The size of the cache partition (in GB). Only used by the `bcache` layout.",
+						},
+						"lv_size_gigabytes": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "The size of the root logical volume (in GB). Only used by the `lvm` layout.",
+						},
+						"root_device": {
+							Type:        schema.TypeString,
 							Optional:    true,
-							Description: "Use quick erase. Wipe 2MiB at the start and at the end of the drive to make data recovery inconvenient and unlikely to happen by accident. This is not secure.",
+							ForceNew:    true,
+							Description: "The name or ID of the physical block device the layout is created on. If it's not given, the boot disk is used.",
 						},
-						"scripts": {
-							Type:     schema.TypeList,
-							Optional: true,
-							Elem: &schema.Schema{
-								Type: schema.TypeString,
-							},
-							Description: "List of the names of existing node release scripts to run when releasing the machine. These scripts run on an ephemeral copy of Ubuntu that is loaded after the deployed OS has been shut down. Only available in MAAS 3.5 and later.",
+						"root_size_gigabytes": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "The size of the root partition (in GB). Not used by the `blank` and `custom` layouts.",
 						},
-						"secure_erase": {
-							Type:        schema.TypeBool,
+						"type": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"flat", "lvm", "bcache", "vmfs6", "vmfs7", "blank", "custom"}, false)),
+							Description:      "The storage layout type. Valid options are: `flat`, `lvm`, `bcache`, `vmfs6`, `vmfs7`, `blank` and `custom`.",
+						},
+						"vg_name": {
+							Type:        schema.TypeString,
 							Optional:    true,
-							Description: "Use the drive's secure erase feature if available.  In some cases, this can be much faster than overwriting the drive. Some drives implement secure erasure by overwriting themselves so this could still be slow.",
+							ForceNew:    true,
+							Description: "The name of the volume group. Only used by the `lvm` layout.",
 						},
 					},
 				},
@@ -263,6 +544,23 @@ func resourceMAASInstance() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			"wait_for_cloud_init": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Wait for cloud-init to finish on the deployed machine, based on the MAAS machine events, before the instance is considered created. Parameters defined below.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"timeout": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "30m",
+							ValidateDiagFunc: isDuration,
+							Description:      "How long to wait for cloud-init to finish after the machine is deployed, as a duration (e.g. `30m`). Defaults to `30m`.",
+						},
+					},
+				},
+			},
 			"zone": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -274,33 +572,7 @@ func resourceMAASInstance() *schema.Resource {
 			Delete: schema.DefaultTimeout(30 * time.Minute),
 		},
 		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
-			p, ok := d.GetOk("release_params")
-			if !ok {
-				return nil
-			}
-
-			releaseParamsData := p.([]any)
-			if releaseParamsData[0] == nil {
-				return nil
-			}
-
-			releaseParams := releaseParamsData[0].(map[string]any)
-
-			scripts, ok := releaseParams["scripts"]
-			if !ok {
-				return nil
-			}
-
-			if len(scripts.([]interface{})) == 0 {
-				return nil
-			}
-
-			err := checkSemverConstraint(meta.(*ClientConfig).MAASVersion, ">=3.5.0")
-			if err != nil {
-				return err
-			}
-
-			return nil
+			return validateReleaseParams(d, meta)
 		},
 	}
 }
@@ -308,8 +580,19 @@ func resourceMAASInstance() *schema.Resource {
 func resourceInstanceCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
 	client := meta.(*ClientConfig).Client
 
+	// Validate the storage layout before allocating the machine
+	storageLayoutParams, err := getMachineStorageLayoutParams(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Validate the network links before allocating the machine
+	if _, err := getInstanceNetworkLinks(d, getInstanceVirtualInterfaces(d)); err != nil {
+		return diag.FromErr(err)
+	}
+
 	// Allocate MAAS machine
-	machine, err := client.Machines.Allocate(getMachinesAllocateParams(d))
+	machine, err := allocateMachine(ctx, meta.(*ClientConfig), d)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -317,12 +600,35 @@ func resourceInstanceCreate(ctx context.Context, d *schema.ResourceData, meta an
 	// Save system id
 	d.SetId(machine.SystemID)
 
+	// Set owner data
+	if err := setMachineOwnerData(client, machine.SystemID, nil, d.Get("owner_data").(map[string]any)); err != nil {
+		return diag.FromErr(err)
+	}
+
 	// Configure network interfaces
 	err = configureInstanceNetworkInterfaces(client, d, machine)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	// Configure storage layout
+	if storageLayoutParams != nil {
+		if err := machineOperation(client, machine.SystemID, "set_storage_layout", storageLayoutParams, nil); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Watch the machine events from before the deployment, so that no cloud-init event is missed
+	cloudInitTimeout, err := getCloudInitTimeout(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var cloudInitEvents *machineEventsWatcher
+	if cloudInitTimeout > 0 {
+		cloudInitEvents = newMachineEventsWatcher(client, machine.SystemID)
+	}
+
 	// Deploy MAAS machine
 	machine, err = client.Machine.Deploy(machine.SystemID, getMachineDeployParams(d))
 	if err != nil {
@@ -335,6 +641,13 @@ func resourceInstanceCreate(ctx context.Context, d *schema.ResourceData, meta an
 		return diag.FromErr(err)
 	}
 
+	// Wait for cloud-init to finish
+	if cloudInitEvents != nil {
+		if err := waitForCloudInit(ctx, cloudInitEvents, cloudInitTimeout); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	// Read MAAS machine info
 	return resourceInstanceRead(ctx, d, meta)
 }
@@ -353,6 +666,11 @@ func resourceInstanceRead(ctx context.Context, d *schema.ResourceData, meta any)
 		ipAddresses[i] = ip.String()
 	}
 
+	networkInterfaces, err := client.NetworkInterfaces.Get(machine.SystemID)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	tfState := map[string]any{
 		"architecture": machine.Architecture,
 		"fqdn":         machine.FQDN,
@@ -363,6 +681,8 @@ func resourceInstanceRead(ctx context.Context, d *schema.ResourceData, meta any)
 		"cpu_count":    machine.CPUCount,
 		"memory":       machine.Memory,
 		"ip_addresses": ipAddresses,
+		"interfaces":   getInstanceInterfaces(networkInterfaces),
+		"owner_data":   getMachineOwnerData(machine),
 	}
 	if err := setTerraformState(d, tfState); err != nil {
 		return diag.FromErr(err)
@@ -372,7 +692,16 @@ func resourceInstanceRead(ctx context.Context, d *schema.ResourceData, meta any)
 }
 
 func resourceInstanceUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
-	// Dummy update to allow release params to be updated.
+	client := meta.(*ClientConfig).Client
+
+	// Update owner data. Other changes are only for release params, which are used on destroy.
+	if d.HasChange("owner_data") {
+		o, n := d.GetChange("owner_data")
+		if err := setMachineOwnerData(client, d.Id(), o.(map[string]any), n.(map[string]any)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	return resourceInstanceRead(ctx, d, meta)
 }
 
@@ -396,6 +725,96 @@ func resourceInstanceDelete(ctx context.Context, d *schema.ResourceData, meta an
 	return nil
 }
 
+// allocateMachine allocates a machine through the provider allocation queue. If
+// allocation_wait is set, the allocation is retried while no machine matches the
+// constraints, until the wait timeout is reached.
+func allocateMachine(ctx context.Context, config *ClientConfig, d *schema.ResourceData) (*entity.Machine, error) {
+	params := getMachinesAllocateParams(d)
+
+	timeout, pollInterval, err := getAllocationWait(d)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		machine, err := allocateMachineQueued(ctx, config, params)
+		if err == nil {
+			return machine, nil
+		}
+
+		if timeout == 0 || !isNoMatchingMachineError(err) {
+			return nil, err
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return nil, fmt.Errorf("timeout after waiting %s for a machine matching the allocation constraints: %w", timeout, err)
+		}
+		// The MAAS error message contains the unmet constraints
+		log.Printf("[INFO] No machine available for allocation, retrying in %s: %s", pollInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func allocateMachineQueued(ctx context.Context, config *ClientConfig, params *entity.MachineAllocateParams) (*entity.Machine, error) {
+	select {
+	case config.AllocationQueue <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	defer func() { <-config.AllocationQueue }()
+
+	return config.Client.Machines.Allocate(params)
+}
+
+func isNoMatchingMachineError(err error) bool {
+	serverErr, ok := gomaasapi.GetServerError(err)
+
+	return ok && serverErr.StatusCode == http.StatusConflict
+}
+
+func getAllocationWait(d *schema.ResourceData) (time.Duration, time.Duration, error) {
+	p, ok := d.GetOk("allocation_wait")
+	if !ok || p.([]any)[0] == nil {
+		return 0, 0, nil
+	}
+
+	allocationWait := p.([]any)[0].(map[string]any)
+
+	timeout, err := time.ParseDuration(allocationWait["timeout"].(string))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pollInterval, err := time.ParseDuration(allocationWait["poll_interval"].(string))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return timeout, pollInterval, nil
+}
+
+func getCloudInitTimeout(d *schema.ResourceData) (time.Duration, error) {
+	p, ok := d.GetOk("wait_for_cloud_init")
+	if !ok {
+		return 0, nil
+	}
+	// An empty block uses the default timeout
+	waitForCloudInit, ok := p.([]any)[0].(map[string]any)
+	if !ok {
+		return 30 * time.Minute, nil
+	}
+
+	return time.ParseDuration(waitForCloudInit["timeout"].(string))
+}
+
 func getMachinesAllocateParams(d *schema.ResourceData) *entity.MachineAllocateParams {
 	if p, ok := d.GetOk("allocate_params"); ok {
 		allocateParamsData := p.([]any)
@@ -437,6 +856,86 @@ func getMachineDeployParams(d *schema.ResourceData) *entity.MachineDeployParams
 	return &entity.MachineDeployParams{}
 }
 
+// machineReleaseParamsSchema returns the schema of the parameters used to
+// release a machine when the resource is destroyed.
+func machineReleaseParamsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Parameters used to release the allocated machine when the resource is destroyed.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"comment": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "A comment to be added to the event log when the machine is released.",
+				},
+				"erase": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Description: "Erase the disk when releasing.",
+				},
+				"force": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Description: "Force the release of the machine.",
+				},
+				"quick_erase": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Description: "Use quick erase. Wipe 2MiB at the start and at the end of the drive to make data recovery inconvenient and unlikely to happen by accident. This is not secure.",
+				},
+				"scripts": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+					Description: "List of the names of existing node release scripts to run when releasing the machine. These scripts run on an ephemeral copy of Ubuntu that is loaded after the deployed OS has been shut down. Only available in MAAS 3.5 and later.",
+				},
+				"secure_erase": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Description: "Use the drive's secure erase feature if available.  In some cases, this can be much faster than overwriting the drive. Some drives implement secure erasure by overwriting themselves so this could still be slow.",
+				},
+			},
+		},
+	}
+}
+
+// validateReleaseParams checks that the release parameters are supported by
+// the MAAS server.
+func validateReleaseParams(d *schema.ResourceDiff, meta any) error {
+	p, ok := d.GetOk("release_params")
+	if !ok {
+		return nil
+	}
+
+	releaseParamsData := p.([]any)
+	if releaseParamsData[0] == nil {
+		return nil
+	}
+
+	releaseParams := releaseParamsData[0].(map[string]any)
+
+	scripts, ok := releaseParams["scripts"]
+	if !ok {
+		return nil
+	}
+
+	if len(scripts.([]interface{})) == 0 {
+		return nil
+	}
+
+	err := checkSemverConstraint(meta.(*ClientConfig).MAASVersion, ">=3.5.0")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func getReleaseParams(d *schema.ResourceData) *entity.MachineReleaseParams {
 	if p, ok := d.GetOk("release_params"); ok {
 		releaseParamsData := p.([]any)
@@ -461,56 +960,476 @@ func getReleaseParams(d *schema.ResourceData) *entity.MachineReleaseParams {
 	return &entity.MachineReleaseParams{}
 }
 
-func configureInstanceNetworkInterfaces(client *client.Client, d *schema.ResourceData, machine *entity.Machine) error {
+func getMachineStorageLayoutParams(d *schema.ResourceData) (url.Values, error) {
+	p, ok := d.GetOk("storage_layout")
+	if !ok {
+		return nil, nil
+	}
+
+	storageLayoutData := p.([]any)
+	if storageLayoutData[0] == nil {
+		return nil, nil
+	}
+
+	storageLayout := storageLayoutData[0].(map[string]any)
+	layoutType := storageLayout["type"].(string)
+
+	// Reject the options that MAAS would silently ignore for the given layout
+	layoutOptions := []struct {
+		name    string
+		layouts []string
+	}{
+		{"boot_size_gigabytes", []string{"flat", "lvm", "bcache", "vmfs6", "vmfs7"}},
+		{"root_size_gigabytes", []string{"flat", "lvm", "bcache", "vmfs6", "vmfs7"}},
+		{"vg_name", []string{"lvm"}},
+		{"lv_size_gigabytes", []string{"lvm"}},
+		{"cache_device", []string{"bcache"}},
+		{"cache_mode", []string{"bcache"}},
+		{"cache_size_gigabytes", []string{"bcache"}},
+		{"cache_no_part", []string{"bcache"}},
+	}
+	for _, option := range layoutOptions {
+		if slices.Contains(option.layouts, layoutType) {
+			continue
+		}
+
+		isSet := false
+
+		switch v := storageLayout[option.name].(type) {
+		case string:
+			isSet = v != ""
+		case int:
+			isSet = v != 0
+		case bool:
+			isSet = v
+		}
+
+		if isSet {
+			return nil, fmt.Errorf("storage layout (%s): '%s' is only supported by the following layouts: %s", layoutType, option.name, strings.Join(option.layouts, ", "))
+		}
+	}
+
+	params := url.Values{}
+	params.Set("storage_layout", layoutType)
+
+	if rootDevice := storageLayout["root_device"].(string); rootDevice != "" {
+		params.Set("root_device", rootDevice)
+	}
+
+	if vgName := storageLayout["vg_name"].(string); vgName != "" {
+		params.Set("vg_name", vgName)
+	}
+
+	if cacheDevice := storageLayout["cache_device"].(string); cacheDevice != "" {
+		params.Set("cache_device", cacheDevice)
+	}
+
+	if cacheMode := storageLayout["cache_mode"].(string); cacheMode != "" {
+		params.Set("cache_mode", cacheMode)
+	}
+
+	if storageLayout["cache_no_part"].(bool) {
+		params.Set("cache_no_part", "true")
+	}
+
+	sizes := map[string]string{
+		"boot_size_gigabytes":  "boot_size",
+		"root_size_gigabytes":  "root_size",
+		"lv_size_gigabytes":    "lv_size",
+		"cache_size_gigabytes": "cache_size",
+	}
+	for option, param := range sizes {
+		if size := storageLayout[option].(int); size > 0 {
+			params.Set(param, strconv.FormatInt(int64(size)*GigaBytes, 10))
+		}
+	}
+
+	return params, nil
+}
+
+func instanceNetworkLinksSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		ForceNew:    true,
+		Description: "Subnet links configured on the network interface, in the given order. Existing links of the interface are removed first. Parameters defined below.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"default_gateway": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					ForceNew:    true,
+					Description: "Use the gateway of the subnet as the default gateway of the machine. Only supported by the `AUTO` and `STATIC` modes.",
+				},
+				"ip_address": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					ForceNew:         true,
+					ValidateDiagFunc: validation.ToDiagFunc(validation.IsIPAddress),
+					Description:      "Static IP address of the link. Only supported by the `STATIC` mode.",
+				},
+				"mode": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					ForceNew:         true,
+					ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"AUTO", "DHCP", "STATIC", "LINK_UP"}, false)),
+					Description:      "The link mode. Valid options are: `AUTO`, `DHCP`, `STATIC` and `LINK_UP`. Defaults to `STATIC` when `ip_address` is set, and to `AUTO` otherwise.",
+				},
+				"subnet_cidr": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					ForceNew:    true,
+					Description: "An existing subnet CIDR to link the network interface to. It is required by the `AUTO` and `STATIC` modes.",
+				},
+			},
+		},
+	}
+}
+
+func instanceNetworkMTUSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeInt,
+		Optional:    true,
+		ForceNew:    true,
+		Description: "The MTU of the network interface.",
+	}
+}
+
+// instanceVirtualInterface is a bond, bridge or VLAN interface declared inline on a maas_instance.
+type instanceVirtualInterface struct {
+	config  map[string]any
+	kind    string
+	name    string
+	parents []string
+}
+
+func getInstanceVirtualInterfaces(d *schema.ResourceData) []instanceVirtualInterface {
+	virtualInterfaces := []instanceVirtualInterface{}
+	for _, bond := range d.Get("network_bonds").(*schema.Set).List() {
+		b := bond.(map[string]any)
+		virtualInterfaces = append(virtualInterfaces, instanceVirtualInterface{
+			config:  b,
+			kind:    "bond",
+			name:    b["name"].(string),
+			parents: convertToStringSlice(b["parents"].([]any)),
+		})
+	}
+
+	for _, bridge := range d.Get("network_bridges").(*schema.Set).List() {
+		b := bridge.(map[string]any)
+		virtualInterfaces = append(virtualInterfaces, instanceVirtualInterface{
+			config:  b,
+			kind:    "bridge",
+			name:    b["name"].(string),
+			parents: []string{b["parent"].(string)},
+		})
+	}
+
+	for _, vlan := range d.Get("network_vlans").(*schema.Set).List() {
+		v := vlan.(map[string]any)
+		virtualInterfaces = append(virtualInterfaces, instanceVirtualInterface{
+			config:  v,
+			kind:    "vlan",
+			name:    fmt.Sprintf("%s.%d", v["parent"].(string), v["vid"].(int)),
+			parents: []string{v["parent"].(string)},
+		})
+	}
+
+	return virtualInterfaces
+}
+
+// getInstanceNetworkLinks returns the subnet links to be configured on each network
+// interface, keyed by interface name. Interfaces present in the result are disconnected
+// before their links are created.
+func getInstanceNetworkLinks(d *schema.ResourceData, virtualInterfaces []instanceVirtualInterface) (map[string][]map[string]any, error) {
+	networkLinks := map[string][]map[string]any{}
 	for _, networkInterface := range d.Get("network_interfaces").(*schema.Set).List() {
 		n := networkInterface.(map[string]any)
-		// Find the machine network interface
 		name := n["name"].(string)
+		subnetCIDR := n["subnet_cidr"].(string)
+		ipAddress := n["ip_address"].(string)
+		links := n["links"].([]any)
+
+		if len(links) > 0 && (subnetCIDR != "" || ipAddress != "") {
+			return nil, fmt.Errorf("network interface (%s): 'subnet_cidr' and 'ip_address' cannot be used together with 'links'", name)
+		}
+
+		if subnetCIDR == "" && ipAddress != "" {
+			return nil, fmt.Errorf("network interface (%s): 'subnet_cidr' is required when 'ip_address' is set", name)
+		}
+
+		networkLinks[name] = []map[string]any{}
+		if subnetCIDR != "" {
+			networkLinks[name] = append(networkLinks[name], map[string]any{
+				"default_gateway": false,
+				"ip_address":      ipAddress,
+				"mode":            "",
+				"subnet_cidr":     subnetCIDR,
+			})
+		}
 
+		for _, link := range links {
+			networkLinks[name] = append(networkLinks[name], link.(map[string]any))
+		}
+	}
+
+	for _, v := range virtualInterfaces {
+		if _, ok := networkLinks[v.name]; ok {
+			return nil, fmt.Errorf("network interface (%s): the interface is declared more than once", v.name)
+		}
+
+		links := v.config["links"].([]any)
+		if len(links) == 0 {
+			continue
+		}
+
+		networkLinks[v.name] = []map[string]any{}
+		for _, link := range links {
+			networkLinks[v.name] = append(networkLinks[v.name], link.(map[string]any))
+		}
+	}
+
+	for name, links := range networkLinks {
+		for _, link := range links {
+			if _, err := getInstanceNetworkLinkMode(name, link); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return networkLinks, nil
+}
+
+func getInstanceNetworkLinkMode(name string, link map[string]any) (string, error) {
+	mode := link["mode"].(string)
+	ipAddress := link["ip_address"].(string)
+
+	if mode == "" {
+		mode = "AUTO"
+		if ipAddress != "" {
+			mode = "STATIC"
+		}
+	}
+
+	if ipAddress != "" && mode != "STATIC" {
+		return "", fmt.Errorf("network interface (%s): 'ip_address' is only supported by the STATIC link mode", name)
+	}
+
+	if link["default_gateway"].(bool) && mode != "AUTO" && mode != "STATIC" {
+		return "", fmt.Errorf("network interface (%s): 'default_gateway' is only supported by the AUTO and STATIC link modes", name)
+	}
+
+	if link["subnet_cidr"].(string) == "" && (mode == "AUTO" || mode == "STATIC") {
+		return "", fmt.Errorf("network interface (%s): 'subnet_cidr' is required by the %s link mode", name, mode)
+	}
+
+	return mode, nil
+}
+
+func configureInstanceNetworkInterfaces(client *client.Client, d *schema.ResourceData, machine *entity.Machine) error {
+	virtualInterfaces := getInstanceVirtualInterfaces(d)
+
+	networkLinks, err := getInstanceNetworkLinks(d, virtualInterfaces)
+	if err != nil {
+		return err
+	}
+	// Create the bonds, bridges and VLANs after their parents
+	if err := createInstanceVirtualInterfaces(client, machine.SystemID, virtualInterfaces); err != nil {
+		return err
+	}
+	// Configure the links in a stable order
+	names := make([]string, 0, len(networkLinks))
+	for name := range networkLinks {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		// Find the machine network interface
 		nic, err := getNetworkInterface(client, machine.SystemID, name)
 		if err != nil {
 			return err
 		}
-		// Validate the given network configs
-		subnetCIDR := n["subnet_cidr"].(string)
-		ipAddress := n["ip_address"].(string)
+		// Clear existing network interface links
+		// This will leave the network interface disconnected if no links are given
+		if _, err = client.NetworkInterface.Disconnect(machine.SystemID, nic.ID); err != nil {
+			return err
+		}
+		// Create new network interface links
+		for _, link := range networkLinks[name] {
+			mode, err := getInstanceNetworkLinkMode(name, link)
+			if err != nil {
+				return err
+			}
 
-		if subnetCIDR == "" {
-			if ipAddress != "" {
-				return fmt.Errorf("network interface (%s): 'subnet_cidr' is required when 'ip_address' is set", name)
+			params := entity.NetworkInterfaceLinkParams{
+				Mode:           mode,
+				IPAddress:      link["ip_address"].(string),
+				DefaultGateway: link["default_gateway"].(bool),
 			}
-			// Clear existing network interface links
-			// This will leave the network interface disconnected
-			if _, err = client.NetworkInterface.Disconnect(machine.SystemID, nic.ID); err != nil {
-				return err
+
+			if subnetCIDR := link["subnet_cidr"].(string); subnetCIDR != "" {
+				subnet, err := getSubnet(client, subnetCIDR)
+				if err != nil {
+					return err
+				}
+
+				params.Subnet = subnet.ID
 			}
 
-			continue
+			if _, err = client.NetworkInterface.LinkSubnet(machine.SystemID, nic.ID, &params); err != nil {
+				return err
+			}
 		}
-		// Find the subnet
-		subnet, err := getSubnet(client, subnetCIDR)
+	}
+
+	return nil
+}
+
+func createInstanceVirtualInterfaces(client *client.Client, systemID string, virtualInterfaces []instanceVirtualInterface) error {
+	pending := virtualInterfaces
+	for len(pending) > 0 {
+		networkInterfaces, err := client.NetworkInterfaces.Get(systemID)
 		if err != nil {
 			return err
 		}
-		// Clear existing network interface links
-		if _, err = client.NetworkInterface.Disconnect(machine.SystemID, nic.ID); err != nil {
-			return err
+
+		existing := map[string]*entity.NetworkInterface{}
+		for i := range networkInterfaces {
+			existing[networkInterfaces[i].Name] = &networkInterfaces[i]
 		}
-		// Create new network interface link
-		mode := "AUTO"
-		if ipAddress != "" {
-			mode = "STATIC"
+
+		blocked := []instanceVirtualInterface{}
+
+		for _, v := range pending {
+			parents := []*entity.NetworkInterface{}
+
+			for _, name := range v.parents {
+				if parent, ok := existing[name]; ok {
+					parents = append(parents, parent)
+				}
+			}
+
+			if len(parents) != len(v.parents) {
+				blocked = append(blocked, v)
+				continue
+			}
+
+			if err := createInstanceVirtualInterface(client, systemID, v, parents); err != nil {
+				return err
+			}
+		}
+		// No interface could be created in this pass, so the remaining parents do not exist
+		if len(blocked) == len(pending) {
+			names := make([]string, len(blocked))
+			for i, v := range blocked {
+				names[i] = v.name
+			}
+
+			return fmt.Errorf("network interfaces (%s): unable to find the parent interfaces", strings.Join(names, ", "))
+		}
+
+		pending = blocked
+	}
+
+	return nil
+}
+
+func createInstanceVirtualInterface(client *client.Client, systemID string, v instanceVirtualInterface, parents []*entity.NetworkInterface) error {
+	parentIDs := make([]int, len(parents))
+	for i, parent := range parents {
+		parentIDs[i] = parent.ID
+	}
+
+	switch v.kind {
+	case "bond", "bridge":
+		// Bond and bridge parents must not have any links
+		for _, parent := range parents {
+			if _, err := client.NetworkInterface.Disconnect(systemID, parent.ID); err != nil {
+				return err
+			}
 		}
+	}
+
+	var err error
 
-		params := entity.NetworkInterfaceLinkParams{
-			Mode:      mode,
-			Subnet:    subnet.ID,
-			IPAddress: ipAddress,
+	switch v.kind {
+	case "bond":
+		_, err = client.NetworkInterfaces.CreateBond(systemID, &entity.NetworkInterfaceBondParams{
+			Name:               v.name,
+			Parents:            parentIDs,
+			BondDownDelay:      v.config["bond_downdelay"].(int),
+			BondLACPRate:       v.config["bond_lacp_rate"].(string),
+			BondMiimon:         v.config["bond_miimon"].(int),
+			BondMode:           v.config["bond_mode"].(string),
+			BondUpDelay:        v.config["bond_updelay"].(int),
+			BondXMitHashPolicy: v.config["bond_xmit_hash_policy"].(string),
+			MTU:                v.config["mtu"].(int),
+		})
+	case "bridge":
+		_, err = client.NetworkInterfaces.CreateBridge(systemID, &entity.NetworkInterfaceBridgeParams{
+			Name:       v.name,
+			Parents:    parentIDs,
+			BridgeFD:   v.config["bridge_fd"].(int),
+			BridgeSTP:  v.config["bridge_stp"].(bool),
+			BridgeType: v.config["bridge_type"].(string),
+			MTU:        v.config["mtu"].(int),
+		})
+	case "vlan":
+		fabricID := parents[0].VLAN.FabricID
+		if fabric := v.config["fabric"].(string); fabric != "" {
+			f, err := getFabric(client, fabric)
+			if err != nil {
+				return err
+			}
+
+			fabricID = f.ID
 		}
-		if _, err = client.NetworkInterface.LinkSubnet(machine.SystemID, nic.ID, &params); err != nil {
+
+		var vlan *entity.VLAN
+
+		vlan, err = getVLAN(client, fabricID, strconv.Itoa(v.config["vid"].(int)))
+		if err != nil {
 			return err
 		}
+
+		_, err = client.NetworkInterfaces.CreateVLAN(systemID, &entity.NetworkInterfaceVLANParams{
+			Parents: parentIDs,
+			VLAN:    vlan.ID,
+			MTU:     v.config["mtu"].(int),
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("network interface (%s): %w", v.name, err)
 	}
 
 	return nil
 }
+
+func getInstanceInterfaces(networkInterfaces []entity.NetworkInterface) []map[string]any {
+	interfaces := make([]map[string]any, len(networkInterfaces))
+	for i, networkInterface := range networkInterfaces {
+		links := make([]map[string]any, len(networkInterface.Links))
+		for j, link := range networkInterface.Links {
+			links[j] = map[string]any{
+				"ip_address":  link.IPAddress,
+				"mode":        strings.ToUpper(link.Mode),
+				"subnet_cidr": link.Subnet.CIDR,
+			}
+		}
+
+		interfaces[i] = map[string]any{
+			"id":          networkInterface.ID,
+			"links":       links,
+			"mac_address": networkInterface.MACAddress,
+			"name":        networkInterface.Name,
+			"parents":     networkInterface.Parents,
+			"type":        networkInterface.Type,
+			"vid":         networkInterface.VLAN.VID,
+		}
+	}
+
+	return interfaces
+}
@@ -116,6 +116,174 @@ func TestAccResourceMAASInstance_basic(t *testing.T) {
 	})
 }
 
+func TestAccResourceMAASInstance_storageLayout(t *testing.T) {
+	vmHost := os.Getenv("TF_ACC_VM_HOST_ID")
+	hostname := acctest.RandomWithPrefix("tf-instance")
+	vgName := "tf-vg"
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VM_HOST_ID"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccMAASInstanceCheckDestroy,
+		Steps: []resource.TestStep{
+			// Test invalid layout options are rejected before allocation
+			{
+				Config:      testAccMAASInstanceConfigStorageLayout(vmHost, hostname, "flat", vgName),
+				ExpectError: regexp.MustCompile("'vg_name' is only supported by the following layouts: lvm"),
+			},
+			// Test creation with an LVM layout
+			{
+				Config: testAccMAASInstanceConfigStorageLayout(vmHost, hostname, "lvm", vgName),
+				Check: resource.ComposeTestCheckFunc(
+					testAccMAASInstanceCheckExists("maas_instance.test"),
+					resource.TestCheckResourceAttr("maas_instance.test", "storage_layout.#", "1"),
+					resource.TestCheckResourceAttr("maas_instance.test", "storage_layout.0.type", "lvm"),
+					testAccMAASInstanceCheckVolumeGroup("maas_instance.test", vgName),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASInstance_networkConfig(t *testing.T) {
+	vmHost := os.Getenv("TF_ACC_VM_HOST_ID")
+	hostname := acctest.RandomWithPrefix("tf-instance")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VM_HOST_ID"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccMAASInstanceCheckDestroy,
+		Steps: []resource.TestStep{
+			// Test invalid links are rejected before allocation
+			{
+				Config:      testAccMAASInstanceConfigNetworkBridge(vmHost, hostname, "DHCP", "10.0.0.10"),
+				ExpectError: regexp.MustCompile("'ip_address' is only supported by the STATIC link mode"),
+			},
+			// Test creation with a bridge on the boot interface
+			{
+				Config: testAccMAASInstanceConfigNetworkBridge(vmHost, hostname, "DHCP", ""),
+				Check: resource.ComposeTestCheckFunc(
+					testAccMAASInstanceCheckExists("maas_instance.test"),
+					resource.TestCheckTypeSetElemNestedAttrs("maas_instance.test", "interfaces.*", map[string]string{
+						"name":         "br0",
+						"type":         "bridge",
+						"parents.0":    "enp5s0",
+						"links.0.mode": "DHCP",
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASInstance_ownerData(t *testing.T) {
+	vmHost := os.Getenv("TF_ACC_VM_HOST_ID")
+	hostname := acctest.RandomWithPrefix("tf-instance")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VM_HOST_ID"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccMAASInstanceCheckDestroy,
+		Steps: []resource.TestStep{
+			// Test creation with owner data
+			{
+				Config: testAccMAASInstanceConfigOwnerData(vmHost, hostname, `{ workload = "db", team = "storage" }`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccMAASInstanceCheckExists("maas_instance.test"),
+					resource.TestCheckResourceAttr("maas_instance.test", "owner_data.%", "2"),
+					resource.TestCheckResourceAttr("maas_instance.test", "owner_data.workload", "db"),
+					resource.TestCheckResourceAttr("maas_instance.test", "owner_data.team", "storage"),
+				),
+			},
+			// Test owner data is updated in place, and removed keys are cleared
+			{
+				Config: testAccMAASInstanceConfigOwnerData(vmHost, hostname, `{ workload = "web" }`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_instance.test", "owner_data.%", "1"),
+					resource.TestCheckResourceAttr("maas_instance.test", "owner_data.workload", "web"),
+					resource.TestCheckResourceAttr("data.maas_machines.test", "machines.#", "1"),
+					resource.TestCheckResourceAttrPair("data.maas_machine.test", "id", "maas_instance.test", "id"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASInstance_allocationWait(t *testing.T) {
+	vmHost := os.Getenv("TF_ACC_VM_HOST_ID")
+	hostname := acctest.RandomWithPrefix("tf-instance")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VM_HOST_ID"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccMAASInstanceCheckDestroy,
+		Steps: []resource.TestStep{
+			// Test the allocation is retried until the wait timeout
+			{
+				Config:      testAccMAASInstanceConfigAllocationWait(vmHost, hostname, 1024*1024*1024),
+				ExpectError: regexp.MustCompile("timeout after waiting 30s for a machine matching the allocation constraints"),
+			},
+			// Test the allocation succeeds when a machine is available
+			{
+				Config: testAccMAASInstanceConfigAllocationWait(vmHost, hostname, 4000),
+				Check: resource.ComposeTestCheckFunc(
+					testAccMAASInstanceCheckExists("maas_instance.test"),
+					resource.TestCheckResourceAttr("maas_instance.test", "allocation_wait.0.timeout", "30s"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASInstance_waitForCloudInit(t *testing.T) {
+	vmHost := os.Getenv("TF_ACC_VM_HOST_ID")
+	hostname := acctest.RandomWithPrefix("tf-instance")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VM_HOST_ID"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccMAASInstanceCheckDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASInstanceConfigWaitForCloudInit(vmHost, hostname),
+				Check: resource.ComposeTestCheckFunc(
+					testAccMAASInstanceCheckExists("maas_instance.test"),
+					resource.TestCheckResourceAttr("maas_instance.test", "wait_for_cloud_init.0.timeout", "15m"),
+				),
+			},
+		},
+	})
+}
+
+func testAccMAASInstanceCheckVolumeGroup(rn string, vgName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("not found: %s", rn)
+		}
+
+		conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+		volumeGroups, err := conn.VolumeGroups.Get(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, volumeGroup := range volumeGroups {
+			if volumeGroup.Name == vgName {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("volume group %s was not found on machine %s", vgName, rs.Primary.ID)
+	}
+}
+
 // Check logs for relevant events to determine if the machine was released as expected during destroy
 func testAccMAASInstanceCheckMachineLogsForDestroy(hostname string, erase bool) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
@@ -352,3 +520,121 @@ resource "maas_instance" "test" {
 }
 `, testAccMAASInstanceConfigSetup(vmHost, hostname), architecture)
 }
+
+func testAccMAASInstanceConfigStorageLayout(vmHost, hostname, layoutType, vgName string) string {
+	return fmt.Sprintf(`
+%s
+
+resource "maas_instance" "test" {
+  allocate_params {
+    hostname      = maas_vm_host_machine.test.hostname
+    min_memory    = 4000
+    min_cpu_count = 1
+  }
+
+  storage_layout {
+    type                = %q
+    vg_name             = %q
+    boot_size_gigabytes = 1
+  }
+}
+`, testAccMAASInstanceConfigSetup(vmHost, hostname), layoutType, vgName)
+}
+
+func testAccMAASInstanceConfigNetworkBridge(vmHost, hostname, mode, ipAddress string) string {
+	ipAddressConfig := ""
+	if ipAddress != "" {
+		ipAddressConfig = fmt.Sprintf("ip_address = %q", ipAddress)
+	}
+
+	return fmt.Sprintf(`
+%s
+
+resource "maas_instance" "test" {
+  allocate_params {
+    hostname      = maas_vm_host_machine.test.hostname
+    min_memory    = 4000
+    min_cpu_count = 1
+  }
+
+  network_bridges {
+    name   = "br0"
+    parent = "enp5s0"
+
+    links {
+      mode = %q
+      %s
+    }
+  }
+}
+`, testAccMAASInstanceConfigSetup(vmHost, hostname), mode, ipAddressConfig)
+}
+
+func testAccMAASInstanceConfigOwnerData(vmHost, hostname, ownerData string) string {
+	return fmt.Sprintf(`
+%s
+
+resource "maas_instance" "test" {
+  allocate_params {
+    hostname      = maas_vm_host_machine.test.hostname
+    min_memory    = 4000
+    min_cpu_count = 1
+  }
+
+  owner_data = %s
+}
+
+data "maas_machines" "test" {
+  owner_data = maas_instance.test.owner_data
+}
+
+data "maas_machine" "test" {
+  owner_data = maas_instance.test.owner_data
+}
+`, testAccMAASInstanceConfigSetup(vmHost, hostname), ownerData)
+}
+
+func testAccMAASInstanceConfigAllocationWait(vmHost, hostname string, minMemory int) string {
+	return fmt.Sprintf(`
+%s
+
+resource "maas_instance" "test" {
+  allocate_params {
+    hostname      = maas_vm_host_machine.test.hostname
+    min_memory    = %d
+    min_cpu_count = 1
+  }
+
+  allocation_wait {
+    timeout       = "30s"
+    poll_interval = "10s"
+  }
+}
+`, testAccMAASInstanceConfigSetup(vmHost, hostname), minMemory)
+}
+
+func testAccMAASInstanceConfigWaitForCloudInit(vmHost, hostname string) string {
+	return fmt.Sprintf(`
+%s
+
+resource "maas_instance" "test" {
+  allocate_params {
+    hostname      = maas_vm_host_machine.test.hostname
+    min_memory    = 4000
+    min_cpu_count = 1
+  }
+
+  deploy_params {
+    user_data = <<-EOF
+      #cloud-config
+      runcmd:
+        - sleep 30
+    EOF
+  }
+
+  wait_for_cloud_init {
+    timeout = "15m"
+  }
+}
+`, testAccMAASInstanceConfigSetup(vmHost, hostname))
+}
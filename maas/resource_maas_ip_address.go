@@ -0,0 +1,158 @@
+package maas
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASIPAddress() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to reserve an IP address in the MAAS IPAM, so that MAAS never assigns it to a node. It is meant for the addresses of virtual IPs, load balancers or appliances that are not MAAS devices. The address is released when the resource is destroyed.",
+		CreateContext: resourceIPAddressCreate,
+		ReadContext:   resourceIPAddressRead,
+		DeleteContext: resourceIPAddressDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"hostname": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The hostname registered in the DNS for the IP address. The default domain is used if the hostname has no domain component.",
+			},
+			"ip_address": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				AtLeastOneOf:     []string{"ip_address", "subnet"},
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IsIPAddress),
+				Description:      "The IP address to reserve. The next free IP address of the subnet is reserved if it's not set.",
+			},
+			"mac_address": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IsMACAddress),
+				Description:      "The MAC address the IP address is bound to.",
+			},
+			"owner": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The username of the owner of the IP address.",
+			},
+			"subnet": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				AtLeastOneOf: []string{"ip_address", "subnet"},
+				Description:  "The identifier (CIDR or ID) of the subnet of the IP address. It must be set if `ip_address` is not set.",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The allocation type of the IP address, e.g. `User reserved`.",
+			},
+		},
+	}
+}
+
+func resourceIPAddressCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	params := url.Values{}
+
+	if p, ok := d.GetOk("subnet"); ok {
+		subnet, err := getSubnet(client, p.(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		params.Set("subnet", strconv.Itoa(subnet.ID))
+	}
+
+	for k, v := range map[string]string{
+		"hostname": d.Get("hostname").(string),
+		"ip":       d.Get("ip_address").(string),
+		"mac":      d.Get("mac_address").(string),
+	} {
+		if v != "" {
+			params.Set(k, v)
+		}
+	}
+
+	// IPAddresses.Reserve cannot be used since entity.IPAddressesParams has no
+	// hostname and mac parameters
+	ipAddress := &entity.IPAddress{}
+	if err := ipAddressesOperation(client, "reserve", params, ipAddress); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(ipAddress.IP.String())
+
+	return resourceIPAddressRead(ctx, d, meta)
+}
+
+func resourceIPAddressRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	ipAddresses, err := client.IPAddresses.Get(&entity.IPAddressesParams{IP: d.Id()})
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	// The IP address was released outside of Terraform
+	if len(ipAddresses) == 0 {
+		d.SetId("")
+		return nil
+	}
+
+	ipAddress := ipAddresses[0]
+
+	tfState := map[string]any{
+		"ip_address": ipAddress.IP.String(),
+		"owner":      ipAddress.Owner.UserName,
+		"subnet":     ipAddress.Subnet.CIDR,
+		"type":       ipAddress.AllocTypeName,
+	}
+
+	// Keep the subnet as configured, by CIDR or ID, when it did not change
+	if d.Get("subnet").(string) == strconv.Itoa(ipAddress.Subnet.ID) {
+		tfState["subnet"] = d.Get("subnet")
+	}
+
+	macAddress := ""
+	if len(ipAddress.InterfaceSet) > 0 {
+		macAddress = ipAddress.InterfaceSet[0].MACAddress
+	}
+
+	if !strings.EqualFold(d.Get("mac_address").(string), macAddress) {
+		tfState["mac_address"] = macAddress
+	}
+
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceIPAddressDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if err := client.IPAddresses.Release(&entity.IPAddressesParams{IP: d.Id()}); err != nil && !strings.Contains(err.Error(), "does not exist") {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package maas_test
+
+import (
+	"fmt"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASIPAddress_basic(t *testing.T) {
+	cidr := testutils.GenerateRandomCIDR()
+	ipAddress := testutils.GetNetworkPrefixFromCIDR(cidr) + ".10"
+	macAddress := testutils.RandomMAC()
+	hostname := acctest.RandomWithPrefix("tf-vip")
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASIPAddressDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASIPAddress(cidr, ipAddress, macAddress, hostname),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_ip_address.static", "id", ipAddress),
+					resource.TestCheckResourceAttr("maas_ip_address.static", "ip_address", ipAddress),
+					resource.TestCheckResourceAttr("maas_ip_address.static", "mac_address", macAddress),
+					resource.TestCheckResourceAttr("maas_ip_address.static", "subnet", cidr),
+					resource.TestCheckResourceAttr("maas_ip_address.static", "type", "User reserved"),
+					resource.TestCheckResourceAttrSet("maas_ip_address.static", "owner"),
+					resource.TestCheckResourceAttrSet("maas_ip_address.next", "ip_address"),
+					resource.TestCheckResourceAttrPair("maas_ip_address.next", "subnet", "maas_subnet.test", "id"),
+				),
+			},
+			// Test import
+			{
+				ResourceName:            "maas_ip_address.static",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"hostname"},
+			},
+		},
+	})
+}
+
+func testAccMAASIPAddress(cidr string, ipAddress string, macAddress string, hostname string) string {
+	return fmt.Sprintf(`
+resource "maas_fabric" "test" {
+  name = "tf-fabric-ip-address"
+}
+
+resource "maas_subnet" "test" {
+  cidr   = %q
+  fabric = maas_fabric.test.id
+}
+
+resource "maas_ip_address" "static" {
+  subnet      = maas_subnet.test.cidr
+  ip_address  = %q
+  mac_address = %q
+  hostname    = %q
+}
+
+resource "maas_ip_address" "next" {
+  subnet = maas_subnet.test.id
+}
+`, cidr, ipAddress, macAddress, hostname)
+}
+
+func testAccCheckMAASIPAddressDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_ip_address" {
+			continue
+		}
+
+		ipAddresses, err := conn.IPAddresses.Get(&entity.IPAddressesParams{IP: rs.Primary.ID})
+		if err != nil {
+			return err
+		}
+
+		if len(ipAddresses) > 0 {
+			return fmt.Errorf("MAAS IP address (%s) still exists.", rs.Primary.ID)
+		}
+	}
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/canonical/gomaasclient/client"
 	"github.com/canonical/gomaasclient/entity"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -19,6 +20,13 @@ func resourceMAASLogicalVolume() *schema.Resource {
 		ReadContext:   resourceLogicalVolumeRead,
 		UpdateContext: resourceLogicalVolumeUpdate,
 		DeleteContext: resourceLogicalVolumeDelete,
+		CustomizeDiff: customdiff.All(
+			validateLogicalVolumeCapacity,
+			// MAAS grows logical volumes in place, but shrinking one would corrupt its filesystem
+			customdiff.ForceNewIfChange("size_gigabytes", func(ctx context.Context, oldValue, newValue, meta any) bool {
+				return newValue.(int) < oldValue.(int)
+			}),
+		),
 
 		Schema: map[string]*schema.Schema{
 			"fs_type": {
@@ -50,7 +58,7 @@ func resourceMAASLogicalVolume() *schema.Resource {
 			"size_gigabytes": {
 				Type:        schema.TypeInt,
 				Required:    true,
-				Description: "The volume size (given in GB).",
+				Description: "The volume size (given in GB). The volume is grown in place, shrinking it recreates the volume.",
 			},
 			"volume_group": {
 				Type:        schema.TypeString,
@@ -151,12 +159,21 @@ func resourceLogicalVolumeUpdate(ctx context.Context, d *schema.ResourceData, me
 		return diag.FromErr(err)
 	}
 
-	formattedDevice, err := formatAndMountVirtualBlockDevice(client, updatedLVM, d)
-	if err != nil {
-		return diag.FromErr(err)
+	// Unmount, unformat, format and mount again only what changed
+	current := storageFilesystem{
+		FSType:       updatedLVM.Filesystem.FSType,
+		MountPoint:   updatedLVM.Filesystem.MountPoint,
+		MountOptions: updatedLVM.Filesystem.MountOptions,
+	}
+	target := storageFilesystem{
+		FSType:       d.Get("fs_type").(string),
+		MountPoint:   d.Get("mount_point").(string),
+		MountOptions: d.Get("mount_options").(string),
 	}
 
-	d.SetId(fmt.Sprintf("%v", formattedDevice.ID))
+	if err := transitionStorageFilesystem(blockDeviceFilesystemOps(client, machine.SystemID, updatedLVM.ID), &current, target); err != nil {
+		return diag.FromErr(fmt.Errorf("logical volume (%s): %w", updatedLVM.Name, err))
+	}
 
 	return resourceLogicalVolumeRead(ctx, d, meta)
 }
@@ -101,6 +101,62 @@ func TestAccResourceMAASLogicalVolume_formatAndMount(t *testing.T) {
 	})
 }
 
+func TestAccResourceMAASLogicalVolume_resize(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+	blockDevice1Name := acctest.RandomWithPrefix("tf-lv-bd")
+	blockDevice2Name := acctest.RandomWithPrefix("tf-lv-bd")
+	volumeGroupName := acctest.RandomWithPrefix("tf-lv-vg")
+	logicalVolumeName := acctest.RandomWithPrefix("tf-lv")
+
+	var createdID, grownID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		ErrorCheck:   func(err error) error { return err },
+		CheckDestroy: testAccCheckLogicalVolumeDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccLogicalVolume(blockDevice1Name, blockDevice2Name, volumeGroupName, machine, "ext4", logicalVolumeName, 2, "/var/test"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckLogicalVolumeExists("maas_logical_volume.test"),
+					testAccLogicalVolumeID("maas_logical_volume.test", &createdID),
+				),
+			},
+			// Growing the logical volume keeps it
+			{
+				Config: testAccLogicalVolume(blockDevice1Name, blockDevice2Name, volumeGroupName, machine, "ext4", logicalVolumeName, 4, "/var/test"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_logical_volume.test", "size_gigabytes", "4"),
+					resource.TestCheckResourceAttr("maas_logical_volume.test", "fs_type", "ext4"),
+					testAccLogicalVolumeID("maas_logical_volume.test", &grownID),
+					func(s *terraform.State) error {
+						if grownID != createdID {
+							return fmt.Errorf("logical volume was recreated when growing: %s != %s", grownID, createdID)
+						}
+
+						return nil
+					},
+				),
+			},
+			// Shrinking the logical volume recreates it
+			{
+				Config: testAccLogicalVolume(blockDevice1Name, blockDevice2Name, volumeGroupName, machine, "ext4", logicalVolumeName, 3, "/var/test"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_logical_volume.test", "size_gigabytes", "3"),
+					testAccCheckLogicalVolumeExists("maas_logical_volume.test"),
+				),
+			},
+			// The volume group cannot hold a logical volume larger than itself
+			{
+				Config:      testAccLogicalVolume(blockDevice1Name, blockDevice2Name, volumeGroupName, machine, "ext4", logicalVolumeName, 50, "/var/test"),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`size_gigabytes: the logical volume requires 50 GB but volume group .* is missing`),
+			},
+		},
+	})
+}
+
 func testAccLogicalVolume(bd1Name string, bd2Name string, vgName string, machine string, fsType string, name string, size int, mountPoint string) string {
 	return fmt.Sprintf(`
 data "maas_machine" "machine" {
@@ -178,6 +234,19 @@ func testAccCheckLogicalVolumeExists(rn string) resource.TestCheckFunc {
 	}
 }
 
+func testAccLogicalVolumeID(rn string, id *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		*id = rs.Primary.ID
+
+		return nil
+	}
+}
+
 func testAccCheckLogicalVolumeDestroy(s *terraform.State) error {
 	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
 
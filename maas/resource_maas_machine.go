@@ -2,15 +2,18 @@ package maas
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"reflect"
+	"slices"
 	"sort"
 	"time"
 
 	"github.com/canonical/gomaasclient/client"
 	"github.com/canonical/gomaasclient/entity"
+	"github.com/canonical/gomaasclient/entity/node"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -18,6 +21,13 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// machinePowerTypes are the power management types supported by MAAS machines.
+var machinePowerTypes = []string{
+	"amt", "apc", "dli", "eaton", "hmc", "ipmi", "manual", "moonshot",
+	"mscm", "msftocs", "nova", "openbmc", "proxmox", "recs_box", "redfish",
+	"sm15k", "ucsm", "vmware", "webhook", "wedge", "lxd", "virsh",
+}
+
 func resourceMAASMachine() *schema.Resource {
 	return &schema.Resource{
 		Description:   "Provides a resource to manage MAAS machines.",
@@ -149,6 +159,13 @@ func resourceMAASMachine() *schema.Resource {
 					Type: schema.TypeString,
 				},
 			},
+			"on_destroy": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "delete",
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"delete", "release_then_delete", "fail_if_deployed"}, false)),
+				Description:      "The action taken when the resource is destroyed. Valid options are: `delete` (delete the machine whatever its status), `release_then_delete` (release the machine first if it's allocated, being deployed, deployed or failed to deploy, using `release_params`) and `fail_if_deployed` (refuse to delete the machine if it's `Deployed` or `Deploying`, delete it in any other status). Defaults to `delete`.",
+			},
 			"pool": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -156,46 +173,26 @@ func resourceMAASMachine() *schema.Resource {
 				Description: "The resource pool of the machine. This is computed if it's not set.",
 			},
 			"power_parameters": {
-				Type:         schema.TypeString,
-				Required:     true,
-				Sensitive:    true,
-				ValidateFunc: validation.StringIsJSON,
-				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
-					oldMap, err := structure.ExpandJsonFromString(oldValue)
-					if err != nil {
-						return false
-					}
-
-					newMap, err := structure.ExpandJsonFromString(newValue)
-					if err != nil {
-						return false
-					}
-
-					return reflect.DeepEqual(oldMap, newMap)
-				},
-				StateFunc: func(v any) string {
-					json, _ := structure.NormalizeJsonString(v)
-					return json
-				},
-				Description: "Serialized JSON string containing the parameters specific to the `power_type`. See [Power types](https://maas.io/docs/api#power-types) section for a list of the available power parameters for each power type.",
+				Type:             schema.TypeString,
+				Required:         true,
+				Sensitive:        true,
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: suppressEquivalentJSONDiffs,
+				StateFunc:        normalizeJSONState,
+				Description:      "Serialized JSON string containing the parameters specific to the `power_type`. See [Power types](https://maas.io/docs/api#power-types) section for a list of the available power parameters for each power type.",
 			},
 			"power_type": {
-				Type:        schema.TypeString,
-				Required:    true,
-				Description: "A power management type (e.g. `ipmi`).",
-				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice(
-					[]string{
-						"amt", "apc", "dli", "eaton", "hmc", "ipmi", "manual", "moonshot",
-						"mscm", "msftocs", "nova", "openbmc", "proxmox", "recs_box", "redfish",
-						"sm15k", "ucsm", "vmware", "webhook", "wedge", "lxd", "virsh",
-					},
-					false)),
+				Type:             schema.TypeString,
+				Required:         true,
+				Description:      "A power management type (e.g. `ipmi`).",
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice(machinePowerTypes, false)),
 			},
 			"pxe_mac_address": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Description: "The MAC address of the machine's PXE boot NIC, optional for IPMI machines but required for all other power types.",
 			},
+			"release_params": machineReleaseParamsSchema(),
 			"script_parameters": {
 				Type:        schema.TypeMap,
 				Optional:    true,
@@ -227,8 +224,13 @@ func resourceMAASMachine() *schema.Resource {
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(30 * time.Minute),
 			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
 		},
 		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+			if err := validateReleaseParams(d, meta); err != nil {
+				return err
+			}
+
 			isDPU, ok := d.GetOk("is_dpu")
 			if !ok {
 				return nil
@@ -349,14 +351,16 @@ func resourceMachineUpdate(ctx context.Context, d *schema.ResourceData, meta any
 		return diag.FromErr(err)
 	}
 
-	powerParams, err := getMachinePowerParams(d)
-	if err != nil {
-		return diag.FromErr(err)
-	}
+	// on_destroy and release_params are only used by Terraform
+	if d.HasChangesExcept("on_destroy", "release_params") {
+		powerParams, err := getMachinePowerParams(d)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 
-	_, err = client.Machine.Update(machine.SystemID, getMachineUpdateParams(d), powerParams)
-	if err != nil {
-		return diag.FromErr(err)
+		if _, err := client.Machine.Update(machine.SystemID, getMachineUpdateParams(d), powerParams); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	// One of the below cases is a special case for when machine is in "New" state. A user has imported this machine into Terraform and it needs to be commissioned to get to "Ready" state. Power parameters are assuming to be empty in the state at this point.
@@ -376,9 +380,35 @@ func resourceMachineUpdate(ctx context.Context, d *schema.ResourceData, meta any
 	return resourceMachineRead(ctx, d, meta)
 }
 
+// releasableMachineStatuses are the statuses of the machines released before
+// being deleted when on_destroy is 'release_then_delete'.
+var releasableMachineStatuses = []node.Status{node.StatusAllocated, node.StatusDeploying, node.StatusDeployed, node.StatusFailedDeployment}
+
 func resourceMachineDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
 	client := meta.(*ClientConfig).Client
 
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	release, err := isMachineReleasedOnDestroy(d.Get("on_destroy").(string), machine)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if release {
+		if _, err := client.Machine.Release(machine.SystemID, getReleaseParams(d)); err != nil {
+			return diag.FromErr(err)
+		}
+
+		// Wait for the machine to be released
+		_, err = waitForMachineStatus(ctx, client, machine.SystemID, []string{"Allocated", "Deployed", "Deploying", "Failed deployment", "Releasing", "Disk erasing"}, []string{"Ready"}, d.Timeout(schema.TimeoutDelete))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	// Delete machine
 	if err := client.Machine.Delete(d.Id()); err != nil {
 		return diag.FromErr(err)
@@ -387,6 +417,22 @@ func resourceMachineDelete(ctx context.Context, d *schema.ResourceData, meta any
 	return nil
 }
 
+// isMachineReleasedOnDestroy returns whether the machine must be released before
+// being deleted, or an error if it must not be deleted, according to on_destroy.
+func isMachineReleasedOnDestroy(onDestroy string, machine *entity.Machine) (bool, error) {
+	switch onDestroy {
+	case "fail_if_deployed":
+		if machine.Status == node.StatusDeployed || machine.Status == node.StatusDeploying {
+			return false, fmt.Errorf("machine (%s) is %s, refusing to delete it since on_destroy is 'fail_if_deployed'", machine.SystemID, machine.StatusName)
+		}
+	case "release_then_delete":
+		// Only the machines being used can be released, e.g. not the commissioning ones
+		return slices.Contains(releasableMachineStatuses, machine.Status), nil
+	}
+
+	return false, nil
+}
+
 func getMachinePowerParams(d *schema.ResourceData) (map[string]any, error) {
 	powerParams := make(map[string]any)
 	powerParamsString := d.Get("power_parameters").(string)
@@ -470,10 +516,16 @@ func getMachineStatusFunc(client *client.Client, systemID string) retry.StateRef
 
 func waitForMachineStatus(ctx context.Context, client *client.Client, systemID string, pendingStates []string, targetStates []string, maxTimeout time.Duration) (*entity.Machine, error) {
 	log.Printf("[DEBUG] Waiting for machine (%s) status to be one of %s\n", systemID, targetStates)
+
+	events := newMachineEventsWatcher(client, systemID)
+	refresh := getMachineStatusFunc(client, systemID)
 	stateConf := &retry.StateChangeConf{
-		Pending:    pendingStates,
-		Target:     targetStates,
-		Refresh:    getMachineStatusFunc(client, systemID),
+		Pending: pendingStates,
+		Target:  targetStates,
+		Refresh: func() (any, string, error) {
+			events.poll(ctx)
+			return refresh()
+		},
 		Timeout:    maxTimeout,
 		Delay:      10 * time.Second,
 		MinTimeout: 3 * time.Second,
@@ -481,12 +533,15 @@ func waitForMachineStatus(ctx context.Context, client *client.Client, systemID s
 
 	result, err := stateConf.WaitForStateContext(ctx)
 	if err != nil {
-		return nil, err
+		return nil, events.wrapError(err)
 	}
 
 	return result.(*entity.Machine), nil
 }
 
+// errMachineNotFound is returned by getMachine when no machine matches the identifier.
+var errMachineNotFound = errors.New("not found")
+
 func getMachine(client *client.Client, identifier string) (*entity.Machine, error) {
 	// Direct lookup via system_id
 	machine, err := client.Machine.Get(identifier)
@@ -505,7 +560,7 @@ func getMachine(client *client.Client, identifier string) (*entity.Machine, erro
 		}
 	}
 
-	return nil, fmt.Errorf("machine (%s) not found", identifier)
+	return nil, fmt.Errorf("machine (%s) %w", identifier, errMachineNotFound)
 }
 
 func getAllBlockDeviceMachineParameters(blockDevices []entity.BlockDevice) []map[string]any {
@@ -527,3 +582,22 @@ func getAllBlockDeviceMachineParameters(blockDevices []entity.BlockDevice) []map
 
 	return blockDeviceParams
 }
+
+func suppressEquivalentJSONDiffs(k, oldValue, newValue string, d *schema.ResourceData) bool {
+	oldMap, err := structure.ExpandJsonFromString(oldValue)
+	if err != nil {
+		return false
+	}
+
+	newMap, err := structure.ExpandJsonFromString(newValue)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(oldMap, newMap)
+}
+
+func normalizeJSONState(v any) string {
+	json, _ := structure.NormalizeJsonString(v)
+	return json
+}
@@ -0,0 +1,255 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/canonical/gomaasclient/entity/node"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASMachineAdoption() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to adopt a MAAS machine that enlisted itself and is in the `New` status. The machine is configured and commissioned until it reaches the `Ready` status.",
+		CreateContext: resourceMachineAdoptionCreate,
+		ReadContext:   resourceMachineAdoptionRead,
+		UpdateContext: resourceMachineAdoptionUpdate,
+		DeleteContext: resourceMachineAdoptionDelete,
+
+		Schema: map[string]*schema.Schema{
+			"architecture": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The architecture type of the machine. This is computed if it's not set.",
+			},
+			"commissioning_scripts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Commissioning script names and tags to be run. By default all custom commissioning scripts are run. Built-in commissioning scripts always run.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"domain": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The domain of the machine. This is computed if it's not set.",
+			},
+			"hostname": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The machine hostname. This is computed if it's not set.",
+			},
+			"keep_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Keep the machine in MAAS when the resource is destroyed. By default the machine is deleted from MAAS.",
+			},
+			"pool": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The resource pool of the machine. This is computed if it's not set.",
+			},
+			"power_parameters": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: suppressEquivalentJSONDiffs,
+				StateFunc:        normalizeJSONState,
+				Description:      "Serialized JSON string containing the parameters specific to the `power_type`. If it's not set, the power parameters discovered during the enlistment are kept.",
+			},
+			"power_type": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice(machinePowerTypes, false)),
+				Description:      "The power management type of the machine. If it's not set, the power type discovered during the enlistment is kept.",
+			},
+			"pxe_mac_address": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsMACAddress,
+				Description:  "The MAC address of the enlisted machine's PXE boot NIC, used to find the machine to adopt.",
+			},
+			"script_parameters": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Scripts specified to run may define their own parameters. These parameters may be passed as parameter name (key) value pairs as a map.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"skip_bmc_config": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Optional parameter to skip re-configuration of the BMC for IPMI based machines",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The status of the machine.",
+			},
+			"testing_scripts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Testing scripts names and tags to be run after commissioning. By default all tests tagged 'testing' will be run. Set to ['none'] to disable running tests.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The zone of the machine. This is computed if it's not set.",
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+		},
+	}
+}
+
+func resourceMachineAdoptionCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getEnlistedMachine(client, d.Get("pxe_mac_address").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := updateAdoptedMachine(client, machine.SystemID, d); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, err := client.Machine.Commission(machine.SystemID, getMachineCommissionParams(d)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(machine.SystemID)
+
+	if _, err := waitForMachineStatus(ctx, client, machine.SystemID, []string{"New", "Commissioning", "Testing"}, []string{"Ready"}, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMachineAdoptionRead(ctx, d, meta)
+}
+
+func resourceMachineAdoptionRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	tfState := map[string]any{
+		"architecture": machine.Architecture,
+		"domain":       machine.Domain.Name,
+		"hostname":     machine.Hostname,
+		"pool":         machine.Pool.Name,
+		"power_type":   machine.PowerType,
+		"status":       machine.StatusName,
+		"zone":         machine.Zone.Name,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceMachineAdoptionUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if d.HasChanges("architecture", "domain", "hostname", "pool", "power_parameters", "power_type", "zone") {
+		if err := updateAdoptedMachine(client, d.Id(), d); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if d.HasChanges("commissioning_scripts", "testing_scripts", "script_parameters") {
+		if _, err := client.Machine.Commission(d.Id(), getMachineCommissionParams(d)); err != nil {
+			return diag.FromErr(err)
+		}
+
+		if _, err := waitForMachineStatus(ctx, client, d.Id(), []string{"Commissioning", "Testing"}, []string{"Ready"}, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return resourceMachineAdoptionRead(ctx, d, meta)
+}
+
+func resourceMachineAdoptionDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if d.Get("keep_on_destroy").(bool) {
+		return nil
+	}
+
+	if err := client.Machine.Delete(d.Id()); err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	return nil
+}
+
+// getEnlistedMachine returns the machine in the `New` status that PXE boots
+// from the given MAC address.
+func getEnlistedMachine(client *client.Client, macAddress string) (*entity.Machine, error) {
+	machines, err := client.Machines.Get(&entity.MachinesParams{MACAddress: []string{macAddress}})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("no enlisted machine found with MAC address %s", macAddress)
+	}
+
+	machine := machines[0]
+	if machine.Status != node.StatusNew {
+		return nil, fmt.Errorf("machine (%s) with MAC address %s is %s, only machines in the New status can be adopted", machine.SystemID, macAddress, machine.StatusName)
+	}
+
+	return &machine, nil
+}
+
+func updateAdoptedMachine(client *client.Client, systemID string, d *schema.ResourceData) error {
+	powerParams := map[string]any{}
+
+	if _, ok := d.GetOk("power_parameters"); ok {
+		var err error
+
+		powerParams, err = getMachinePowerParams(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	params := &entity.MachineUpdateParams{
+		Architecture: d.Get("architecture").(string),
+		Domain:       d.Get("domain").(string),
+		Hostname:     d.Get("hostname").(string),
+		Pool:         d.Get("pool").(string),
+		PowerType:    d.Get("power_type").(string),
+		Zone:         d.Get("zone").(string),
+	}
+
+	_, err := client.Machine.Update(systemID, params, powerParams)
+
+	return err
+}
@@ -0,0 +1,45 @@
+package maas_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASMachineAdoption_validation(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMAASMachineAdoption("not-a-mac"),
+				ExpectError: regexp.MustCompile(`expected "pxe_mac_address" to be a valid MAC address`),
+			},
+			{
+				Config: testAccMAASMachineAdoption("52:54:00:00:00:01"),
+				// Verify the plan is valid, don't actually adopt the machine.
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccMAASMachineAdoption(macAddress string) string {
+	return fmt.Sprintf(`
+resource "maas_machine_adoption" "test" {
+  pxe_mac_address = %q
+  hostname        = "tf-adopted"
+  power_type      = "manual"
+  testing_scripts = ["none"]
+  keep_on_destroy = true
+}
+`, macAddress)
+}
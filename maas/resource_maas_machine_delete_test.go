@@ -0,0 +1,50 @@
+package maas
+
+import (
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/canonical/gomaasclient/entity/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMachineReleasedOnDestroy(t *testing.T) {
+	testCases := []struct {
+		onDestroy  string
+		status     node.Status
+		statusName string
+		release    bool
+		err        bool
+	}{
+		{onDestroy: "delete", status: node.StatusDeployed, statusName: "Deployed"},
+		{onDestroy: "fail_if_deployed", status: node.StatusReady, statusName: "Ready"},
+		{onDestroy: "fail_if_deployed", status: node.StatusCommissioning, statusName: "Commissioning"},
+		{onDestroy: "fail_if_deployed", status: node.StatusFailedCommissioning, statusName: "Failed commissioning"},
+		{onDestroy: "fail_if_deployed", status: node.StatusTesting, statusName: "Testing"},
+		{onDestroy: "fail_if_deployed", status: node.StatusAllocated, statusName: "Allocated"},
+		{onDestroy: "fail_if_deployed", status: node.StatusFailedDeployment, statusName: "Failed deployment"},
+		{onDestroy: "fail_if_deployed", status: node.StatusDeploying, statusName: "Deploying", err: true},
+		{onDestroy: "fail_if_deployed", status: node.StatusDeployed, statusName: "Deployed", err: true},
+		{onDestroy: "release_then_delete", status: node.StatusReady, statusName: "Ready"},
+		{onDestroy: "release_then_delete", status: node.StatusCommissioning, statusName: "Commissioning"},
+		{onDestroy: "release_then_delete", status: node.StatusAllocated, statusName: "Allocated", release: true},
+		{onDestroy: "release_then_delete", status: node.StatusDeploying, statusName: "Deploying", release: true},
+		{onDestroy: "release_then_delete", status: node.StatusDeployed, statusName: "Deployed", release: true},
+		{onDestroy: "release_then_delete", status: node.StatusFailedDeployment, statusName: "Failed deployment", release: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.onDestroy+" "+testCase.statusName, func(t *testing.T) {
+			machine := &entity.Machine{SystemID: "abc123", Status: testCase.status, StatusName: testCase.statusName}
+
+			release, err := isMachineReleasedOnDestroy(testCase.onDestroy, machine)
+			if testCase.err {
+				assert.EqualError(t, err, "machine (abc123) is "+testCase.statusName+", refusing to delete it since on_destroy is 'fail_if_deployed'")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.release, release)
+		})
+	}
+}
@@ -0,0 +1,644 @@
+package maas
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"gopkg.in/yaml.v3"
+)
+
+// machineInventoryEntry is a machine described in a maas_machine_inventory inventory.
+type machineInventoryEntry struct {
+	Architecture  string   `json:"architecture,omitempty" yaml:"architecture"`
+	Domain        string   `json:"domain,omitempty" yaml:"domain"`
+	Hostname      string   `json:"hostname" yaml:"hostname"`
+	Pool          string   `json:"pool,omitempty" yaml:"pool"`
+	PowerAddress  string   `json:"power_address,omitempty" yaml:"power_address"`
+	PowerID       string   `json:"power_id,omitempty" yaml:"power_id"`
+	PowerPass     string   `json:"power_pass,omitempty" yaml:"power_pass"`
+	PowerType     string   `json:"power_type" yaml:"power_type"`
+	PowerUser     string   `json:"power_user,omitempty" yaml:"power_user"`
+	PXEMACAddress string   `json:"pxe_mac_address,omitempty" yaml:"pxe_mac_address"`
+	Tags          []string `json:"tags,omitempty" yaml:"tags"`
+	Zone          string   `json:"zone,omitempty" yaml:"zone"`
+}
+
+func resourceMAASMachineInventory() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to enlist and commission MAAS machines in bulk, from a CSV, JSON or YAML inventory.\n\nEach inventory entry describes a machine with the following fields: `hostname` (required), `power_type` (required), `pxe_mac_address` (required unless `power_type` is `ipmi`), `power_address`, `power_user`, `power_pass`, `power_id`, `architecture`, `domain`, `zone`, `pool` and `tags`. In CSV inventories, the first row holds the field names and the tags are separated by `;`.\n\nOn later applies, machines added to the inventory are created, machines removed from it are deleted, and machines whose entry changed are updated. Tags are only added to the machines, never removed.",
+		CreateContext: resourceMachineInventoryCreate,
+		ReadContext:   resourceMachineInventoryRead,
+		UpdateContext: resourceMachineInventoryUpdate,
+		DeleteContext: resourceMachineInventoryDelete,
+
+		Schema: map[string]*schema.Schema{
+			"checksums": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The checksum of each inventory entry, by hostname. Used to detect the changed entries.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"commissioning_scripts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Commissioning script names and tags to be run on the new machines. By default all custom commissioning scripts are run. Built-in commissioning scripts always run.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"content": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"content", "file"},
+				Description:  "The inline inventory content. Conflicts with `file`.",
+			},
+			"file": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"content", "file"},
+				Description:  "The path of the inventory file. Conflicts with `content`.",
+			},
+			"format": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"csv", "json", "yaml"}, false)),
+				Description:      "The inventory format. Valid options are: `csv`, `json` and `yaml`. Unknown columns or keys are rejected.",
+			},
+			"machines": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The system ID of each enlisted machine, by hostname.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"parallelism": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				Default:          4,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IntAtLeast(1)),
+				Description:      "The maximum number of machines created, updated or deleted at the same time. Defaults to `4`.",
+			},
+			"script_parameters": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Parameters of the commissioning and testing scripts run on the new machines, as parameter name (key) value pairs. Optionally a parameter may have the script name prepended to have that parameter only apply to that specific script, e.g. my-script_param=value.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"statuses": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The status of each enlisted machine, by hostname.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"testing_scripts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Testing script names and tags to be run on the new machines after commissioning. By default all tests tagged 'testing' will be run. Set to ['none'] to disable running tests.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+		},
+		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+			if !d.NewValueKnown("content") || !d.NewValueKnown("file") {
+				for _, k := range []string{"checksums", "machines", "statuses"} {
+					if err := d.SetNewComputed(k); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
+
+			entries, err := getMachineInventoryEntries(d.Get("format").(string), d.Get("content").(string), d.Get("file").(string))
+			if err != nil {
+				return err
+			}
+
+			checksums := getMachineInventoryChecksums(entries)
+			if old, ok := d.Get("checksums").(map[string]any); ok && d.Id() != "" && maps.Equal(old, checksums) {
+				return nil
+			}
+
+			if err := d.SetNew("checksums", checksums); err != nil {
+				return err
+			}
+
+			if err := d.SetNewComputed("machines"); err != nil {
+				return err
+			}
+
+			return d.SetNewComputed("statuses")
+		},
+	}
+}
+
+func resourceMachineInventoryCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	d.SetId(id.UniqueId())
+
+	return reconcileMachineInventory(ctx, d, meta, d.Timeout(schema.TimeoutCreate))
+}
+
+func resourceMachineInventoryRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machines := d.Get("machines").(map[string]any)
+	checksums := d.Get("checksums").(map[string]any)
+	statuses := map[string]any{}
+
+	for hostname, systemID := range machines {
+		machine, err := client.Machine.Get(systemID.(string))
+		if err != nil {
+			if strings.Contains(err.Error(), "404 Not Found") {
+				// The machine is created again on the next apply
+				log.Printf("[DEBUG] Machine (%s) of inventory (%s) was not found\n", systemID, d.Id())
+				delete(machines, hostname)
+				delete(checksums, hostname)
+
+				continue
+			}
+
+			return diag.FromErr(err)
+		}
+
+		statuses[hostname] = machine.StatusName
+	}
+
+	tfState := map[string]any{
+		"checksums": checksums,
+		"machines":  machines,
+		"statuses":  statuses,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceMachineInventoryUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	return reconcileMachineInventory(ctx, d, meta, d.Timeout(schema.TimeoutUpdate))
+}
+
+func resourceMachineInventoryDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machines := d.Get("machines").(map[string]any)
+
+	systemIDs := make([]string, 0, len(machines))
+	for _, systemID := range machines {
+		systemIDs = append(systemIDs, systemID.(string))
+	}
+
+	var diags diag.Diagnostics
+
+	errs := runConcurrently(d.Get("parallelism").(int), len(systemIDs), func(i int) error {
+		return client.Machine.Delete(systemIDs[i])
+	})
+	for i, err := range errs {
+		if err != nil && !strings.Contains(err.Error(), "404 Not Found") {
+			diags = append(diags, diag.Errorf("machine (%s): %s", systemIDs[i], err)...)
+		}
+	}
+
+	return diags
+}
+
+// reconcileMachineInventory creates, updates and deletes the machines to match the
+// inventory. Failures of individual machines are reported as warnings, so that the
+// machines already enlisted are kept in the state.
+func reconcileMachineInventory(ctx context.Context, d *schema.ResourceData, meta any, timeout time.Duration) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	entries, err := getMachineInventoryEntries(d.Get("format").(string), d.Get("content").(string), d.Get("file").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	oldChecksums := map[string]any{}
+	if o, _ := d.GetChange("checksums"); o != nil {
+		oldChecksums = o.(map[string]any)
+	}
+
+	// The machines are unknown in the plan when the inventory changed, so use the prior state
+	machines := map[string]any{}
+	if o, _ := d.GetChange("machines"); o != nil {
+		machines = o.(map[string]any)
+	}
+
+	checksums := map[string]any{}
+	newChecksums := getMachineInventoryChecksums(entries)
+
+	// Prepare the machines to create or update, and the machines to delete
+	type machineInventoryJob struct {
+		entry    *machineInventoryEntry
+		data     *schema.ResourceData
+		systemID string
+	}
+
+	jobs := []machineInventoryJob{}
+
+	for _, entry := range entries {
+		systemID, exists := machines[entry.Hostname]
+		if exists && oldChecksums[entry.Hostname] == newChecksums[entry.Hostname] {
+			checksums[entry.Hostname] = newChecksums[entry.Hostname]
+			continue
+		}
+
+		machineData, err := getMachineInventoryEntryData(d, entry)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		job := machineInventoryJob{entry: entry, data: machineData}
+		if exists {
+			job.systemID = systemID.(string)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	deleted := []string{}
+
+	for hostname := range machines {
+		if _, ok := newChecksums[hostname]; !ok {
+			deleted = append(deleted, hostname)
+		}
+	}
+
+	var (
+		diags diag.Diagnostics
+		mutex sync.Mutex
+	)
+
+	warn := func(hostname string, err error) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Unable to enlist machine %s from the inventory", hostname),
+			Detail:   err.Error(),
+		})
+	}
+
+	errs := runConcurrently(d.Get("parallelism").(int), len(deleted), func(i int) error {
+		err := client.Machine.Delete(machines[deleted[i]].(string))
+		if err != nil && strings.Contains(err.Error(), "404 Not Found") {
+			return nil
+		}
+
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			diags = append(diags, diag.Errorf("machine (%s): %s", deleted[i], err)...)
+			continue
+		}
+
+		delete(machines, deleted[i])
+	}
+
+	errs = runConcurrently(d.Get("parallelism").(int), len(jobs), func(i int) error {
+		job := jobs[i]
+		if job.systemID != "" {
+			return updateMachineFromInventory(client, job.systemID, job.entry, job.data)
+		}
+
+		systemID, err := createMachineFromInventory(ctx, client, job.entry, job.data, timeout)
+		if systemID != "" {
+			mutex.Lock()
+			machines[job.entry.Hostname] = systemID
+			mutex.Unlock()
+		}
+
+		return err
+	})
+	for i, err := range errs {
+		hostname := jobs[i].entry.Hostname
+		if err != nil {
+			warn(hostname, err)
+		}
+		// Machines that could not be created are retried on the next apply
+		if _, ok := machines[hostname]; ok && (err == nil || jobs[i].systemID == "") {
+			checksums[hostname] = newChecksums[hostname]
+		}
+	}
+
+	if err := d.Set("machines", machines); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("checksums", checksums); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return append(diags, resourceMachineInventoryRead(ctx, d, meta)...)
+}
+
+// getMachineInventoryEntryData returns the maas_machine resource data of an inventory
+// entry, so that the maas_machine parameters helpers can be used.
+func getMachineInventoryEntryData(d *schema.ResourceData, entry *machineInventoryEntry) (*schema.ResourceData, error) {
+	powerParams := map[string]string{}
+
+	for k, v := range map[string]string{
+		"power_address": entry.PowerAddress,
+		"power_id":      entry.PowerID,
+		"power_pass":    entry.PowerPass,
+		"power_user":    entry.PowerUser,
+	} {
+		if v != "" {
+			powerParams[k] = v
+		}
+	}
+
+	powerParamsJSON, err := json.Marshal(powerParams)
+	if err != nil {
+		return nil, err
+	}
+
+	architecture := entry.Architecture
+	if architecture == "" {
+		architecture = "amd64/generic"
+	}
+
+	machineData := resourceMAASMachine().Data(nil)
+	tfState := map[string]any{
+		"architecture":          architecture,
+		"commissioning_scripts": d.Get("commissioning_scripts"),
+		"domain":                entry.Domain,
+		"hostname":              entry.Hostname,
+		"pool":                  entry.Pool,
+		"power_parameters":      string(powerParamsJSON),
+		"power_type":            entry.PowerType,
+		"pxe_mac_address":       entry.PXEMACAddress,
+		"script_parameters":     d.Get("script_parameters"),
+		"testing_scripts":       d.Get("testing_scripts"),
+		"zone":                  entry.Zone,
+	}
+	if err := setTerraformState(machineData, tfState); err != nil {
+		return nil, err
+	}
+
+	return machineData, nil
+}
+
+// createMachineFromInventory creates and commissions an inventory machine. The system ID is
+// returned as soon as the machine exists, even if it fails to be commissioned.
+func createMachineFromInventory(ctx context.Context, client *client.Client, entry *machineInventoryEntry, machineData *schema.ResourceData, timeout time.Duration) (string, error) {
+	powerParams, err := getMachinePowerParams(machineData)
+	if err != nil {
+		return "", err
+	}
+
+	machine, err := client.Machines.Create(getMachineCreateParams(machineData), powerParams)
+	if err != nil {
+		return "", err
+	}
+
+	if err := addMachineInventoryTags(client, machine.SystemID, entry.Tags); err != nil {
+		return machine.SystemID, err
+	}
+
+	if _, err := client.Machine.Commission(machine.SystemID, getMachineCommissionParams(machineData)); err != nil {
+		log.Printf("[DEBUG] Machine (%s) cleaning up trailing resources\n", machine.SystemID)
+
+		if errDel := client.Machine.Delete(machine.SystemID); errDel != nil {
+			return machine.SystemID, fmt.Errorf("%v;\nAdditionally, error when attempting to delete the trailing machine: %v", err, errDel)
+		}
+
+		return "", err
+	}
+
+	// Wait for machine to be ready
+	_, err = waitForMachineStatus(ctx, client, machine.SystemID, []string{"Commissioning", "Testing"}, []string{"Ready"}, timeout)
+
+	return machine.SystemID, err
+}
+
+func updateMachineFromInventory(client *client.Client, systemID string, entry *machineInventoryEntry, machineData *schema.ResourceData) error {
+	powerParams, err := getMachinePowerParams(machineData)
+	if err != nil {
+		return err
+	}
+
+	// The machine is only commissioned when it is created
+	params := getMachineUpdateParams(machineData)
+	params.Commission = nil
+
+	if _, err := client.Machine.Update(systemID, params, powerParams); err != nil {
+		return err
+	}
+
+	return addMachineInventoryTags(client, systemID, entry.Tags)
+}
+
+func addMachineInventoryTags(client *client.Client, systemID string, tags []string) error {
+	for _, tag := range tags {
+		if err := client.Tag.AddMachines(tag, []string{systemID}); err != nil {
+			return fmt.Errorf("unable to add tag %q: %w", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// getMachineInventoryEntries parses and validates the inventory, from its content or file.
+func getMachineInventoryEntries(format string, content string, file string) ([]*machineInventoryEntry, error) {
+	data := []byte(content)
+
+	if file != "" {
+		var err error
+
+		data, err = os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read inventory file: %w", err)
+		}
+	}
+
+	var (
+		entries []*machineInventoryEntry
+		err     error
+	)
+
+	switch format {
+	case "csv":
+		entries, err = parseMachineInventoryCSV(data)
+	case "json":
+		entries, err = parseMachineInventoryJSON(data)
+	case "yaml":
+		entries, err = parseMachineInventoryYAML(data)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse inventory: %w", err)
+	}
+
+	hostnames := map[string]bool{}
+
+	for i, entry := range entries {
+		if entry == nil || entry.Hostname == "" {
+			return nil, fmt.Errorf("inventory entry %d: 'hostname' is required", i+1)
+		}
+
+		if hostnames[entry.Hostname] {
+			return nil, fmt.Errorf("inventory entry %d: hostname %q is duplicated", i+1, entry.Hostname)
+		}
+
+		hostnames[entry.Hostname] = true
+
+		if entry.PowerType == "" {
+			return nil, fmt.Errorf("inventory entry %d (%s): 'power_type' is required", i+1, entry.Hostname)
+		}
+
+		if entry.PowerType != "ipmi" && entry.PXEMACAddress == "" {
+			return nil, fmt.Errorf("inventory entry %d (%s): 'pxe_mac_address' is required when 'power_type' is not 'ipmi'", i+1, entry.Hostname)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseMachineInventoryJSON parses a JSON inventory, rejecting the unknown keys.
+func parseMachineInventoryJSON(data []byte) ([]*machineInventoryEntry, error) {
+	var entries []*machineInventoryEntry
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the inventory")
+	}
+
+	return entries, nil
+}
+
+// parseMachineInventoryYAML parses a YAML inventory, rejecting the unknown keys.
+func parseMachineInventoryYAML(data []byte) ([]*machineInventoryEntry, error) {
+	var entries []*machineInventoryEntry
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	// An empty document is an empty inventory
+	if err := decoder.Decode(&entries); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseMachineInventoryCSV(data []byte) ([]*machineInventoryEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*machineInventoryEntry{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &machineInventoryEntry{}
+
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+
+			switch strings.TrimSpace(column) {
+			case "architecture":
+				entry.Architecture = value
+			case "domain":
+				entry.Domain = value
+			case "hostname":
+				entry.Hostname = value
+			case "pool":
+				entry.Pool = value
+			case "power_address":
+				entry.PowerAddress = value
+			case "power_id":
+				entry.PowerID = value
+			case "power_pass":
+				entry.PowerPass = value
+			case "power_type":
+				entry.PowerType = value
+			case "power_user":
+				entry.PowerUser = value
+			case "pxe_mac_address":
+				entry.PXEMACAddress = value
+			case "tags":
+				for _, tag := range strings.Split(value, ";") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						entry.Tags = append(entry.Tags, tag)
+					}
+				}
+			case "zone":
+				entry.Zone = value
+			default:
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+func getMachineInventoryChecksums(entries []*machineInventoryEntry) map[string]any {
+	checksums := map[string]any{}
+
+	for _, entry := range entries {
+		tags := slices.Clone(entry.Tags)
+		slices.Sort(tags)
+
+		normalized := *entry
+		normalized.Tags = tags
+
+		data, _ := json.Marshal(normalized)
+		sum := sha256.Sum256(data)
+		checksums[entry.Hostname] = hex.EncodeToString(sum[:])
+	}
+
+	return checksums
+}
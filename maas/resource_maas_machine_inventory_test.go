@@ -0,0 +1,68 @@
+package maas_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASMachineInventory_validation(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASMachineInventory("csv", `hostname,power_type,power_address,power_user,power_pass
+ipmi-1,ipmi,10.0.0.10,admin,password
+ipmi-1,ipmi,10.0.0.11,admin,password`),
+				ExpectError: regexp.MustCompile(`hostname "ipmi-1" is duplicated`),
+			},
+			{
+				Config: testAccMAASMachineInventory("yaml", `- hostname: lxd-1
+  power_type: lxd
+  power_address: 10.0.0.10`),
+				ExpectError: regexp.MustCompile(`'pxe_mac_address' is required when 'power_type' is not 'ipmi'`),
+			},
+			{
+				Config: testAccMAASMachineInventory("yaml", `- hostname: ipmi-1
+  power_type: ipmi
+  power_adress: 10.0.0.10`),
+				ExpectError: regexp.MustCompile(`field power_adress not found`),
+			},
+			{
+				Config:      testAccMAASMachineInventory("json", `[{"hostname": "ipmi-1", "power_type": "ipmi", "power_adress": "10.0.0.10"}]`),
+				ExpectError: regexp.MustCompile(`unknown field "power_adress"`),
+			},
+			{
+				Config: testAccMAASMachineInventory("json", `[
+  {"hostname": "ipmi-1", "power_type": "ipmi", "power_address": "10.0.0.10", "power_user": "admin", "power_pass": "password", "tags": ["rack-1"]},
+  {"hostname": "ipmi-2", "power_type": "ipmi", "power_address": "10.0.0.11", "power_user": "admin", "power_pass": "password", "tags": ["rack-1"]}
+]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine_inventory.test", "checksums.%", "2"),
+				),
+				// Verify the plan is valid, don't actually create the machines.
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccMAASMachineInventory(format string, content string) string {
+	return fmt.Sprintf(`
+resource "maas_machine_inventory" "test" {
+  format  = %q
+  content = <<-EOT
+%s
+EOT
+}
+`, format, content)
+}
@@ -0,0 +1,615 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASMachineNetwork() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage the whole network configuration of a MAAS machine: physical interfaces, bonds, VLANs, bridges, subnet links and default gateways. The existing interfaces with the same names, or the same MAC addresses for physical interfaces, are reconfigured. The changes are applied in dependency order, and rolled back if one of them fails. The machine must be in the `New`, `Ready`, `Allocated`, `Broken` or `Failed testing` status.",
+		CreateContext: resourceMachineNetworkCreate,
+		ReadContext:   resourceMachineNetworkRead,
+		UpdateContext: resourceMachineNetworkUpdate,
+		DeleteContext: resourceMachineNetworkDelete,
+		CustomizeDiff: validateMachineNetwork,
+
+		Schema: map[string]*schema.Schema{
+			"bond": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bonds of the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineNetworkInterfaceSchema(withNetworkBondSchema(map[string]*schema.Schema{
+						"mac_address": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Computed:    true,
+							Description: "The MAC address of the bond. This argument is computed if it's not set.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the bond.",
+						},
+						"parents": {
+							Type:        schema.TypeList,
+							Required:    true,
+							MinItems:    1,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The names of the physical interfaces of the layout bonded together. Changing them recreates the bond.",
+						},
+						"vlan": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Computed:    true,
+							Description: "Database ID of the VLAN the bond is connected to. This argument is computed if it's not set.",
+						},
+					})),
+				},
+			},
+			"bridge": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bridges of the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineNetworkInterfaceSchema(withNetworkBridgeSchema(map[string]*schema.Schema{
+						"mac_address": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Computed:    true,
+							Description: "The MAC address of the bridge. This argument is computed if it's not set.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the bridge.",
+						},
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the physical, bond or VLAN interface of the layout bridged. Changing it recreates the bridge.",
+						},
+						"vlan": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Computed:    true,
+							Description: "Database ID of the VLAN the bridge is connected to. This argument is computed if it's not set.",
+						},
+					})),
+				},
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The identifier (system ID, hostname, or FQDN) of the machine.",
+			},
+			"physical": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The physical interfaces of the layout. The existing links of an interface are removed when it is added to the layout, and it is disconnected when it is removed from the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineNetworkInterfaceSchema(map[string]*schema.Schema{
+						"mac_address": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The MAC address identifying the physical interface. The interface is created if the machine does not have it.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the physical interface, used to reference it in the layout.",
+						},
+						"vlan": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Computed:    true,
+							Description: "Database ID of the VLAN the physical interface is connected to. This argument is computed if it's not set.",
+						},
+					}),
+				},
+			},
+			"vlan": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The VLAN interfaces of the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineNetworkInterfaceSchema(map[string]*schema.Schema{
+						"mac_address": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The MAC address of the VLAN interface, inherited from its parent.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the VLAN interface, `<parent>.<vid>`. It is used to reference the interface in the layout.",
+						},
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the physical or bond interface of the layout tagged. Changing it recreates the VLAN interface.",
+						},
+						"vid": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(1, 4094),
+							Description:  "The VID of the VLAN, on the fabric of the parent. Changing it recreates the VLAN interface.",
+						},
+					}),
+				},
+			},
+		},
+	}
+}
+
+// withMachineNetworkInterfaceSchema adds the attributes shared by all the kinds of
+// interfaces to the schema s.
+func withMachineNetworkInterfaceSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	maps.Copy(s, map[string]*schema.Schema{
+		"id": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The network interface ID.",
+		},
+		"link": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "The subnet links of the interface, in order. Changing them recreates the links of the interface.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"default_gateway": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     false,
+						Description: "Whether the gateway of the subnet is a default gateway of the machine. This option can only be used with the `AUTO` and `STATIC` modes. Defaults to `false`.",
+					},
+					"id": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "The link ID.",
+					},
+					"ip_address": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						Computed:         true,
+						ValidateDiagFunc: validation.ToDiagFunc(validation.IsIPAddress),
+						Description:      "The IP address of the link. It can only be set with the `STATIC` mode, a random IP address of the subnet is used if it's not set.",
+					},
+					"mode": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						Default:          "AUTO",
+						ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"AUTO", "DHCP", "STATIC", "LINK_UP"}, false)),
+						Description:      "Connection mode to subnet. Valid options are: `AUTO`, `DHCP`, `STATIC` and `LINK_UP`. Defaults to `AUTO`.",
+					},
+					"subnet": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "The identifier (CIDR or ID) of the subnet.",
+					},
+				},
+			},
+		},
+		"mtu": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			Description: "The MTU of the interface. This argument is computed if it's not set.",
+		},
+		"tags": {
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "A set of tag names assigned to the interface. This argument is computed if it's not set.",
+		},
+	})
+
+	return s
+}
+
+// withNetworkBondSchema adds the bond settings to the schema s.
+func withNetworkBondSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	maps.Copy(s, map[string]*schema.Schema{
+		"bond_downdelay": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     0,
+			Description: "The time, in milliseconds, to wait before disabling a parent after a link failure has been detected. Defaults to `0`.",
+		},
+		"bond_lacp_rate": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Default:          "slow",
+			ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"fast", "slow"}, false)),
+			Description:      "The rate at which to ask the link partner to transmit LACPDU packets in `802.3ad` mode. Valid options are: `fast` and `slow`. Defaults to `slow`.",
+		},
+		"bond_miimon": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     100,
+			Description: "The link monitoring frequency in milliseconds. Defaults to `100`.",
+		},
+		"bond_mode": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Default:          "active-backup",
+			ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}, false)),
+			Description:      "The operating mode of the bond. Valid options are: `balance-rr`, `active-backup`, `balance-xor`, `broadcast`, `802.3ad`, `balance-tlb` and `balance-alb`. Defaults to `active-backup`.",
+		},
+		"bond_num_grat_arp": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     1,
+			Description: "The number of peer notifications to be issued after a failover. Defaults to `1`.",
+		},
+		"bond_updelay": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     0,
+			Description: "The time, in milliseconds, to wait before enabling a parent after a link recovery has been detected. Defaults to `0`.",
+		},
+		"bond_xmit_hash_policy": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Default:          "layer2",
+			ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"layer2", "layer2+3", "layer3+4", "encap2+3", "encap3+4"}, false)),
+			Description:      "The transmit hash policy used to select the parent in the `balance-xor`, `802.3ad` and `balance-tlb` modes. Valid options are: `layer2`, `layer2+3`, `layer3+4`, `encap2+3` and `encap3+4`. Defaults to `layer2`.",
+		},
+	})
+
+	return s
+}
+
+// withNetworkBridgeSchema adds the bridge settings to the schema s.
+func withNetworkBridgeSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	maps.Copy(s, map[string]*schema.Schema{
+		"bridge_fd": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     15,
+			Description: "The forward delay of the bridge, in seconds. Defaults to `15`.",
+		},
+		"bridge_stp": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether the spanning tree protocol is enabled on the bridge. Defaults to `false`.",
+		},
+		"bridge_type": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Default:          "standard",
+			ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"standard", "ovs"}, false)),
+			Description:      "The type of the bridge. Valid options are: `standard` and `ovs`. Defaults to `standard`.",
+		},
+	})
+
+	return s
+}
+
+// validateMachineNetwork checks the references of the layout at plan time, when
+// they are known.
+func validateMachineNetwork(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	for _, kind := range networkKinds {
+		if !d.NewValueKnown(kind) {
+			return nil
+		}
+
+		for i := range d.Get(kind).([]any) {
+			for _, key := range []string{"name", "parent", "parents"} {
+				// The name of a VLAN interface is computed from its parent
+				if kind == networkKindVLAN && key == "name" {
+					continue
+				}
+
+				if !d.NewValueKnown(fmt.Sprintf("%s.%d.%s", kind, i, key)) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return expandMachineNetworkLayout(func(key string) []any { return d.Get(key).([]any) }).validate()
+}
+
+func resourceMachineNetworkCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its network cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	networkInterfaces, err := client.NetworkInterfaces.Get(machine.SystemID)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Start from the existing interfaces of the layout, e.g. the physical interface
+	// with the PXE link, so that they are restored if the layout cannot be applied
+	desired := getMachineNetworkLayout(d, false)
+	current := observeMachineNetworkLayout(machine, networkInterfaces, getMachineNetworkNames(desired, networkInterfaces))
+	initial := current.clone()
+
+	applier := newMachineNetworkApplier(client, machine, current)
+
+	err = applier.apply(desired)
+
+	// Keep the interfaces configured before a failure that could not be rolled back in the state
+	if err == nil || !applier.current.inSync(initial) {
+		d.SetId(machine.SystemID)
+
+		if err := setTerraformState(d, flattenMachineNetworkLayout(applier.current)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMachineNetworkRead(ctx, d, meta)
+}
+
+func resourceMachineNetworkRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	layout := getMachineNetworkLayout(d, true)
+	if err := layout.refresh(client, machine); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := setTerraformState(d, flattenMachineNetworkLayout(layout)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceMachineNetworkUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its network cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	current := getMachineNetworkLayout(d, true)
+	if err := current.refresh(client, machine); err != nil {
+		return diag.FromErr(err)
+	}
+
+	applier := newMachineNetworkApplier(client, machine, current)
+
+	err = applier.apply(getMachineNetworkLayout(d, false))
+
+	if err := setTerraformState(d, flattenMachineNetworkLayout(applier.current)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMachineNetworkRead(ctx, d, meta)
+}
+
+func resourceMachineNetworkDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its network cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	log.Printf("[DEBUG] Machine (%s) deleting the network layout\n", machine.SystemID)
+
+	current := getMachineNetworkLayout(d, true)
+	if err := current.refresh(client, machine); err != nil {
+		return diag.FromErr(err)
+	}
+
+	applier := newMachineNetworkApplier(client, machine, current)
+	if err := applier.destroy(); err != nil {
+		if err := setTerraformState(d, flattenMachineNetworkLayout(applier.current)); err != nil {
+			return diag.FromErr(err)
+		}
+
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// getMachineNetworkLayout returns the layout of the state when old is set, or
+// the one of the configuration otherwise.
+// getMachineNetworkNames returns the names of the existing interfaces of the
+// layout: the ones with the same name, and the physical interfaces with the same
+// MAC address.
+func getMachineNetworkNames(layout *machineNetworkLayout, networkInterfaces []entity.NetworkInterface) []string {
+	names := layout.keys()
+
+	for _, o := range layout.Interfaces {
+		if o.Kind != networkKindPhysical {
+			continue
+		}
+
+		for _, n := range networkInterfaces {
+			if n.Type == networkKindPhysical && strings.EqualFold(n.MACAddress, o.MACAddress) && !slices.Contains(names, n.Name) {
+				names = append(names, n.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+func getMachineNetworkLayout(d *schema.ResourceData, old bool) *machineNetworkLayout {
+	return expandMachineNetworkLayout(func(key string) []any {
+		o, n := d.GetChange(key)
+		if old {
+			return o.([]any)
+		}
+
+		return n.([]any)
+	})
+}
+
+func expandMachineNetworkLayout(get func(key string) []any) *machineNetworkLayout {
+	layout := &machineNetworkLayout{}
+
+	for _, kind := range networkKinds {
+		for _, v := range get(kind) {
+			m := v.(map[string]any)
+			o := &networkInterface{
+				ID:     m["id"].(int),
+				Kind:   kind,
+				MTU:    m["mtu"].(int),
+				Tags:   convertToStringSlice(m["tags"].(*schema.Set).List()),
+				Params: map[string]string{},
+			}
+
+			switch kind {
+			case networkKindPhysical:
+				o.Name = m["name"].(string)
+				o.MACAddress = m["mac_address"].(string)
+				o.VLAN = m["vlan"].(int)
+			case networkKindBond:
+				o.Name = m["name"].(string)
+				o.MACAddress = m["mac_address"].(string)
+				o.Parents = convertToStringSlice(m["parents"])
+				o.VLAN = m["vlan"].(int)
+			case networkKindVLAN:
+				o.Parents = []string{m["parent"].(string)}
+				o.VID = m["vid"].(int)
+				o.Name = fmt.Sprintf("%s.%d", o.Parents[0], o.VID)
+			case networkKindBridge:
+				o.Name = m["name"].(string)
+				o.MACAddress = m["mac_address"].(string)
+				o.Parents = []string{m["parent"].(string)}
+				o.VLAN = m["vlan"].(int)
+			}
+
+			for _, k := range networkInterfaceParams[kind] {
+				o.Params[k] = fmt.Sprint(m[k])
+			}
+
+			for _, l := range m["link"].([]any) {
+				lm := l.(map[string]any)
+				o.Links = append(o.Links, &networkLink{
+					ID:             lm["id"].(int),
+					Subnet:         lm["subnet"].(string),
+					Mode:           lm["mode"].(string),
+					IPAddress:      lm["ip_address"].(string),
+					DefaultGateway: lm["default_gateway"].(bool),
+				})
+			}
+
+			layout.Interfaces = append(layout.Interfaces, o)
+		}
+	}
+
+	return layout
+}
+
+func flattenMachineNetworkLayout(layout *machineNetworkLayout) map[string]any {
+	tfState := map[string]any{}
+
+	for _, kind := range networkKinds {
+		interfaces := []map[string]any{}
+
+		for _, o := range layout.Interfaces {
+			if o.Kind != kind {
+				continue
+			}
+
+			links := make([]map[string]any, len(o.Links))
+			for i, link := range o.Links {
+				links[i] = map[string]any{
+					"default_gateway": link.DefaultGateway,
+					"id":              link.ID,
+					"ip_address":      link.IPAddress,
+					"mode":            link.Mode,
+					"subnet":          link.Subnet,
+				}
+			}
+
+			m := map[string]any{
+				"id":          o.ID,
+				"link":        links,
+				"mac_address": o.MACAddress,
+				"mtu":         o.MTU,
+				"name":        o.Name,
+				"tags":        o.Tags,
+			}
+
+			switch kind {
+			case networkKindBond:
+				m["parents"] = o.Parents
+			case networkKindVLAN:
+				m["parent"] = o.Parents[0]
+				m["vid"] = o.VID
+			case networkKindBridge:
+				m["parent"] = o.Parents[0]
+			}
+
+			if kind != networkKindVLAN {
+				m["vlan"] = o.VLAN
+			}
+
+			for _, k := range networkInterfaceParams[kind] {
+				m[k] = flattenNetworkInterfaceParam(o.Params[k])
+			}
+
+			interfaces = append(interfaces, m)
+		}
+
+		tfState[kind] = interfaces
+	}
+
+	return tfState
+}
+
+// flattenNetworkInterfaceParam returns the value of a setting reported by MAAS,
+// as an integer or a boolean when it is one.
+func flattenNetworkInterfaceParam(v string) any {
+	if n, err := strconv.Atoi(v); err == nil {
+		return n
+	}
+
+	if b, err := strconv.ParseBool(strings.ToLower(v)); err == nil {
+		return b
+	}
+
+	return v
+}
@@ -0,0 +1,283 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASMachineNetwork_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_NETWORK_INTERFACE_MACHINE")
+	cidr := testutils.GenerateRandomCIDR()
+	macAddressOne := testutils.RandomMAC()
+	macAddressTwo := testutils.RandomMAC()
+
+	var bondID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_NETWORK_INTERFACE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASMachineNetworkDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASMachineNetwork(machine, cidr, macAddressOne, macAddressTwo, 1500),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("maas_machine_network.test", "id", "data.maas_machine.machine", "id"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "physical.#", "2"),
+					resource.TestCheckResourceAttrSet("maas_machine_network.test", "physical.0.id"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bond.0.parents.#", "2"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bond.0.bond_mode", "active-backup"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bond.0.mtu", "1500"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bridge.0.link.#", "1"),
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bridge.0.link.0.mode", "STATIC"),
+					resource.TestCheckResourceAttrSet("maas_machine_network.test", "bridge.0.link.0.id"),
+					resource.TestCheckResourceAttrWith("maas_machine_network.test", "bond.0.id", func(value string) error {
+						bondID = value
+						return nil
+					}),
+				),
+			},
+			// Changing the MTU keeps the bond
+			{
+				Config: testAccMAASMachineNetwork(machine, cidr, macAddressOne, macAddressTwo, 9000),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine_network.test", "bond.0.mtu", "9000"),
+					resource.TestCheckResourceAttrWith("maas_machine_network.test", "bond.0.id", func(value string) error {
+						if value != bondID {
+							return fmt.Errorf("expected the bond (%s) to be kept, got %s", bondID, value)
+						}
+
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASMachineNetwork_invalidReference(t *testing.T) {
+	machine := os.Getenv("TF_ACC_NETWORK_INTERFACE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_NETWORK_INTERFACE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASMachineNetworkDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_machine_network" "test" {
+  machine = data.maas_machine.machine.id
+
+  bond {
+    name    = "bond0"
+    parents = ["missing"]
+  }
+}
+`, machine),
+				ExpectError: regexp.MustCompile(`bond "bond0": "missing" is not an interface of the layout`),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASMachineNetwork_rollback(t *testing.T) {
+	machine := os.Getenv("TF_ACC_NETWORK_INTERFACE_MACHINE")
+
+	var boot *entity.NetworkInterface
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_NETWORK_INTERFACE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASMachineNetworkDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			// The IP address is outside of the subnet, the boot interface and its link are restored
+			{
+				PreConfig: func() {
+					var err error
+					if boot, err = testAccGetMachineBootInterface(machine); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config:      testAccMAASMachineNetworkBadLink(machine, testutils.GenerateRandomCIDR()),
+				ExpectError: regexp.MustCompile(`failed to link physical "tfpxe0"`),
+			},
+			{
+				Config: fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+`, machine),
+				Check: func(s *terraform.State) error {
+					restored, err := testAccGetMachineBootInterface(machine)
+					if err != nil {
+						return err
+					}
+
+					if restored.Name != boot.Name {
+						return fmt.Errorf("expected the boot interface to be named %s, got %s", boot.Name, restored.Name)
+					}
+
+					if len(restored.Links) != len(boot.Links) {
+						return fmt.Errorf("expected %d links on the boot interface, got %d", len(boot.Links), len(restored.Links))
+					}
+
+					for i, link := range boot.Links {
+						if restored.Links[i].Mode != link.Mode || restored.Links[i].Subnet.ID != link.Subnet.ID {
+							return fmt.Errorf("expected the %s link to subnet %s to be restored, got %s to subnet %s", link.Mode, link.Subnet.CIDR, restored.Links[i].Mode, restored.Links[i].Subnet.CIDR)
+						}
+					}
+
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func testAccGetMachineBootInterface(identifier string) (*entity.NetworkInterface, error) {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	machines, err := conn.Machines.Get(&entity.MachinesParams{Hostname: []string{identifier}})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("machine (%s) not found", identifier)
+	}
+
+	return conn.NetworkInterface.Get(machines[0].SystemID, machines[0].BootInterface.ID)
+}
+
+func testAccMAASMachineNetworkBadLink(machine string, cidr string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_subnet" "test" {
+  cidr = %q
+}
+
+resource "maas_machine_network" "test" {
+  machine = data.maas_machine.machine.id
+
+  physical {
+    name        = "tfpxe0"
+    mac_address = data.maas_machine.machine.pxe_mac_address
+
+    link {
+      subnet     = maas_subnet.test.cidr
+      mode       = "STATIC"
+      ip_address = "192.0.2.10"
+    }
+  }
+}
+`, machine, cidr)
+}
+
+func testAccMAASMachineNetwork(machine string, cidr string, macAddressOne string, macAddressTwo string, mtu int) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_fabric" "test" {
+  name = "tf-fabric-machine-network"
+}
+
+data "maas_vlan" "test" {
+  fabric = maas_fabric.test.id
+  vlan   = 0
+}
+
+resource "maas_subnet" "test" {
+  fabric = maas_fabric.test.id
+  vlan   = data.maas_vlan.test.id
+  cidr   = %q
+}
+
+resource "maas_machine_network" "test" {
+  machine = data.maas_machine.machine.id
+
+  physical {
+    name        = "tfeth0"
+    mac_address = %q
+  }
+
+  physical {
+    name        = "tfeth1"
+    mac_address = %q
+  }
+
+  bond {
+    name    = "tfbond0"
+    parents = ["tfeth0", "tfeth1"]
+    mtu     = %d
+  }
+
+  bridge {
+    name   = "tfbr0"
+    parent = "tfbond0"
+
+    link {
+      subnet     = maas_subnet.test.cidr
+      mode       = "STATIC"
+      ip_address = cidrhost(maas_subnet.test.cidr, 10)
+    }
+  }
+}
+`, machine, cidr, macAddressOne, macAddressTwo, mtu)
+}
+
+func testAccCheckMAASMachineNetworkDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_machine_network" {
+			continue
+		}
+
+		for _, kind := range []string{"bond", "bridge", "vlan"} {
+			for i := 0; ; i++ {
+				v, ok := rs.Primary.Attributes[fmt.Sprintf("%s.%d.id", kind, i)]
+				if !ok {
+					break
+				}
+
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					return err
+				}
+
+				response, err := conn.NetworkInterface.Get(rs.Primary.ID, id)
+				if err == nil {
+					return fmt.Errorf("network interface %s (%d) still exists.", response.Name, id)
+				}
+
+				// 404 means destroyed, anything else is an error
+				if !strings.Contains(err.Error(), "404 Not Found") {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
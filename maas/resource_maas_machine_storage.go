@@ -0,0 +1,620 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASMachineStorage() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage the whole storage layout of a MAAS machine: partitions, RAIDs, bcaches, volume groups, logical volumes, filesystems and mounts. The changes are applied in dependency order, and rolled back if one of them fails. Changing a partition recreates it with the following partitions of its disk, and the objects using them. A disk used by a RAID, a bcache or a volume group outside of the layout is refused. The machine must be in the `New`, `Ready`, `Allocated`, `Broken` or `Failed testing` status.",
+		CreateContext: resourceMachineStorageCreate,
+		ReadContext:   resourceMachineStorageRead,
+		UpdateContext: resourceMachineStorageUpdate,
+		DeleteContext: resourceMachineStorageDelete,
+		CustomizeDiff: validateMachineStorageDisks,
+
+		Schema: map[string]*schema.Schema{
+			"bcache": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bcaches of the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineStorageFilesystemSchema(false, map[string]*schema.Schema{
+						"backing_device": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The disk, partition or RAID of the layout backing the bcache.",
+						},
+						"cache_mode": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "writeback",
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"writeback", "writethrough", "writearound"}, false)),
+							Description:      "The cache mode of the bcache. Valid options are: `writeback`, `writethrough` and `writearound`. Defaults to `writeback`.",
+						},
+						"cache_set": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the `bcache_cache_set` of the layout used by the bcache.",
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The bcache ID.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the bcache, used to reference it in the layout.",
+						},
+					}),
+				},
+			},
+			"bcache_cache_set": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bcache cache sets of the layout.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"device": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The disk, partition or RAID of the layout used as cache device.",
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The bcache cache set ID.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the cache set, used to reference it in the `bcache` blocks.",
+						},
+					},
+				},
+			},
+			"disk": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The physical disks of the layout. A disk is selected by its stable attributes, at least one of `id_path`, `model`, `serial`, `wwn`, `tags`, `min_size_gigabytes` or `max_size_gigabytes` must be set and they must match exactly one disk of the machine. The existing partitions and filesystem of a disk are removed when it is added to the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineStorageFilesystemSchema(false, withStorageSelectorSchema(map[string]*schema.Schema{
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The block device ID of the disk.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the disk, used to reference it in the layout. Its partitions are referenced as `<name>-part<N>`.",
+						},
+						"partition": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The partitions of the disk, in order.",
+							Elem: &schema.Resource{
+								Schema: withMachineStorageFilesystemSchema(true, map[string]*schema.Schema{
+									"bootable": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "Whether the partition is bootable. Defaults to `false`.",
+									},
+									"id": {
+										Type:        schema.TypeInt,
+										Computed:    true,
+										Description: "The partition ID.",
+									},
+									"size_gigabytes": {
+										Type:         schema.TypeInt,
+										Required:     true,
+										ValidateFunc: validation.IntAtLeast(1),
+										Description:  "The size of the partition (GB).",
+									},
+								}),
+							},
+						},
+					})),
+				},
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The identifier (system ID, hostname, or FQDN) of the machine.",
+			},
+			"raid": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The RAIDs of the layout.",
+				Elem: &schema.Resource{
+					Schema: withMachineStorageFilesystemSchema(false, map[string]*schema.Schema{
+						"devices": {
+							Type:        schema.TypeList,
+							Required:    true,
+							Description: "The active disks and partitions of the layout in the RAID.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The RAID ID.",
+						},
+						"level": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"0", "1", "5", "6", "10"}, false)),
+							Description:      "The RAID level. Valid options are: `0`, `1`, `5`, `6` and `10`.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the RAID, used to reference it in the layout.",
+						},
+						"spare_devices": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The spare disks and partitions of the layout in the RAID.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					}),
+				},
+			},
+			"volume_group": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The LVM volume groups of the layout.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"devices": {
+							Type:        schema.TypeList,
+							Required:    true,
+							Description: "The disks, partitions, RAIDs and bcaches of the layout in the volume group.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The volume group ID.",
+						},
+						"logical_volume": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The logical volumes of the volume group.",
+							Elem: &schema.Resource{
+								Schema: withMachineStorageFilesystemSchema(false, map[string]*schema.Schema{
+									"id": {
+										Type:        schema.TypeInt,
+										Computed:    true,
+										Description: "The block device ID of the logical volume.",
+									},
+									"name": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "The name of the logical volume.",
+									},
+									"size_gigabytes": {
+										Type:         schema.TypeInt,
+										Required:     true,
+										ValidateFunc: validation.IntAtLeast(1),
+										Description:  "The size of the logical volume (GB).",
+									},
+								}),
+							},
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the volume group.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// withMachineStorageFilesystemSchema adds the filesystem attributes of a device
+// to its schema. Only partitions can have a filesystem label.
+func withMachineStorageFilesystemSchema(label bool, s map[string]*schema.Schema) map[string]*schema.Schema {
+	filesystem := map[string]*schema.Schema{
+		"fs_type": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The filesystem type used to format the device (e.g. `ext4` or `xfs`). The device is not formatted if unset.",
+		},
+		"mount_options": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The options used to mount the filesystem.",
+		},
+		"mount_point": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The mount point of the filesystem. The filesystem is not mounted if unset.",
+		},
+	}
+
+	if label {
+		filesystem["label"] = &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The label of the filesystem.",
+		}
+	}
+
+	maps.Copy(s, filesystem)
+
+	return s
+}
+
+// validateMachineStorageDisks checks at plan time that each disk selector of the
+// layout matches exactly one physical disk of the machine, and no two selectors the same.
+func validateMachineStorageDisks(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	if !d.NewValueKnown("disk") || !d.NewValueKnown("machine") || len(d.Get("disk").([]any)) == 0 {
+		return nil
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return err
+	}
+
+	selected := map[string]int{}
+
+	for i, v := range d.Get("disk").([]any) {
+		selector := expandStorageSelector(v.(map[string]any))
+		if selector.String() == "" {
+			// Reported when applying the layout
+			continue
+		}
+
+		id, err := selectStorageDevice(machine.PhysicalBlockDeviceSet, selector)
+		if err != nil {
+			return fmt.Errorf("disk.%d: %w", i, err)
+		}
+
+		if j, ok := selected[id]; ok {
+			return fmt.Errorf("disk.%d: the disk is already selected by disk.%d", i, j)
+		}
+
+		selected[id] = i
+	}
+
+	return nil
+}
+
+func resourceMachineStorageCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its storage cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	applier := newMachineStorageApplier(client, machine, &machineStorageLayout{})
+
+	err = applier.apply(getMachineStorageLayout(d, false))
+
+	// Keep the objects created before a failure that could not be rolled back in the state
+	if len(applier.current.keys()) > 0 || err == nil {
+		d.SetId(machine.SystemID)
+
+		if err := setTerraformState(d, flattenMachineStorageLayout(applier.current)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMachineStorageRead(ctx, d, meta)
+}
+
+func resourceMachineStorageRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	if _, err := client.Machine.Get(d.Id()); err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	layout := getMachineStorageLayout(d, true)
+	if err := layout.refresh(client, d.Id()); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := setTerraformState(d, flattenMachineStorageLayout(layout)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceMachineStorageUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its storage cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	// The virtual block devices of the RAIDs and bcaches are only known by MAAS
+	current := getMachineStorageLayout(d, true)
+	if err := current.refresh(client, machine.SystemID); err != nil {
+		return diag.FromErr(err)
+	}
+
+	applier := newMachineStorageApplier(client, machine, current)
+
+	err = applier.apply(getMachineStorageLayout(d, false))
+
+	if err := setTerraformState(d, flattenMachineStorageLayout(applier.current)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMachineStorageRead(ctx, d, meta)
+}
+
+func resourceMachineStorageDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := client.Machine.Get(d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return diag.Errorf("machine (%s) is %s, its storage cannot be configured", machine.SystemID, machine.StatusName)
+	}
+
+	log.Printf("[DEBUG] Machine (%s) deleting the storage layout\n", machine.SystemID)
+
+	current := getMachineStorageLayout(d, true)
+	if err := current.refresh(client, machine.SystemID); err != nil {
+		return diag.FromErr(err)
+	}
+
+	applier := newMachineStorageApplier(client, machine, current)
+	if err := applier.destroy(); err != nil {
+		if err := setTerraformState(d, flattenMachineStorageLayout(applier.current)); err != nil {
+			return diag.FromErr(err)
+		}
+
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// getMachineStorageLayout returns the layout of the state when old is set, or
+// the one of the configuration otherwise.
+func getMachineStorageLayout(d *schema.ResourceData, old bool) *machineStorageLayout {
+	get := func(key string) []any {
+		o, n := d.GetChange(key)
+		if old {
+			return o.([]any)
+		}
+
+		return n.([]any)
+	}
+
+	layout := &machineStorageLayout{}
+
+	for _, v := range get("disk") {
+		m := v.(map[string]any)
+		disk := &storageDisk{
+			ID:         m["id"].(int),
+			Name:       m["name"].(string),
+			Selector:   expandStorageSelector(m),
+			Filesystem: expandStorageFilesystem(m),
+		}
+
+		for _, p := range m["partition"].([]any) {
+			pm := p.(map[string]any)
+			disk.Partitions = append(disk.Partitions, &storagePartition{
+				ID:            pm["id"].(int),
+				SizeGigabytes: pm["size_gigabytes"].(int),
+				Bootable:      pm["bootable"].(bool),
+				Filesystem:    expandStorageFilesystem(pm),
+			})
+		}
+
+		layout.Disks = append(layout.Disks, disk)
+	}
+
+	for _, v := range get("raid") {
+		m := v.(map[string]any)
+		layout.RAIDs = append(layout.RAIDs, &storageRAID{
+			ID:           m["id"].(int),
+			Name:         m["name"].(string),
+			Level:        m["level"].(string),
+			Devices:      convertToStringSlice(m["devices"]),
+			SpareDevices: convertToStringSlice(m["spare_devices"]),
+			Filesystem:   expandStorageFilesystem(m),
+		})
+	}
+
+	for _, v := range get("bcache_cache_set") {
+		m := v.(map[string]any)
+		layout.CacheSets = append(layout.CacheSets, &storageCacheSet{
+			ID:     m["id"].(int),
+			Name:   m["name"].(string),
+			Device: m["device"].(string),
+		})
+	}
+
+	for _, v := range get("bcache") {
+		m := v.(map[string]any)
+		layout.BCaches = append(layout.BCaches, &storageBCache{
+			ID:            m["id"].(int),
+			Name:          m["name"].(string),
+			BackingDevice: m["backing_device"].(string),
+			CacheSet:      m["cache_set"].(string),
+			CacheMode:     m["cache_mode"].(string),
+			Filesystem:    expandStorageFilesystem(m),
+		})
+	}
+
+	for _, v := range get("volume_group") {
+		m := v.(map[string]any)
+		vg := &storageVolumeGroup{
+			ID:      m["id"].(int),
+			Name:    m["name"].(string),
+			Devices: convertToStringSlice(m["devices"]),
+		}
+
+		for _, lv := range m["logical_volume"].([]any) {
+			lvm := lv.(map[string]any)
+			vg.LogicalVolumes = append(vg.LogicalVolumes, &storageLogicalVolume{
+				ID:            lvm["id"].(int),
+				Name:          lvm["name"].(string),
+				SizeGigabytes: lvm["size_gigabytes"].(int),
+				Filesystem:    expandStorageFilesystem(lvm),
+			})
+		}
+
+		layout.VolumeGroups = append(layout.VolumeGroups, vg)
+	}
+
+	return layout
+}
+
+func expandStorageFilesystem(m map[string]any) storageFilesystem {
+	fs := storageFilesystem{
+		FSType:       m["fs_type"].(string),
+		MountPoint:   m["mount_point"].(string),
+		MountOptions: m["mount_options"].(string),
+	}
+
+	if label, ok := m["label"]; ok {
+		fs.Label = label.(string)
+	}
+
+	return fs
+}
+
+func flattenStorageFilesystem(m map[string]any, fs storageFilesystem) map[string]any {
+	m["fs_type"] = fs.FSType
+	m["mount_options"] = fs.MountOptions
+	m["mount_point"] = fs.MountPoint
+
+	return m
+}
+
+func flattenMachineStorageLayout(layout *machineStorageLayout) map[string]any {
+	disks := make([]map[string]any, len(layout.Disks))
+
+	for i, o := range layout.Disks {
+		partitions := make([]map[string]any, len(o.Partitions))
+		for j, p := range o.Partitions {
+			partitions[j] = flattenStorageFilesystem(map[string]any{
+				"bootable":       p.Bootable,
+				"id":             p.ID,
+				"label":          p.Filesystem.Label,
+				"size_gigabytes": p.SizeGigabytes,
+			}, p.Filesystem)
+		}
+
+		disks[i] = flattenStorageFilesystem(map[string]any{
+			"id":                 o.ID,
+			"id_path":            o.Selector.IDPath,
+			"max_size_gigabytes": o.Selector.MaxSizeGigabytes,
+			"min_size_gigabytes": o.Selector.MinSizeGigabytes,
+			"model":              o.Selector.Model,
+			"name":               o.Name,
+			"partition":          partitions,
+			"serial":             o.Selector.Serial,
+			"tags":               o.Selector.Tags,
+			"wwn":                o.Selector.WWN,
+		}, o.Filesystem)
+	}
+
+	raids := make([]map[string]any, len(layout.RAIDs))
+	for i, o := range layout.RAIDs {
+		raids[i] = flattenStorageFilesystem(map[string]any{
+			"devices":       o.Devices,
+			"id":            o.ID,
+			"level":         o.Level,
+			"name":          o.Name,
+			"spare_devices": o.SpareDevices,
+		}, o.Filesystem)
+	}
+
+	cacheSets := make([]map[string]any, len(layout.CacheSets))
+	for i, o := range layout.CacheSets {
+		cacheSets[i] = map[string]any{
+			"device": o.Device,
+			"id":     o.ID,
+			"name":   o.Name,
+		}
+	}
+
+	bcaches := make([]map[string]any, len(layout.BCaches))
+	for i, o := range layout.BCaches {
+		bcaches[i] = flattenStorageFilesystem(map[string]any{
+			"backing_device": o.BackingDevice,
+			"cache_mode":     o.CacheMode,
+			"cache_set":      o.CacheSet,
+			"id":             o.ID,
+			"name":           o.Name,
+		}, o.Filesystem)
+	}
+
+	volumeGroups := make([]map[string]any, len(layout.VolumeGroups))
+
+	for i, o := range layout.VolumeGroups {
+		logicalVolumes := make([]map[string]any, len(o.LogicalVolumes))
+		for j, lv := range o.LogicalVolumes {
+			logicalVolumes[j] = flattenStorageFilesystem(map[string]any{
+				"id":             lv.ID,
+				"name":           lv.Name,
+				"size_gigabytes": lv.SizeGigabytes,
+			}, lv.Filesystem)
+		}
+
+		volumeGroups[i] = map[string]any{
+			"devices":        o.Devices,
+			"id":             o.ID,
+			"logical_volume": logicalVolumes,
+			"name":           o.Name,
+		}
+	}
+
+	return map[string]any{
+		"bcache":           bcaches,
+		"bcache_cache_set": cacheSets,
+		"disk":             disks,
+		"raid":             raids,
+		"volume_group":     volumeGroups,
+	}
+}
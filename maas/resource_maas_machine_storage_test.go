@@ -0,0 +1,210 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASMachineStorage_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	var raidID, partitionID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASMachineStorageDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASMachineStorage(machine, 10, "/data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("maas_machine_storage.test", "id", "data.maas_machine.machine", "id"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "disk.#", "2"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "disk.0.partition.#", "2"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "disk.0.partition.0.mount_point", "/srv"),
+					resource.TestCheckResourceAttrSet("maas_machine_storage.test", "disk.0.partition.0.id"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "raid.0.devices.#", "2"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "raid.0.mount_point", "/data"),
+					resource.TestCheckResourceAttrWith("maas_machine_storage.test", "raid.0.id", func(value string) error {
+						raidID = value
+						return nil
+					}),
+					resource.TestCheckResourceAttrWith("maas_machine_storage.test", "disk.0.partition.0.id", func(value string) error {
+						partitionID = value
+						return nil
+					}),
+				),
+			},
+			// Changing the mount point keeps the RAID
+			{
+				Config: testAccMAASMachineStorage(machine, 10, "/srv/data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "raid.0.mount_point", "/srv/data"),
+					resource.TestCheckResourceAttrWith("maas_machine_storage.test", "raid.0.id", func(value string) error {
+						if value != raidID {
+							return fmt.Errorf("expected the RAID (%s) to be kept, got %s", raidID, value)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// Resizing a RAID member partition recreates the RAID, the partition before it is kept
+			{
+				Config: testAccMAASMachineStorage(machine, 12, "/srv/data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "disk.0.partition.1.size_gigabytes", "12"),
+					resource.TestCheckResourceAttr("maas_machine_storage.test", "disk.0.partition.0.mount_point", "/srv"),
+					resource.TestCheckResourceAttrWith("maas_machine_storage.test", "disk.0.partition.0.id", func(value string) error {
+						if value != partitionID {
+							return fmt.Errorf("expected the partition (%s) to be kept, got %s", partitionID, value)
+						}
+
+						return nil
+					}),
+					resource.TestCheckResourceAttrWith("maas_machine_storage.test", "raid.0.id", func(value string) error {
+						if value == raidID {
+							return fmt.Errorf("expected the RAID (%s) to be recreated", raidID)
+						}
+
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceMAASMachineStorage_invalidReference(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASMachineStorageDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_machine_storage" "test" {
+  machine = data.maas_machine.machine.id
+
+  volume_group {
+    name    = "vg0"
+    devices = ["missing-part1"]
+  }
+}
+`, machine),
+				ExpectError: regexp.MustCompile(`"missing-part1" is not a disk, partition, RAID or bcache of the layout`),
+			},
+		},
+	})
+}
+
+func testAccMAASMachineStorage(machine string, memberSize int, mountPoint string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_block_device" "sda" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfsda"
+  size_gigabytes = 30
+  block_size     = 512
+  id_path        = "/dev/tfsda"
+}
+
+resource "maas_block_device" "sdb" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfsdb"
+  size_gigabytes = 30
+  block_size     = 512
+  id_path        = "/dev/tfsdb"
+}
+
+resource "maas_machine_storage" "test" {
+  machine = data.maas_machine.machine.id
+
+  disk {
+    name    = "a"
+    id_path = maas_block_device.sda.id_path
+
+    partition {
+      size_gigabytes = 10
+      fs_type        = "ext4"
+      mount_point    = "/srv"
+    }
+
+    partition {
+      size_gigabytes = %d
+    }
+  }
+
+  disk {
+    name    = "b"
+    id_path = maas_block_device.sdb.id_path
+
+    partition {
+      size_gigabytes = 10
+    }
+  }
+
+  raid {
+    name        = "md0"
+    level       = "1"
+    devices     = ["a-part2", "b-part1"]
+    fs_type     = "ext4"
+    mount_point = %q
+  }
+}
+`, machine, memberSize, mountPoint)
+}
+
+func testAccCheckMAASMachineStorageDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_machine_storage" {
+			continue
+		}
+
+		for i := 0; ; i++ {
+			v, ok := rs.Primary.Attributes[fmt.Sprintf("raid.%d.id", i)]
+			if !ok {
+				break
+			}
+
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+
+			response, err := conn.RAID.Get(rs.Primary.ID, id)
+			if err == nil {
+				return fmt.Errorf("RAID %s (%d) still exists.", response.Name, id)
+			}
+
+			// 404 means destroyed, anything else is an error
+			if !strings.Contains(err.Error(), "404 Not Found") {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -131,3 +131,55 @@ resource "maas_machine" "test" {
 }
 `, ipAddress)
 }
+
+func TestAccResourceMAASMachine_onDestroy(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMAASMachineOnDestroy("release"),
+				ExpectError: regexp.MustCompile(`expected on_destroy to be one of`),
+			},
+			{
+				Config: testAccMAASMachineOnDestroy("release_then_delete"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine.test", "on_destroy", "release_then_delete"),
+				),
+				// Verify the plan is valid, don't actually create the machines.
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccMAASMachineOnDestroy("fail_if_deployed"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_machine.test", "on_destroy", "fail_if_deployed"),
+				),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccMAASMachineOnDestroy(onDestroy string) string {
+	return fmt.Sprintf(`
+resource "maas_machine" "test" {
+  power_type = "ipmi"
+  power_parameters = jsonencode({
+    power_address = "10.0.0.10"
+    power_user    = "admin"
+    power_pass    = "password"
+  })
+  hostname   = "ipmiTestMachineOnDestroy"
+  on_destroy = %q
+
+  release_params {
+    erase       = true
+    quick_erase = true
+  }
+}
+`, onDestroy)
+}
@@ -0,0 +1,243 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/canonical/gomaasclient/entity/node"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceMAASMachineTestRun() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to run hardware tests on MAAS machines in the `Ready` status, and wait for the machines to be `Ready` again. The apply fails if any of the tests fails. If the tests cannot be started on one of the machines, the tests already started on the others are aborted. Any change of the arguments runs the tests again.",
+		CreateContext: resourceMachineTestRunCreate,
+		ReadContext:   resourceMachineTestRunRead,
+		DeleteContext: resourceMachineTestRunDelete,
+
+		Schema: map[string]*schema.Schema{
+			"machines": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The identifiers (system ID, hostname, FQDN or PXE MAC address) of the machines to test.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"mark_broken_on_failure": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Mark the machines with failed tests as `Broken`. Defaults to `false`.",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The results of the test scripts run on the machines.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"exit_status": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The exit status of the script, `-1` if the script did not run to completion.",
+						},
+						"machine": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The system ID of the tested machine.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the test script.",
+						},
+						"output_digest": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The SHA-256 digest of the script output.",
+						},
+						"status": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The status of the script (e.g. `Passed`, `Failed` or `Timed out`).",
+						},
+					},
+				},
+			},
+			"script_parameters": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Scripts specified to run may define their own parameters. These parameters may be passed as parameter name (key) value pairs as a map. Optionally a parameter may have the script name prepended to have that parameter only apply to that specific script, e.g. my-script_param=value.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"testing_scripts": {
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MinItems:    1,
+				Description: "Testing script names and tags to be run (e.g. `smartctl-validate`, `memtester` or `stress-ng-cpu-short`).",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "A map of arbitrary values that, when changed, runs the tests again.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+		},
+	}
+}
+
+func resourceMachineTestRunCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	identifiers := convertToStringSlice(d.Get("machines").(*schema.Set).List())
+
+	systemIDs := make([]string, 0, len(identifiers))
+
+	for _, identifier := range identifiers {
+		machine, err := getMachine(client, identifier)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if machine.Status != node.StatusReady {
+			return diag.Errorf("machine (%s) is %s, only machines in the Ready status can be tested", machine.SystemID, machine.StatusName)
+		}
+
+		systemIDs = append(systemIDs, machine.SystemID)
+	}
+
+	sort.Strings(systemIDs)
+
+	params := getMachineTestParams(d)
+	for i, systemID := range systemIDs {
+		if err := machineOperation(client, systemID, "test", params, nil); err != nil {
+			diags := diag.FromErr(fmt.Errorf("machine (%s): %w", systemID, err))
+
+			// The test run is not saved, abort the tests already started to return
+			// these machines to the Ready status
+			for _, started := range systemIDs[:i] {
+				if _, err := client.Machine.Abort(started, "Test run failed on machine "+systemID); err != nil {
+					diags = append(diags, diag.Diagnostic{
+						Severity: diag.Warning,
+						Summary:  fmt.Sprintf("Unable to abort the tests of machine %s", started),
+						Detail:   err.Error(),
+					})
+				}
+			}
+
+			return diags
+		}
+	}
+
+	d.SetId(id.UniqueId())
+
+	resultSets := make([]*nodeScriptResultSet, len(systemIDs))
+	errs := runConcurrently(len(systemIDs), len(systemIDs), func(i int) error {
+		_, err := waitForMachineStatus(ctx, client, systemIDs[i], []string{"Testing"}, []string{"Ready", "Failed testing"}, d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return err
+		}
+
+		resultSets[i], err = getNodeScriptResultSet(client, systemIDs[i], "current-testing")
+
+		return err
+	})
+
+	var diags diag.Diagnostics
+
+	results := []map[string]any{}
+
+	for i, systemID := range systemIDs {
+		if errs[i] != nil {
+			diags = append(diags, diag.Errorf("machine (%s): %s", systemID, errs[i])...)
+			continue
+		}
+
+		var failed []string
+
+		for _, result := range resultSets[i].Results {
+			digest, err := result.outputDigest()
+			if err != nil {
+				return diag.FromErr(err)
+			}
+
+			results = append(results, map[string]any{
+				"exit_status":   result.exitStatus(),
+				"machine":       systemID,
+				"name":          result.Name,
+				"output_digest": digest,
+				"status":        result.StatusName,
+			})
+
+			if result.isFailed() {
+				failed = append(failed, fmt.Sprintf("%s (%s)", result.Name, result.StatusName))
+			}
+		}
+
+		if len(failed) == 0 {
+			continue
+		}
+
+		diags = append(diags, diag.Errorf("machine (%s) failed testing: %s", systemID, strings.Join(failed, ", "))...)
+
+		if d.Get("mark_broken_on_failure").(bool) {
+			log.Printf("[DEBUG] Machine (%s) marking as broken\n", systemID)
+
+			if _, err := client.Machine.MarkBroken(systemID, "Failed testing: "+strings.Join(failed, ", ")); err != nil {
+				diags = append(diags, diag.Errorf("machine (%s): %s", systemID, err)...)
+			}
+		}
+	}
+
+	if err := d.Set("results", results); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+func resourceMachineTestRunRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	// The results are the ones of the test run, they are not refreshed since the
+	// machines may have been tested again since.
+	return nil
+}
+
+func resourceMachineTestRunDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	// Test results cannot be deleted, just remove the test run from the state.
+	d.SetId("")
+	return nil
+}
+
+func getMachineTestParams(d *schema.ResourceData) url.Values {
+	params := url.Values{
+		"testing_scripts": {listAsString(d.Get("testing_scripts").([]any))},
+	}
+
+	for k, v := range d.Get("script_parameters").(map[string]any) {
+		params.Add(k, v.(string))
+	}
+
+	return params
+}
@@ -0,0 +1,45 @@
+package maas_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASMachineTestRun_validation(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMAASMachineTestRun(""),
+				ExpectError: regexp.MustCompile(`Attribute requires 1 item minimum`),
+			},
+			{
+				Config: testAccMAASMachineTestRun(`"smartctl-validate", "memtester"`),
+				// Verify the plan is valid, don't actually test the machines.
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccMAASMachineTestRun(testingScripts string) string {
+	return fmt.Sprintf(`
+resource "maas_machine_test_run" "test" {
+  machines        = ["tf-test-machine"]
+  testing_scripts = [%s]
+  script_parameters = {
+    "memtester_runtime" = "60"
+  }
+}
+`, testingScripts)
+}
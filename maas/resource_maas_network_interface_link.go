@@ -3,7 +3,9 @@ package maas
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/canonical/gomaasclient/client"
 	"github.com/canonical/gomaasclient/entity"
@@ -14,11 +16,14 @@ import (
 
 func resourceMAASNetworkInterfaceLink() *schema.Resource {
 	return &schema.Resource{
-		Description:   "Provides a resource to manage network configuration on a network interface.",
+		Description:   "Provides a resource to manage network configuration on a network interface. Changing the mode, IP address or subnet updates the link in place, without recreating the resource. The machine must be in the `New`, `Ready`, `Allocated`, `Broken` or `Failed testing` status to update it.",
 		CreateContext: resourceNetworkInterfaceLinkCreate,
 		ReadContext:   resourceNetworkInterfaceLinkRead,
 		UpdateContext: resourceNetworkInterfaceLinkUpdate,
 		DeleteContext: resourceNetworkInterfaceLinkDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceNetworkInterfaceLinkImport,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"default_gateway": {
@@ -37,7 +42,6 @@ func resourceMAASNetworkInterfaceLink() *schema.Resource {
 			"ip_address": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				ForceNew:         true,
 				Computed:         true,
 				ValidateDiagFunc: validation.ToDiagFunc(validation.IsIPAddress),
 				Description:      "Valid IP address (from the given subnet) to be configured on the network interface. Only used when `mode` is set to `STATIC`.",
@@ -51,7 +55,6 @@ func resourceMAASNetworkInterfaceLink() *schema.Resource {
 			"mode": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				ForceNew:         true,
 				Default:          "AUTO",
 				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"AUTO", "DHCP", "STATIC", "LINK_UP"}, false)),
 				Description:      "Connection mode to subnet. It defaults to `AUTO`. Valid options are:\n\t* `AUTO` - Random static IP address from the subnet.\n\t* `DHCP` - IP address from the DHCP on the given subnet.\n\t* `STATIC` - Use `ip_address` as static IP address.\n\t* `LINK_UP` - Bring the interface up only on the given subnet. No IP address will be assigned.",
@@ -65,7 +68,6 @@ func resourceMAASNetworkInterfaceLink() *schema.Resource {
 			"subnet": {
 				Type:        schema.TypeString,
 				Required:    true,
-				ForceNew:    true,
 				Description: "The identifier (CIDR or ID) of the subnet to be connected.",
 			},
 		},
@@ -126,8 +128,28 @@ func resourceNetworkInterfaceLinkRead(ctx context.Context, d *schema.ResourceDat
 		return unsetIfNotFoundError(d, err)
 	}
 
+	tfState := map[string]any{
+		"ip_address": link.IPAddress,
+		"mode":       strings.ToUpper(link.Mode),
+		"subnet":     link.Subnet.CIDR,
+	}
+
+	// Keep the subnet as configured, by CIDR or ID, when it did not change
+	if d.Get("subnet").(string) == strconv.Itoa(link.Subnet.ID) {
+		tfState["subnet"] = d.Get("subnet")
+	}
+
+	if d.Get("machine") != "" {
+		machine, err := client.Machine.Get(systemID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		tfState["default_gateway"] = machine.DefaultGateways.IPv4.LinkID == linkID || machine.DefaultGateways.IPv6.LinkID == linkID
+	}
+
 	// Set the Terraform state
-	if err := d.Set("ip_address", link.IPAddress); err != nil {
+	if err := setTerraformState(d, tfState); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -143,33 +165,62 @@ func resourceNetworkInterfaceLinkUpdate(ctx context.Context, d *schema.ResourceD
 		return diag.FromErr(err)
 	}
 
-	// Update only happens when default_gateway changes (the only non-ForceNew field).
-	// Since default_gateway has ConflictsWith device, we know this must be a machine.
-	machine, err := getMachine(client, d.Get("machine").(string))
+	systemID, err := getMachineOrDeviceSystemID(client, d)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	if !isMachineInPermittedState(machine) {
-		return diag.Errorf("machine is not in a permitted state for update")
-	}
+	if d.Get("machine") != "" {
+		machine, err := client.Machine.Get(systemID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 
-	systemID := machine.SystemID
+		if !isMachineInPermittedState(machine) {
+			return diag.Errorf("machine (%s) is %s, its network interface links cannot be updated", machine.SystemID, machine.StatusName)
+		}
+	}
 
 	networkInterface, err := getNetworkInterface(client, systemID, d.Get("network_interface").(string))
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	// Clear existing default gateways before potentially setting a new one
-	if _, err := client.Machine.ClearDefaultGateways(systemID); err != nil {
-		return diag.FromErr(err)
+	if d.HasChanges("mode", "ip_address", "subnet") {
+		subnet, err := getSubnet(client, d.Get("subnet").(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		params := getNetworkInterfaceLinkParams(d, subnet.ID)
+
+		// The computed IP address of the previous subnet cannot be used on the new one
+		if d.HasChange("subnet") && !d.HasChange("ip_address") {
+			params.IPAddress = ""
+		}
+
+		link, err := updateNetworkInterfaceLink(client, systemID, networkInterface, linkID, params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		d.SetId(strconv.Itoa(link.ID))
+		linkID = link.ID
 	}
 
-	if d.Get("default_gateway").(bool) {
-		if _, err := client.NetworkInterface.SetDefaultGateway(systemID, networkInterface.ID, linkID); err != nil {
+	// Since default_gateway has ConflictsWith device, we know this must be a machine.
+	// The default gateway is set again on a new link.
+	if d.HasChange("default_gateway") || (d.HasChanges("mode", "ip_address", "subnet") && d.Get("default_gateway").(bool)) {
+		// Clear existing default gateways before potentially setting a new one
+		if _, err := client.Machine.ClearDefaultGateways(systemID); err != nil {
 			return diag.FromErr(err)
 		}
+
+		if d.Get("default_gateway").(bool) {
+			if _, err := client.NetworkInterface.SetDefaultGateway(systemID, networkInterface.ID, linkID); err != nil {
+				return diag.FromErr(err)
+			}
+		}
 	}
 
 	return resourceNetworkInterfaceLinkRead(ctx, d, meta)
@@ -202,6 +253,45 @@ func resourceNetworkInterfaceLinkDelete(ctx context.Context, d *schema.ResourceD
 	return nil
 }
 
+func resourceNetworkInterfaceLinkImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.Split(d.Id(), ":")
+	if len(idParts) != 3 || idParts[0] == "" || idParts[1] == "" || idParts[2] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE:INTERFACE:LINK_ID", d.Id())
+	}
+
+	linkID, err := strconv.Atoi(idParts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LINK_ID, expected int-like, err: %v", err)
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	networkInterface, err := getNetworkInterface(client, machine.SystemID, idParts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := getNetworkInterfaceLink(client, networkInterface, linkID); err != nil {
+		return nil, err
+	}
+
+	tfState := map[string]any{
+		"id":                strconv.Itoa(linkID),
+		"machine":           machine.SystemID,
+		"network_interface": strconv.Itoa(networkInterface.ID),
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func getNetworkInterfaceLinkParams(d *schema.ResourceData, subnetID int) *entity.NetworkInterfaceLinkParams {
 	return &entity.NetworkInterfaceLinkParams{
 		Subnet:         subnetID,
@@ -229,6 +319,62 @@ func createNetworkInterfaceLink(client *client.Client, machineSystemID string, n
 	return &networkInterface.Links[0], nil
 }
 
+// updateNetworkInterfaceLink replaces a link of the network interface with a new one
+// with the given parameters, keeping the other links. The new link is created before
+// the previous one is removed, so that the interface keeps an address, unless the
+// static IP address of the previous link is reused or either link is LINK_UP. If
+// the new link cannot be created then, the previous one is restored.
+func updateNetworkInterfaceLink(client *client.Client, machineSystemID string, networkInterface *entity.NetworkInterface, linkID int, params *entity.NetworkInterfaceLinkParams) (*entity.NetworkInterfaceLink, error) {
+	previous, err := getNetworkInterfaceLink(client, networkInterface, linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A static IP address can only be moved to another link once released, and
+	// LINK_UP cannot be set next to other links
+	unlinkFirst := params.Mode == "LINK_UP" || strings.EqualFold(previous.Mode, "LINK_UP") ||
+		(params.Mode == "STATIC" && params.IPAddress != "" && params.IPAddress == previous.IPAddress)
+
+	if unlinkFirst {
+		if err := deleteNetworkInterfaceLink(client, machineSystemID, networkInterface.ID, linkID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := client.NetworkInterface.LinkSubnet(machineSystemID, networkInterface.ID, params)
+	if err != nil {
+		if !unlinkFirst {
+			return nil, err
+		}
+
+		restore := &entity.NetworkInterfaceLinkParams{Subnet: previous.Subnet.ID, Mode: strings.ToUpper(previous.Mode)}
+		if restore.Mode == "STATIC" {
+			restore.IPAddress = previous.IPAddress
+		}
+
+		if _, restoreErr := client.NetworkInterface.LinkSubnet(machineSystemID, networkInterface.ID, restore); restoreErr != nil {
+			return nil, fmt.Errorf("%w\nAdditionally, the previous link could not be restored: %w", err, restoreErr)
+		}
+
+		return nil, err
+	}
+
+	if !unlinkFirst {
+		if err := deleteNetworkInterfaceLink(client, machineSystemID, networkInterface.ID, linkID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The new link is the one the interface did not have
+	for _, link := range updated.Links {
+		if !slices.ContainsFunc(networkInterface.Links, func(l entity.NetworkInterfaceLink) bool { return l.ID == link.ID }) {
+			return &link, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot find the new link on the network interface (%v) from machine (%s)", networkInterface.ID, machineSystemID)
+}
+
 func getNetworkInterfaceLink(client *client.Client, networkInterface *entity.NetworkInterface, linkID int) (*entity.NetworkInterfaceLink, error) {
 	for _, link := range networkInterface.Links {
 		if link.ID == linkID {
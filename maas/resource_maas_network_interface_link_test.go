@@ -1,8 +1,10 @@
 package maas_test
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"terraform-provider-maas/maas"
 	"terraform-provider-maas/maas/testutils"
@@ -166,16 +168,123 @@ func TestAccResourceMAASNetworkInterfaceLink_basic(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					append(checks, resource.TestCheckResourceAttr("maas_network_interface_link.test", "ip_address", "30.30.30.2"))...),
 			},
-			// Test update
+			// Test the in-place update
 			{
 				Config: testAccMAASNetworkInterfaceLink(machine, cidr, gateway, "30.30.30.3", macAddress),
 				Check: resource.ComposeTestCheckFunc(
-					append(checks, resource.TestCheckResourceAttr("maas_network_interface_link.test", "ip_address", "30.30.30.3"))...),
+					append(checks,
+						resource.TestCheckResourceAttr("maas_network_interface_link.test", "ip_address", "30.30.30.3"),
+						testAccMAASNetworkInterfaceLinkCheckAddresses("maas_network_interface_link.test", "30.30.30.3"),
+					)...),
+			},
+			// Test import
+			{
+				ResourceName:      "maas_network_interface_link.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					rs, ok := s.RootModule().Resources["maas_network_interface_link.test"]
+					if !ok {
+						return "", fmt.Errorf("resource not found: %s", "maas_network_interface_link.test")
+					}
+
+					return fmt.Sprintf("%s:%s:%s", rs.Primary.Attributes["machine"], rs.Primary.Attributes["network_interface"], rs.Primary.ID), nil
+				},
 			},
 		},
 	})
 }
 
+func TestResourceMAASNetworkInterfaceLink_updateInPlace(t *testing.T) {
+	r := maas.Provider().ResourcesMap["maas_network_interface_link"]
+
+	state := &terraform.InstanceState{
+		ID: "42",
+		Attributes: map[string]string{
+			"id":                "42",
+			"machine":           "abc123",
+			"network_interface": "7",
+			"subnet":            "30.30.30.0/24",
+			"mode":              "STATIC",
+			"ip_address":        "30.30.30.2",
+			"default_gateway":   "true",
+		},
+	}
+
+	testCases := map[string]map[string]any{
+		"ip_address": {"ip_address": "30.30.30.3"},
+		"mode":       {"mode": "AUTO", "ip_address": nil},
+		"subnet":     {"subnet": "40.40.40.0/24", "ip_address": "40.40.40.2"},
+	}
+
+	for name, changes := range testCases {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]any{
+				"machine":           "abc123",
+				"network_interface": "7",
+				"subnet":            "30.30.30.0/24",
+				"mode":              "STATIC",
+				"ip_address":        "30.30.30.2",
+				"default_gateway":   true,
+			}
+			for k, v := range changes {
+				if v == nil {
+					delete(raw, k)
+				} else {
+					raw[k] = v
+				}
+			}
+
+			diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff.Empty() {
+				t.Fatal("expected a diff")
+			}
+
+			if diff.RequiresNew() {
+				t.Fatalf("expected the link to be updated in place, got a replacement: %v", diff)
+			}
+		})
+	}
+}
+
+// testAccMAASNetworkInterfaceLinkCheckAddresses checks that the network interface of
+// the link only has the given IP addresses, e.g. after the link was updated.
+func testAccMAASNetworkInterfaceLinkCheckAddresses(rn string, ipAddresses ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+		networkInterfaceID, err := strconv.Atoi(rs.Primary.Attributes["network_interface"])
+		if err != nil {
+			return err
+		}
+
+		networkInterface, err := conn.NetworkInterface.Get(rs.Primary.Attributes["machine"], networkInterfaceID)
+		if err != nil {
+			return fmt.Errorf("error getting network interface: %s", err)
+		}
+
+		var got []string
+		for _, link := range networkInterface.Links {
+			got = append(got, link.IPAddress)
+		}
+
+		if !slices.Equal(got, ipAddresses) {
+			return fmt.Errorf("expected the network interface (%d) to have the IP addresses %v, got %v", networkInterfaceID, ipAddresses, got)
+		}
+
+		return nil
+	}
+}
+
 func testAccMAASNetworkInterfaceLinkCheckExists(rn string, nodeType string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -0,0 +1,312 @@
+package maas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASNetworkTemplate() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to define a network layout shared by machines with identical network cards. The physical interfaces are matched by roles, and the bonds, VLANs and bridges reference the roles instead of the interface names. The template is only stored in the Terraform state, it is applied to machines with the `maas_network_template_attachment` resource.",
+		CreateContext: resourceNetworkTemplateCreate,
+		ReadContext:   schema.NoopContext,
+		UpdateContext: resourceNetworkTemplateUpdate,
+		DeleteContext: schema.NoopContext,
+		CustomizeDiff: customizeNetworkTemplateDiff,
+
+		Schema: map[string]*schema.Schema{
+			"bond": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bonds of the template.",
+				Elem: &schema.Resource{
+					Schema: withNetworkBondSchema(map[string]*schema.Schema{
+						"link": networkTemplateLinkSchema(),
+						"mtu": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "The MTU of the bond. The MTU is left unchanged if it's not set.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the bond.",
+						},
+						"parents": {
+							Type:        schema.TypeList,
+							Required:    true,
+							MinItems:    1,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The roles, or the names of the physical interfaces, bonded together. A role stands for all the interfaces it matches.",
+						},
+					}),
+				},
+			},
+			"bridge": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The bridges of the template.",
+				Elem: &schema.Resource{
+					Schema: withNetworkBridgeSchema(map[string]*schema.Schema{
+						"link": networkTemplateLinkSchema(),
+						"mtu": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "The MTU of the bridge. The MTU is left unchanged if it's not set.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the bridge.",
+						},
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The role matching a single interface, or the name of the bond or VLAN interface, bridged.",
+						},
+					}),
+				},
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The JSON encoded template, to be used as the `template` of `maas_network_template_attachment` resources.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the template.",
+			},
+			"role": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Description: "The roles of the physical interfaces. Each physical interface of a machine is assigned to the first role it matches, the interfaces not matching any role are left unchanged.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"count": {
+							Type:             schema.TypeInt,
+							Optional:         true,
+							Default:          1,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.IntAtLeast(1)),
+							Description:      "The number of interfaces the role must match on each machine. Defaults to `1`.",
+						},
+						"link": networkTemplateLinkSchema(),
+						"link_speed": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "The link speed, in Mbit/s, of the interfaces matched.",
+						},
+						"mtu": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "The MTU of the interfaces matched. The MTU is left unchanged if it's not set.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the role, used to reference the interfaces it matches in the template.",
+						},
+						"name_pattern": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateDiagFunc: validation.ToDiagFunc(validation.StringIsValidRegExp),
+							Description:      "A regular expression matching the names of the interfaces, e.g. `^enp1s0f[01]$`.",
+						},
+						"product": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The PCI product (device) name of the interfaces matched, case insensitive.",
+						},
+						"vendor": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The PCI vendor name of the interfaces matched, case insensitive.",
+						},
+					},
+				},
+			},
+			"vlan": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The VLAN interfaces of the template, named `<parent>.<vid>` on the machines.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"link": networkTemplateLinkSchema(),
+						"mtu": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "The MTU of the VLAN interface. The MTU is left unchanged if it's not set.",
+						},
+						"parent": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The role matching a single interface, or the name of the bond, tagged.",
+						},
+						"vid": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(1, 4094),
+							Description:  "The VID of the VLAN, on the fabric of the parent.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// networkTemplateLinkSchema returns the schema of the IP assignment policy of the
+// interfaces of a template. Static addresses are specific to each machine, so
+// they are not supported.
+func networkTemplateLinkSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: "The subnet links of the interface, in order.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"default_gateway": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Whether the gateway of the subnet is a default gateway of the machines. This option can only be used with the `AUTO` mode. Defaults to `false`.",
+				},
+				"mode": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					Default:          "AUTO",
+					ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"AUTO", "DHCP", "LINK_UP"}, false)),
+					Description:      "Connection mode to subnet. Valid options are: `AUTO`, `DHCP` and `LINK_UP`. Defaults to `AUTO`.",
+				},
+				"subnet": {
+					Type:             schema.TypeString,
+					Required:         true,
+					ValidateDiagFunc: validation.ToDiagFunc(validation.IsCIDR),
+					Description:      "The CIDR of the subnet.",
+				},
+			},
+		},
+	}
+}
+
+// customizeNetworkTemplateDiff checks the template at plan time and computes its
+// content, when the configuration is known.
+func customizeNetworkTemplateDiff(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	s := resourceMAASNetworkTemplate().Schema
+	delete(s, "content")
+
+	if !newValuesKnown(d, "", s) {
+		return d.SetNewComputed("content")
+	}
+
+	content, err := getNetworkTemplateContent(expandNetworkTemplate(d.Get))
+	if err != nil {
+		return err
+	}
+
+	return d.SetNew("content", content)
+}
+
+func resourceNetworkTemplateCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	d.SetId(id.UniqueId())
+
+	return resourceNetworkTemplateUpdate(ctx, d, meta)
+}
+
+func resourceNetworkTemplateUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	content, err := getNetworkTemplateContent(expandNetworkTemplate(d.Get))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("content", content); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func getNetworkTemplateContent(t *networkTemplate) (string, error) {
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func expandNetworkTemplate(get func(key string) any) *networkTemplate {
+	t := &networkTemplate{}
+
+	links := func(v any) []*networkTemplateLink {
+		var links []*networkTemplateLink
+
+		for _, l := range v.([]any) {
+			lm := l.(map[string]any)
+			links = append(links, &networkTemplateLink{
+				Subnet:         lm["subnet"].(string),
+				Mode:           lm["mode"].(string),
+				DefaultGateway: lm["default_gateway"].(bool),
+			})
+		}
+
+		return links
+	}
+
+	for _, v := range get("role").([]any) {
+		m := v.(map[string]any)
+		t.Roles = append(t.Roles, &networkTemplateRole{
+			Name:        m["name"].(string),
+			NamePattern: m["name_pattern"].(string),
+			Vendor:      m["vendor"].(string),
+			Product:     m["product"].(string),
+			LinkSpeed:   m["link_speed"].(int),
+			Count:       m["count"].(int),
+			MTU:         m["mtu"].(int),
+			Links:       links(m["link"]),
+		})
+	}
+
+	for _, kind := range networkKinds[1:] {
+		for _, v := range get(kind).([]any) {
+			m := v.(map[string]any)
+			o := &networkTemplateInterface{
+				MTU:    m["mtu"].(int),
+				Params: map[string]string{},
+				Links:  links(m["link"]),
+			}
+
+			switch kind {
+			case networkKindBond:
+				o.Name = m["name"].(string)
+				o.Parents = convertToStringSlice(m["parents"])
+				t.Bonds = append(t.Bonds, o)
+			case networkKindVLAN:
+				o.Parents = []string{m["parent"].(string)}
+				o.VID = m["vid"].(int)
+				t.VLANs = append(t.VLANs, o)
+			case networkKindBridge:
+				o.Name = m["name"].(string)
+				o.Parents = []string{m["parent"].(string)}
+				t.Bridges = append(t.Bridges, o)
+			}
+
+			for _, k := range networkInterfaceParams[kind] {
+				o.Params[k] = fmt.Sprint(m[k])
+			}
+		}
+	}
+
+	return t
+}
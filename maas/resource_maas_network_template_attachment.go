@@ -0,0 +1,295 @@
+package maas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// networkTemplateApplied is the status of the machines whose network matches the template.
+const networkTemplateApplied = "applied"
+
+// errNetworkNotConfigurable is returned for the machines whose status does not
+// allow to configure their network.
+var errNetworkNotConfigurable = errors.New("its network cannot be configured")
+
+func resourceMAASNetworkTemplateAttachment() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to apply a `maas_network_template` to a set of MAAS machines. The machines are configured concurrently, and the changes of each machine are rolled back if one of them fails. The machines must be in the `New`, `Ready`, `Allocated`, `Broken` or `Failed testing` status.\n\nA machine that could not be configured is reported as a warning and in the `statuses` attribute, and it is configured again on the next apply. The interfaces of the template are removed from the machines detached, except from the ones whose network cannot be configured anymore (e.g. deployed machines), which are skipped with a warning.",
+		CreateContext: resourceNetworkTemplateAttachmentCreate,
+		ReadContext:   resourceNetworkTemplateAttachmentRead,
+		UpdateContext: resourceNetworkTemplateAttachmentUpdate,
+		DeleteContext: resourceNetworkTemplateAttachmentDelete,
+
+		Schema: map[string]*schema.Schema{
+			"machines": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The identifiers (system ID, hostname, or FQDN) of the machines.",
+			},
+			"parallelism": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				Default:          4,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IntAtLeast(1)),
+				Description:      "The maximum number of machines configured at the same time. Defaults to `4`.",
+			},
+			"statuses": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The status of each machine, by identifier: `applied` when its network matches the template, `out of sync` when it was changed outside of Terraform, or the reason why the template cannot be applied.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"template": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringIsJSON),
+				Description:      "The `content` of the `maas_network_template` resource applied.",
+			},
+		},
+		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+			// Configure the machines again until the template is applied to all of them
+			for _, status := range d.Get("statuses").(map[string]any) {
+				if status != networkTemplateApplied {
+					return d.SetNewComputed("statuses")
+				}
+			}
+
+			if d.HasChanges("machines", "template") {
+				return d.SetNewComputed("statuses")
+			}
+
+			return nil
+		},
+	}
+}
+
+func resourceNetworkTemplateAttachmentCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	d.SetId(id.UniqueId())
+
+	return reconcileNetworkTemplateAttachment(ctx, d, meta)
+}
+
+func resourceNetworkTemplateAttachmentRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	t, err := parseNetworkTemplate(d.Get("template").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	identifiers := convertToStringSlice(d.Get("machines").(*schema.Set).List())
+	inSync := make([]bool, len(identifiers))
+
+	errs := runConcurrently(d.Get("parallelism").(int), len(identifiers), func(i int) error {
+		machine, err := getMachine(client, identifiers[i])
+		if err != nil {
+			return err
+		}
+
+		current, desired, err := getNetworkTemplateLayouts(client, machine, t, nil)
+		if err != nil {
+			return err
+		}
+
+		inSync[i] = current.inSync(desired)
+
+		return nil
+	})
+
+	statuses := map[string]any{}
+
+	for i, err := range errs {
+		switch {
+		case err != nil:
+			statuses[identifiers[i]] = err.Error()
+		case inSync[i]:
+			statuses[identifiers[i]] = networkTemplateApplied
+		default:
+			statuses[identifiers[i]] = "out of sync"
+		}
+	}
+
+	if err := d.Set("statuses", statuses); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceNetworkTemplateAttachmentUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	return reconcileNetworkTemplateAttachment(ctx, d, meta)
+}
+
+func resourceNetworkTemplateAttachmentDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	t, err := parseNetworkTemplate(d.Get("template").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	identifiers := convertToStringSlice(d.Get("machines").(*schema.Set).List())
+
+	return detachNetworkTemplateFromMachines(client, identifiers, t, d.Get("parallelism").(int))
+}
+
+// reconcileNetworkTemplateAttachment applies the template to the machines, and
+// removes the interfaces of the previous template from the machines detached.
+// Failures of individual machines are reported as warnings.
+func reconcileNetworkTemplateAttachment(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	o, n := d.GetChange("template")
+
+	t, err := parseNetworkTemplate(n.(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The interfaces of the previous template are removed when they are no longer used
+	var previous *networkTemplate
+	if o.(string) != "" {
+		if previous, err = parseNetworkTemplate(o.(string)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	oldMachines, newMachines := d.GetChange("machines")
+	identifiers := convertToStringSlice(newMachines.(*schema.Set).List())
+	detached := convertToStringSlice(oldMachines.(*schema.Set).Difference(newMachines.(*schema.Set)).List())
+
+	diags := detachNetworkTemplateFromMachines(client, detached, previous, d.Get("parallelism").(int))
+
+	// Keep the previous template and machines in the state, to detach them again on the next apply
+	if diags.HasError() {
+		d.Partial(true)
+		return diags
+	}
+
+	errs := runConcurrently(d.Get("parallelism").(int), len(identifiers), func(i int) error {
+		return applyNetworkTemplate(client, identifiers[i], t, previous)
+	})
+	for i, err := range errs {
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Unable to apply the network template to machine %s", identifiers[i]),
+				Detail:   err.Error(),
+			})
+		}
+	}
+
+	return append(diags, resourceNetworkTemplateAttachmentRead(ctx, d, meta)...)
+}
+
+// getNetworkTemplateLayouts returns the current network layout of a machine and the
+// one of the template t. The current layout also holds the interfaces of the
+// previous template, when there is one.
+func getNetworkTemplateLayouts(client *client.Client, machine *entity.Machine, t *networkTemplate, previous *networkTemplate) (*machineNetworkLayout, *machineNetworkLayout, error) {
+	networkInterfaces, err := client.NetworkInterfaces.Get(machine.SystemID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desired, err := t.layout(networkInterfaces)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := desired.keys()
+	if previous != nil {
+		names = append(names, previous.names(networkInterfaces)...)
+	}
+
+	return observeMachineNetworkLayout(machine, networkInterfaces, slices.Compact(sortedStrings(names))), desired, nil
+}
+
+func applyNetworkTemplate(client *client.Client, identifier string, t *networkTemplate, previous *networkTemplate) error {
+	machine, err := getMachine(client, identifier)
+	if err != nil {
+		return err
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return fmt.Errorf("machine (%s) is %s, %w", machine.SystemID, machine.StatusName, errNetworkNotConfigurable)
+	}
+
+	current, desired, err := getNetworkTemplateLayouts(client, machine, t, previous)
+	if err != nil {
+		return err
+	}
+
+	if current.inSync(desired) {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Machine (%s) applying the network template\n", machine.SystemID)
+
+	return newMachineNetworkApplier(client, machine, current).apply(desired)
+}
+
+// detachNetworkTemplateFromMachines removes the interfaces of the template t from
+// the machines. The machines whose network cannot be configured, e.g. deployed
+// ones, are skipped with a warning.
+func detachNetworkTemplateFromMachines(client *client.Client, identifiers []string, t *networkTemplate, parallelism int) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	errs := runConcurrently(parallelism, len(identifiers), func(i int) error {
+		return detachNetworkTemplate(client, identifiers[i], t)
+	})
+	for i, err := range errs {
+		switch {
+		case errors.Is(err, errNetworkNotConfigurable):
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Unable to remove the network template from machine %s", identifiers[i]),
+				Detail:   err.Error(),
+			})
+		case err != nil:
+			diags = append(diags, diag.Errorf("machine (%s): %s", identifiers[i], err)...)
+		}
+	}
+
+	return diags
+}
+
+// detachNetworkTemplate removes the interfaces of the template t from a machine.
+// Nothing is removed if the template does not apply to the machine.
+func detachNetworkTemplate(client *client.Client, identifier string, t *networkTemplate) error {
+	machine, err := getMachine(client, identifier)
+	if err != nil {
+		// The network of a deleted machine is gone with it
+		if errors.Is(err, errMachineNotFound) || isNotFoundError(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !isMachineInPermittedState(machine) {
+		return fmt.Errorf("machine (%s) is %s, %w", machine.SystemID, machine.StatusName, errNetworkNotConfigurable)
+	}
+
+	networkInterfaces, err := client.NetworkInterfaces.Get(machine.SystemID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Machine (%s) removing the network template\n", machine.SystemID)
+
+	return newMachineNetworkApplier(client, machine, observeMachineNetworkLayout(machine, networkInterfaces, t.names(networkInterfaces))).destroy()
+}
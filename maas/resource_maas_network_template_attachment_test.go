@@ -0,0 +1,109 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASNetworkTemplateAttachment_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_NETWORK_INTERFACE_MACHINE")
+	cidr := testutils.GenerateRandomCIDR()
+	macAddressOne := testutils.RandomMAC()
+	macAddressTwo := testutils.RandomMAC()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_NETWORK_INTERFACE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASNetworkTemplateAttachment(machine, cidr, macAddressOne, macAddressTwo, "active-backup"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_network_template_attachment.test", "statuses.%", "1"),
+					resource.TestCheckResourceAttr("maas_network_template_attachment.test", fmt.Sprintf("statuses.%s", machine), "applied"),
+				),
+			},
+			// Changing the template updates the machines
+			{
+				Config: testAccMAASNetworkTemplateAttachment(machine, cidr, macAddressOne, macAddressTwo, "balance-rr"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_network_template_attachment.test", fmt.Sprintf("statuses.%s", machine), "applied"),
+				),
+			},
+		},
+	})
+}
+
+func testAccMAASNetworkTemplateAttachment(machine string, cidr string, macAddressOne string, macAddressTwo string, bondMode string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_fabric" "test" {
+  name = "tf-fabric-network-template"
+}
+
+data "maas_vlan" "test" {
+  fabric = maas_fabric.test.id
+  vlan   = 0
+}
+
+resource "maas_subnet" "test" {
+  fabric = maas_fabric.test.id
+  vlan   = data.maas_vlan.test.id
+  cidr   = %q
+}
+
+resource "maas_network_interface_physical" "one" {
+  machine     = data.maas_machine.machine.id
+  name        = "tfeth0"
+  mac_address = %q
+}
+
+resource "maas_network_interface_physical" "two" {
+  machine     = data.maas_machine.machine.id
+  name        = "tfeth1"
+  mac_address = %q
+}
+
+resource "maas_network_template" "test" {
+  name = "tf-network-template-attachment"
+
+  role {
+    name         = "uplink"
+    name_pattern = "^tfeth[01]$"
+    count        = 2
+  }
+
+  bond {
+    name      = "tfbond0"
+    parents   = ["uplink"]
+    bond_mode = %q
+  }
+
+  bridge {
+    name   = "tfbr0"
+    parent = "tfbond0"
+
+    link {
+      subnet = maas_subnet.test.cidr
+    }
+  }
+}
+
+resource "maas_network_template_attachment" "test" {
+  template = maas_network_template.test.content
+  machines = [data.maas_machine.machine.hostname]
+
+  depends_on = [maas_network_interface_physical.one, maas_network_interface_physical.two]
+}
+`, machine, cidr, macAddressOne, macAddressTwo, bondMode)
+}
@@ -0,0 +1,72 @@
+package maas_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"terraform-provider-maas/maas/testutils"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASNetworkTemplate_basic(t *testing.T) {
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, nil) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASNetworkTemplate("uplink"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("maas_network_template.test", "id"),
+					resource.TestCheckResourceAttrWith("maas_network_template.test", "content", func(value string) error {
+						for _, s := range []string{`"name":"uplink"`, `"parents":["uplink"]`, `"bond_mode":"802.3ad"`, `"vid":100`} {
+							if !strings.Contains(value, s) {
+								return fmt.Errorf("expected %s in the template content, got %s", s, value)
+							}
+						}
+
+						return nil
+					}),
+				),
+			},
+			{
+				Config:      testAccMAASNetworkTemplate("missing"),
+				ExpectError: regexp.MustCompile(`bond "bond0": "missing" is not an interface of the layout`),
+			},
+		},
+	})
+}
+
+func testAccMAASNetworkTemplate(parent string) string {
+	return fmt.Sprintf(`
+resource "maas_network_template" "test" {
+  name = "tf-network-template"
+
+  role {
+    name         = "uplink"
+    name_pattern = "^enp1s0f[01]$"
+    count        = 2
+  }
+
+  bond {
+    name      = "bond0"
+    parents   = [%q]
+    bond_mode = "802.3ad"
+  }
+
+  vlan {
+    parent = "bond0"
+    vid    = 100
+
+    link {
+      subnet = "10.100.0.0/24"
+    }
+  }
+}
+`, parent)
+}
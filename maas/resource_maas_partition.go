@@ -0,0 +1,356 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASPartition() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage a partition of a MAAS machine block device, including the physical disks discovered during commissioning.",
+		CreateContext: resourcePartitionCreate,
+		ReadContext:   resourcePartitionRead,
+		UpdateContext: resourcePartitionUpdate,
+		DeleteContext: resourcePartitionDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourcePartitionImport,
+		},
+		CustomizeDiff: customdiff.Sequence(
+			resolveStorageSelectorsDiff("block_device_selector"),
+			// The partition is recreated when its selector matches another disk
+			func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+				if d.Id() != "" && d.HasChange("selected_devices") {
+					return d.ForceNew("selected_devices")
+				}
+
+				return nil
+			},
+		),
+
+		Schema: map[string]*schema.Schema{
+			"block_device": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"block_device", "block_device_selector"},
+				Description:  "The identifier (ID, name, serial, ID path or path) of the block device to partition.",
+			},
+			"block_device_selector": storageSelectorSchema("Selects the disk to partition by its stable attributes.", false, []string{"block_device", "block_device_selector"}),
+			"block_device_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The ID of the partitioned block device.",
+			},
+			"bootable": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Whether the partition is bootable. Defaults to `false`.",
+			},
+			"fs_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The filesystem type used to format the partition (e.g. `ext4` or `xfs`). The partition is not formatted if unset.",
+			},
+			"label": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"fs_type"},
+				Description:  "The label of the filesystem.",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The identifier (system ID, hostname, or FQDN) of the machine.",
+			},
+			"mount_options": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"mount_point"},
+				Description:  "The options used to mount the filesystem.",
+			},
+			"mount_point": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"fs_type"},
+				Description:  "The mount point of the filesystem. The filesystem is not mounted if unset.",
+			},
+			"path": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The path of the partition.",
+			},
+			"selected_devices": selectedDevicesSchema(),
+			"size_gigabytes": {
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "The size of the partition (GB).",
+			},
+			"tags": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "A set of tag names assigned to the partition.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"uuid": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The partition UUID.",
+			},
+		},
+	}
+}
+
+func resourcePartitionImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.Split(d.Id(), ":")
+	if len(idParts) != 3 || idParts[0] == "" || idParts[1] == "" || idParts[2] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE:BLOCK_DEVICE:PARTITION", d.Id())
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	blockDevice, err := getBlockDevice(client, machine.SystemID, idParts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	partition, err := getBlockDevicePartition(blockDevice, idParts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	tfState := map[string]any{
+		"block_device":   blockDevice.Name,
+		"machine":        machine.SystemID,
+		"size_gigabytes": int(math.Round(float64(partition.Size) / GigaBytes)),
+		"bootable":       partition.Bootable,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return nil, err
+	}
+
+	d.SetId(fmt.Sprintf("%v", partition.ID))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourcePartitionCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_device")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	identifiers, err := references.ids(d, "block_device")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if len(identifiers) != 1 {
+		return diag.Errorf("exactly one of block_device or block_device_selector must be set")
+	}
+
+	blockDevice, err := getBlockDevice(client, machine.SystemID, identifiers[0])
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	partition, err := client.BlockDevicePartitions.Create(machine.SystemID, blockDevice.ID, &entity.BlockDevicePartitionParams{
+		Size:     int64(d.Get("size_gigabytes").(int)) * GigaBytes,
+		Bootable: d.Get("bootable").(bool),
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(fmt.Sprintf("%v", partition.ID))
+
+	if err := d.Set("block_device_id", blockDevice.ID); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourcePartitionUpdate(ctx, d, meta)
+}
+
+func resourcePartitionRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	blockDeviceID := d.Get("block_device_id").(int)
+	if blockDeviceID == 0 {
+		// Imported partition
+		blockDevice, err := getBlockDevice(client, machine.SystemID, d.Get("block_device").(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		blockDeviceID = blockDevice.ID
+	}
+
+	partition, err := client.BlockDevicePartition.Get(machine.SystemID, blockDeviceID, id)
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	// The selector is resolved to the disk the partition is on, the partition is
+	// recreated if the selector matches another disk
+	selected := map[string]any{}
+	if len(d.Get("block_device_selector").([]any)) > 0 {
+		selected["block_device_selector.0"] = fmt.Sprintf("%v", blockDeviceID)
+	}
+
+	tfState := map[string]any{
+		"block_device_id":  blockDeviceID,
+		"bootable":         partition.Bootable,
+		"fs_type":          partition.FileSystem.FSType,
+		"label":            partition.FileSystem.Label,
+		"mount_options":    partition.FileSystem.MountOptions,
+		"mount_point":      partition.FileSystem.MountPoint,
+		"path":             partition.Path,
+		"selected_devices": selected,
+		"size_gigabytes":   int(math.Round(float64(partition.Size) / GigaBytes)),
+		"tags":             partition.Tags,
+		"uuid":             partition.UUID,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourcePartitionUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	blockDeviceID := d.Get("block_device_id").(int)
+
+	partition, err := client.BlockDevicePartition.Get(machine.SystemID, blockDeviceID, id)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := setPartitionTags(client, d, machine.SystemID, blockDeviceID, partition); err != nil {
+		return diag.FromErr(err)
+	}
+
+	current := storageFilesystem{
+		FSType:       partition.FileSystem.FSType,
+		Label:        partition.FileSystem.Label,
+		MountPoint:   partition.FileSystem.MountPoint,
+		MountOptions: partition.FileSystem.MountOptions,
+	}
+	target := storageFilesystem{
+		FSType:       d.Get("fs_type").(string),
+		Label:        d.Get("label").(string),
+		MountPoint:   d.Get("mount_point").(string),
+		MountOptions: d.Get("mount_options").(string),
+	}
+
+	if err := transitionStorageFilesystem(partitionFilesystemOps(client, machine.SystemID, blockDeviceID, id), &current, target); err != nil {
+		return diag.FromErr(fmt.Errorf("partition (%d): %w", id, err))
+	}
+
+	return resourcePartitionRead(ctx, d, meta)
+}
+
+func resourcePartitionDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	id, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := client.BlockDevicePartition.Delete(machine.SystemID, d.Get("block_device_id").(int), id); err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	return nil
+}
+
+// getBlockDevicePartition returns the partition of the block device with the given
+// ID, path or name (e.g. `sda-part1`).
+func getBlockDevicePartition(blockDevice *entity.BlockDevice, identifier string) (*entity.BlockDevicePartition, error) {
+	for _, p := range blockDevice.Partitions {
+		if fmt.Sprintf("%v", p.ID) == identifier || p.Path == identifier || path.Base(p.Path) == identifier {
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("partition (%s) was not found on block device (%s)", identifier, blockDevice.Name)
+}
+
+func setPartitionTags(client *client.Client, d *schema.ResourceData, systemID string, blockDeviceID int, partition *entity.BlockDevicePartition) error {
+	tags := convertToStringSlice(d.Get("tags").(*schema.Set).List())
+
+	for _, t := range partition.Tags {
+		if !slices.Contains(tags, t) {
+			if _, err := client.BlockDevicePartition.RemoveTag(systemID, blockDeviceID, partition.ID, t); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, t := range tags {
+		if !slices.Contains(partition.Tags, t) {
+			if _, err := client.BlockDevicePartition.AddTag(systemID, blockDeviceID, partition.ID, t); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
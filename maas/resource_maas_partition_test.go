@@ -0,0 +1,133 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASPartition_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	var partitionID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASPartitionDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASPartition(machine, "/srv", "tag1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_partition.test", "size_gigabytes", "10"),
+					resource.TestCheckResourceAttr("maas_partition.test", "fs_type", "ext4"),
+					resource.TestCheckResourceAttr("maas_partition.test", "label", "data"),
+					resource.TestCheckResourceAttr("maas_partition.test", "mount_point", "/srv"),
+					resource.TestCheckResourceAttr("maas_partition.test", "tags.#", "1"),
+					resource.TestCheckResourceAttrPair("maas_partition.test", "block_device_id", "maas_block_device.test", "id"),
+					resource.TestCheckResourceAttrWith("maas_partition.test", "id", func(value string) error {
+						partitionID = value
+						return nil
+					}),
+				),
+			},
+			// Changing the mount point and tags keeps the partition
+			{
+				Config: testAccMAASPartition(machine, "/srv/data", "tag2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_partition.test", "mount_point", "/srv/data"),
+					resource.TestCheckTypeSetElemAttr("maas_partition.test", "tags.*", "tag2"),
+					resource.TestCheckResourceAttrWith("maas_partition.test", "id", func(value string) error {
+						if value != partitionID {
+							return fmt.Errorf("expected the partition (%s) to be kept, got %s", partitionID, value)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// Test import
+			{
+				ResourceName:      "maas_partition.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					rs, ok := s.RootModule().Resources["maas_partition.test"]
+					if !ok {
+						return "", fmt.Errorf("resource not found: %s", "maas_partition.test")
+					}
+
+					return fmt.Sprintf("%s:%s:%s", rs.Primary.Attributes["machine"], rs.Primary.Attributes["block_device"], rs.Primary.ID), nil
+				},
+			},
+		},
+	})
+}
+
+func testAccMAASPartition(machine string, mountPoint string, tag string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_block_device" "test" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfpart"
+  size_gigabytes = 20
+  block_size     = 512
+  id_path        = "/dev/tfpart"
+}
+
+resource "maas_partition" "test" {
+  machine        = data.maas_machine.machine.id
+  block_device   = maas_block_device.test.name
+  size_gigabytes = 10
+  fs_type        = "ext4"
+  label          = "data"
+  mount_point    = %q
+  tags           = [%q]
+}
+`, machine, mountPoint, tag)
+}
+
+func testAccCheckMAASPartitionDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_partition" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		blockDeviceID, err := strconv.Atoi(rs.Primary.Attributes["block_device_id"])
+		if err != nil {
+			return err
+		}
+
+		response, err := conn.BlockDevicePartition.Get(rs.Primary.Attributes["machine"], blockDeviceID, id)
+		if err == nil {
+			if response != nil && response.ID == id {
+				return fmt.Errorf("Partition %s (%d) still exists.", response.Path, id)
+			}
+		}
+
+		// 404 means destroyed, anything else is an error
+		if !strings.Contains(err.Error(), "404 Not Found") {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/canonical/gomaasclient/client"
 	"github.com/canonical/gomaasclient/entity"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
@@ -23,6 +24,10 @@ func resourceMAASRAID() *schema.Resource {
 		ReadContext:   resourceRAIDRead,
 		UpdateContext: resourceRAIDUpdate,
 		DeleteContext: resourceRAIDDelete,
+		CustomizeDiff: customdiff.Sequence(
+			resolveStorageSelectorsDiff("block_device_selector", "partition_selector", "spare_device_selector", "spare_partition_selector"),
+			validateRAIDCapacity,
+		),
 
 		Schema: map[string]*schema.Schema{
 			"block_devices": {
@@ -30,8 +35,9 @@ func resourceMAASRAID() *schema.Resource {
 				Optional:     true,
 				Elem:         &schema.Schema{Type: schema.TypeString},
 				Description:  "The list of block devices to be included in the RAID.\n*Note*: The boot disk cannot participate in the RAID as a block device, a partition on top of it should be supplied instead.\n*Note*: Block devices with partitions are not valid targets to construct a RAID, supply their partitions instead.",
-				AtLeastOneOf: []string{"block_devices", "partitions"},
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
 			},
+			"block_device_selector": storageSelectorSchema("Selects a block device to be included in the RAID by its stable attributes, rather than by ID.", false, nil),
 			"fs_type": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -40,7 +46,8 @@ func resourceMAASRAID() *schema.Resource {
 			"level": {
 				Type:        schema.TypeString,
 				Required:    true,
-				Description: "The RAID Level. Valid levels are: `\"0\", \"1\", \"5\", \"6\", \"10\"`",
+				ForceNew:    true,
+				Description: "The RAID Level. Valid levels are: `\"0\", \"1\", \"5\", \"6\", \"10\"`. MAAS cannot change the level of a RAID, changing it recreates the RAID.",
 				ValidateFunc: validation.StringInSlice(
 					[]string{"0", "1", "5", "6", "10"},
 					false,
@@ -72,8 +79,10 @@ func resourceMAASRAID() *schema.Resource {
 				Optional:     true,
 				Elem:         &schema.Schema{Type: schema.TypeString},
 				Description:  "The list of partitions to be included in the RAID.",
-				AtLeastOneOf: []string{"block_devices", "partitions"},
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
 			},
+			"partition_selector": storageSelectorSchema("Selects a partition to be included in the RAID by the stable attributes of its disk and its number, rather than by ID.", true, nil),
+			"selected_devices":   selectedDevicesSchema(),
 			"size_gigabytes": {
 				Type:        schema.TypeInt,
 				Computed:    true,
@@ -83,14 +92,16 @@ func resourceMAASRAID() *schema.Resource {
 				Type:        schema.TypeSet,
 				Optional:    true,
 				Elem:        &schema.Schema{Type: schema.TypeString},
-				Description: "The list of spare block devices for the RAID.\n*Note*: The boot disk cannot participate in the RAID as a block device, a partition on top of it should be supplied instead.\n*Note*: Block devices with partitions are not valid targets to construct a RAID, supply their partitions instead.",
+				Description: "The list of spare block devices for the RAID. Spares can be added and removed in place.\n*Note*: The boot disk cannot participate in the RAID as a block device, a partition on top of it should be supplied instead.\n*Note*: Block devices with partitions are not valid targets to construct a RAID, supply their partitions instead.",
 			},
 			"spare_partitions": {
 				Type:        schema.TypeSet,
 				Optional:    true,
 				Elem:        &schema.Schema{Type: schema.TypeString},
-				Description: "The list of spare partitions for the RAID.",
+				Description: "The list of spare partitions for the RAID. Spares can be added and removed in place.",
 			},
+			"spare_device_selector":    storageSelectorSchema("Selects a spare block device for the RAID by its stable attributes, rather than by ID.", false, nil),
+			"spare_partition_selector": storageSelectorSchema("Selects a spare partition for the RAID by the stable attributes of its disk and its number, rather than by ID.", true, nil),
 		},
 	}
 }
@@ -111,8 +122,13 @@ func resourceRAIDCreate(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
+	devices, err := getRAIDDevices(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	// Check the RAID configuration is valid
-	if err = verifyRAIDConfig(client, machine, d); err != nil {
+	if err = verifyRAIDConfig(client, machine, d.Get("level").(string), devices); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -120,10 +136,10 @@ func resourceRAIDCreate(ctx context.Context, d *schema.ResourceData, meta interf
 	createRAIDParams := &entity.RAIDCreateParams{
 		Name:            d.Get("name").(string),
 		Level:           fmt.Sprintf("raid-%v", d.Get("level").(string)),
-		BlockDevices:    convertToStringSlice(d.Get("block_devices").(*schema.Set).List()),
-		Partitions:      convertToStringSlice(d.Get("partitions").(*schema.Set).List()),
-		SpareDevices:    convertToStringSlice(d.Get("spare_devices").(*schema.Set).List()),
-		SparePartitions: convertToStringSlice(d.Get("spare_partitions").(*schema.Set).List()),
+		BlockDevices:    devices["block_devices"],
+		Partitions:      devices["partitions"],
+		SpareDevices:    devices["spare_devices"],
+		SparePartitions: devices["spare_partitions"],
 	}
 
 	raid, err := client.RAIDs.Create(machine.SystemID, createRAIDParams)
@@ -170,6 +186,18 @@ func resourceRAIDRead(ctx context.Context, d *schema.ResourceData, meta interfac
 		return diag.FromErr(err)
 	}
 
+	// the devices selected by selectors are not reported by ID
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions", "spare_devices", "spare_partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	selected := map[string]any{}
+	devices = references.attribute(d, "block_devices", devices, selected)
+	partitions = references.attribute(d, "partitions", partitions, selected)
+	spareDevices = references.attribute(d, "spare_devices", spareDevices, selected)
+	sparePartitions = references.attribute(d, "spare_partitions", sparePartitions, selected)
+
 	// Update the Terraform state
 	tfstate := map[string]interface{}{
 		"block_devices":    devices,
@@ -180,6 +208,7 @@ func resourceRAIDRead(ctx context.Context, d *schema.ResourceData, meta interfac
 		"mount_point":      raid.VirtualDevice.Filesystem.MountPoint,
 		"name":             raid.Name,
 		"partitions":       partitions,
+		"selected_devices": selected,
 		"size_gigabytes":   int(math.Round(float64(raid.Size) / GigaBytes)),
 		"spare_devices":    spareDevices,
 		"spare_partitions": sparePartitions,
@@ -205,17 +234,27 @@ func resourceRAIDUpdate(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.FromErr(err)
 	}
 
+	devices, err := getRAIDDevices(client, machine.SystemID, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	// Check the RAID configuration is valid
-	if err = verifyRAIDConfig(client, machine, d); err != nil {
+	if err = verifyRAIDConfig(client, machine, d.Get("level").(string), devices); err != nil {
 		return diag.FromErr(err)
 	}
 
+	previousDevices := map[string][]string{}
+	for attr := range devices {
+		previousDevices[attr] = previousStorageIDs(d, attr)
+	}
+
 	// devices that are moving from active to spare or vice-versa need to be removed and then re-added in a separate call
 	// we use the following function calls to determine the disks that have been moved vs. newly added/removed
-	newBlockDevice, movedBlockDevice, removedBlockDevice := getMovedDevices(d, "block_devices", "spare_devices")
-	newSpareDevice, movedSpareDevice, removedSpareDevice := getMovedDevices(d, "spare_devices", "block_devices")
-	newPartition, movedPartition, removedPartition := getMovedDevices(d, "partitions", "spare_partitions")
-	newSparePartition, movedSparePartition, removedSparePartition := getMovedDevices(d, "spare_partitions", "partitions")
+	newBlockDevice, movedBlockDevice, removedBlockDevice := getMovedDevices(previousDevices, devices, "block_devices", "spare_devices")
+	newSpareDevice, movedSpareDevice, removedSpareDevice := getMovedDevices(previousDevices, devices, "spare_devices", "block_devices")
+	newPartition, movedPartition, removedPartition := getMovedDevices(previousDevices, devices, "partitions", "spare_partitions")
+	newSparePartition, movedSparePartition, removedSparePartition := getMovedDevices(previousDevices, devices, "spare_partitions", "partitions")
 
 	// We need to be very careful about order of operations, so that we maintain the minimum number of active disks required for the RAID level
 	// We also need to ensure a disk is never included in both an add and a remove operation, as that will cause collisions in MAAS
@@ -286,9 +325,20 @@ func resourceRAIDUpdate(ctx context.Context, d *schema.ResourceData, meta interf
 		}
 	}
 
-	// We can finally perform mounting and formatting operations on the virtual block device
-	if _, err = formatAndMountVirtualBlockDevice(client, &raid.VirtualDevice, d); err != nil {
-		return diag.FromErr(err)
+	// We can finally unmount, unformat, format and mount again what changed on the virtual block device
+	current := storageFilesystem{
+		FSType:       raid.VirtualDevice.Filesystem.FSType,
+		MountPoint:   raid.VirtualDevice.Filesystem.MountPoint,
+		MountOptions: raid.VirtualDevice.Filesystem.MountOptions,
+	}
+	target := storageFilesystem{
+		FSType:       d.Get("fs_type").(string),
+		MountPoint:   d.Get("mount_point").(string),
+		MountOptions: d.Get("mount_options").(string),
+	}
+
+	if err := transitionStorageFilesystem(blockDeviceFilesystemOps(client, machine.SystemID, raid.VirtualDevice.ID), &current, target); err != nil {
+		return diag.FromErr(fmt.Errorf("RAID (%s): %w", raid.Name, err))
 	}
 
 	d.SetId(fmt.Sprintf("%v", raid.ID))
@@ -317,14 +367,14 @@ func resourceRAIDDelete(ctx context.Context, d *schema.ResourceData, meta interf
 	return nil
 }
 
-func verifyRAIDConfig(client *client.Client, machine *entity.Machine, d *schema.ResourceData) error {
+func verifyRAIDConfig(client *client.Client, machine *entity.Machine, level string, devices map[string][]string) error {
 	// Ensure the provided config has the correct disks for the RAID level, that each block device is partition-less,
 	// that the boot-disk is not provided while block devices are (see: VolumeGroups for similar behavior), and that
 	// provided disks are not included as both active and spare simultaneously.
-	blockDevices := convertToStringSlice(d.Get("block_devices").(*schema.Set).List())
-	spareDevices := convertToStringSlice(d.Get("spare_devices").(*schema.Set).List())
-	partitions := convertToStringSlice(d.Get("partitions").(*schema.Set).List())
-	sparePartitions := convertToStringSlice(d.Get("spare_partitions").(*schema.Set).List())
+	blockDevices := devices["block_devices"]
+	spareDevices := devices["spare_devices"]
+	partitions := devices["partitions"]
+	sparePartitions := devices["spare_partitions"]
 
 	// If any of the supplied block devices are the boot disk, MAAS will create partitions on all of the block devices
 	// We ensure, similar to VolumeGroup, that the boot disk is not supplied as a block device, spare or active
@@ -349,7 +399,7 @@ func verifyRAIDConfig(client *client.Client, machine *entity.Machine, d *schema.
 
 	// verify the RAID Level is valid for the number of active and spare disks
 	if err := verifyRAIDDevicesLevel(
-		d.Get("level").(string),
+		level,
 		len(blockDevices)+len(partitions),
 		len(spareDevices)+len(sparePartitions),
 	); err != nil {
@@ -492,7 +542,7 @@ func splitDeviceTypes(devices []entity.RAIDDevice) ([]string, []string, error) {
 	return blockDevices, partitions, nil
 }
 
-func getMovedDevices(d *schema.ResourceData, field string, counterpartField string) ([]string, []string, []string) {
+func getMovedDevices(oldDevices map[string][]string, newDevices map[string][]string, field string, counterpartField string) ([]string, []string, []string) {
 	// Determine the list of disks, for a supplied field, that have been newly added, or newly removed. Additionally, determine
 	// if any of the new disks are actually new, or have been moved from the counterpart field.
 	// i.e.: the set of new active disks, removed active disks, or spare disks that have been moved to active.
@@ -502,35 +552,50 @@ func getMovedDevices(d *schema.ResourceData, field string, counterpartField stri
 
 	var removeDevices []string
 
-	// if our field has no changes, evidently no devices are created, moved, or removed.
-	if d.HasChange(field) {
-		// determine the changes to our field
-		oldDevices, newDevices := d.GetChange(field)
-		oldList := convertToStringSlice(oldDevices.(*schema.Set).List())
-		newList := convertToStringSlice(newDevices.(*schema.Set).List())
-
-		// we also need a list of devices removed from the counterpart field to determine if
-		// they were moved here
-		counterpartOld, _ := d.GetChange(counterpartField)
-		counterpartOldList := convertToStringSlice(counterpartOld.(*schema.Set).List())
-
-		// if a new device exists in the old counterpart, it must have been moved
-		// else, if it doesn't exist in the old list, it is newly created
-		for _, device := range newList {
-			if slices.Contains(counterpartOldList, device) {
-				movedDevices = append(movedDevices, device)
-			} else if !slices.Contains(oldList, device) {
-				createdDevices = append(createdDevices, device)
-			}
+	oldList := oldDevices[field]
+	newList := newDevices[field]
+
+	// we also need a list of devices removed from the counterpart field to determine if
+	// they were moved here
+	counterpartOldList := oldDevices[counterpartField]
+
+	// if a new device exists in the old counterpart, it must have been moved
+	// else, if it doesn't exist in the old list, it is newly created
+	for _, device := range newList {
+		if slices.Contains(counterpartOldList, device) {
+			movedDevices = append(movedDevices, device)
+		} else if !slices.Contains(oldList, device) {
+			createdDevices = append(createdDevices, device)
 		}
+	}
 
-		// if an old device doesnt exist in the new list, it must have been removed
-		for _, device := range oldList {
-			if !slices.Contains(newList, device) {
-				removeDevices = append(removeDevices, device)
-			}
+	// if an old device doesnt exist in the new list, it must have been removed
+	for _, device := range oldList {
+		if !slices.Contains(newList, device) {
+			removeDevices = append(removeDevices, device)
 		}
 	}
 
 	return createdDevices, movedDevices, removeDevices
 }
+
+// getRAIDDevices returns the active and spare block devices and partitions of the RAID,
+// by ID or selected by the selectors, keyed by attribute.
+func getRAIDDevices(client *client.Client, systemID string, d *schema.ResourceData) (map[string][]string, error) {
+	attrs := []string{"block_devices", "partitions", "spare_devices", "spare_partitions"}
+
+	references, err := getStorageReferences(client, systemID, d, attrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string][]string{}
+
+	for _, attr := range attrs {
+		if devices[attr], err = references.ids(d, attr); err != nil {
+			return nil, err
+		}
+	}
+
+	return devices, nil
+}
@@ -263,6 +263,67 @@ func TestAccResourceMAASRAID_differentLevels(t *testing.T) {
 	}
 }
 
+func TestAccResourceMAASRAID_capacity(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+	blockDeviceNames := []string{
+		acctest.RandomWithPrefix("tf-raid-bd"),
+		acctest.RandomWithPrefix("tf-raid-bd"),
+		acctest.RandomWithPrefix("tf-raid-bd"),
+		acctest.RandomWithPrefix("tf-raid-bd"),
+	}
+	smallBlockDeviceName := acctest.RandomWithPrefix("tf-raid-bd")
+
+	baseConfig := testAccRAIDMachine(machine) +
+		testAccRAIDBlockDevice(acctest.RandomWithPrefix("boot"), 2, true) +
+		testAccRAIDBlockDevice(smallBlockDeviceName, 1, false)
+	for _, name := range blockDeviceNames {
+		baseConfig += testAccRAIDBlockDevice(name, 2, false)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheclMAASRAIDDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: baseConfig + testAccRAIDConfig("test RAID capacity", "5", "ext4", "/var/raidcapacity",
+					generateRAIDBlockDevices(blockDeviceNames),
+					[]string{},
+					[]string{},
+					[]string{},
+				),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckRAIDExists("maas_raid.test"),
+					resource.TestCheckResourceAttr("maas_raid.test", "block_devices.#", "4"),
+				),
+			},
+			// The data of the RAID do not fit on the remaining members
+			{
+				Config: baseConfig + testAccRAIDConfig("test RAID capacity", "5", "ext4", "/var/raidcapacity",
+					generateRAIDBlockDevices(blockDeviceNames[:3]),
+					[]string{},
+					[]string{},
+					[]string{},
+				),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`block_devices: RAID 5 of 3 devices would hold 4 GB but the RAID has .* is missing`),
+			},
+			// A spare smaller than the members cannot replace them
+			{
+				Config: baseConfig + testAccRAIDConfig("test RAID capacity", "5", "ext4", "/var/raidcapacity",
+					generateRAIDBlockDevices(blockDeviceNames),
+					[]string{},
+					generateRAIDBlockDevices([]string{smallBlockDeviceName}),
+					[]string{},
+				),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`spare_devices: spare .* \(1 GB\) cannot replace a RAID member, 1 GB is missing`),
+			},
+		},
+	})
+}
+
 func TestVerifyRAIDDevicesLevel(t *testing.T) {
 	// define the test cases
 	testMatrix := []struct {
@@ -0,0 +1,196 @@
+package maas
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceMAASSpecialFilesystem() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage MAAS special filesystems (e.g. `tmpfs` or `ramfs`), mounted on a machine without a backing storage device.",
+		CreateContext: resourceSpecialFilesystemCreate,
+		ReadContext:   resourceSpecialFilesystemRead,
+		UpdateContext: resourceSpecialFilesystemUpdate,
+		DeleteContext: resourceSpecialFilesystemDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSpecialFilesystemImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"fs_type": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice([]string{"tmpfs", "ramfs"}, false)),
+				Description:      "The filesystem type. Valid options are: `tmpfs` and `ramfs`.",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The identifier (system ID, hostname, or FQDN) of the machine.",
+			},
+			"mount_options": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Comma separated options used to mount the filesystem (e.g. `size=1G`). Changing them remounts the filesystem.",
+			},
+			"mount_point": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The absolute path of the mount point.",
+			},
+			"uuid": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The filesystem UUID.",
+			},
+		},
+	}
+}
+
+func resourceSpecialFilesystemImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.SplitN(d.Id(), ":", 2)
+	if len(idParts) != 2 || idParts[0] == "" || idParts[1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE:MOUNT_POINT", d.Id())
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := getSpecialFilesystem(machine, idParts[1]); err != nil {
+		return nil, err
+	}
+
+	if err := d.Set("machine", machine.SystemID); err != nil {
+		return nil, err
+	}
+
+	d.SetId(idParts[1])
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceSpecialFilesystemCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	mountPoint := d.Get("mount_point").(string)
+	if err := mountSpecialFilesystem(client, machine.SystemID, d.Get("fs_type").(string), mountPoint, d.Get("mount_options").(string)); err != nil {
+		return diag.Errorf("Could not mount special filesystem: %v", err)
+	}
+
+	d.SetId(mountPoint)
+
+	return resourceSpecialFilesystemRead(ctx, d, meta)
+}
+
+func resourceSpecialFilesystemRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	filesystem, err := getSpecialFilesystem(machine, d.Id())
+	if err != nil {
+		// The filesystem was unmounted outside of Terraform
+		d.SetId("")
+		return nil
+	}
+
+	tfState := map[string]any{
+		"fs_type":       filesystem.FSType,
+		"machine":       machine.SystemID,
+		"mount_options": filesystem.MountOptions,
+		"mount_point":   filesystem.MountPoint,
+		"uuid":          filesystem.UUID,
+	}
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceSpecialFilesystemUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// MAAS cannot change the options of a mounted special filesystem
+	if err := unmountSpecialFilesystem(client, machine.SystemID, d.Id()); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := mountSpecialFilesystem(client, machine.SystemID, d.Get("fs_type").(string), d.Id(), d.Get("mount_options").(string)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceSpecialFilesystemRead(ctx, d, meta)
+}
+
+func resourceSpecialFilesystemDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := unmountSpecialFilesystem(client, machine.SystemID, d.Id()); err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	return nil
+}
+
+func getSpecialFilesystem(machine *entity.Machine, mountPoint string) (*entity.MachineSpecialFilesystem, error) {
+	for _, filesystem := range machine.SpecialFilesystems {
+		if filesystem.MountPoint == mountPoint {
+			return &filesystem, nil
+		}
+	}
+
+	return nil, fmt.Errorf("special filesystem (%s) was not found on machine (%s)", mountPoint, machine.SystemID)
+}
+
+func mountSpecialFilesystem(client *client.Client, systemID string, fsType string, mountPoint string, mountOptions string) error {
+	params := url.Values{}
+	params.Set("fstype", fsType)
+	params.Set("mount_point", mountPoint)
+
+	if mountOptions != "" {
+		params.Set("mount_options", mountOptions)
+	}
+
+	return machineOperation(client, systemID, "mount_special", params, nil)
+}
+
+func unmountSpecialFilesystem(client *client.Client, systemID string, mountPoint string) error {
+	params := url.Values{}
+	params.Set("mount_point", mountPoint)
+
+	return machineOperation(client, systemID, "unmount_special", params, nil)
+}
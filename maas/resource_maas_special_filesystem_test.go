@@ -0,0 +1,92 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"terraform-provider-maas/maas"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASSpecialFilesystem_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_BLOCK_DEVICE_MACHINE")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_BLOCK_DEVICE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: testAccCheckMAASSpecialFilesystemDestroy,
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMAASSpecialFilesystem(machine, "size=1G"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_special_filesystem.test", "fs_type", "tmpfs"),
+					resource.TestCheckResourceAttr("maas_special_filesystem.test", "mount_point", "/scratch"),
+					resource.TestCheckResourceAttr("maas_special_filesystem.test", "mount_options", "size=1G"),
+				),
+			},
+			// Changing the options remounts the filesystem
+			{
+				Config: testAccMAASSpecialFilesystem(machine, "size=2G"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_special_filesystem.test", "mount_options", "size=2G"),
+				),
+			},
+			// Test import
+			{
+				ResourceName:      "maas_special_filesystem.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateIdFunc: func(s *terraform.State) (string, error) {
+					rs, ok := s.RootModule().Resources["maas_special_filesystem.test"]
+					if !ok {
+						return "", fmt.Errorf("resource not found: %s", "maas_special_filesystem.test")
+					}
+
+					return fmt.Sprintf("%s:%s", rs.Primary.Attributes["machine"], rs.Primary.ID), nil
+				},
+			},
+		},
+	})
+}
+
+func testAccMAASSpecialFilesystem(machine string, mountOptions string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_special_filesystem" "test" {
+  machine       = data.maas_machine.machine.id
+  fs_type       = "tmpfs"
+  mount_point   = "/scratch"
+  mount_options = %q
+}
+`, machine, mountOptions)
+}
+
+func testAccCheckMAASSpecialFilesystemDestroy(s *terraform.State) error {
+	conn := testutils.TestAccProvider.Meta().(*maas.ClientConfig).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "maas_special_filesystem" {
+			continue
+		}
+
+		machine, err := conn.Machine.Get(rs.Primary.Attributes["machine"])
+		if err != nil {
+			return err
+		}
+
+		for _, filesystem := range machine.SpecialFilesystems {
+			if filesystem.MountPoint == rs.Primary.ID {
+				return fmt.Errorf("MAAS special filesystem (%s) still exists", rs.Primary.ID)
+			}
+		}
+	}
+
+	return nil
+}
@@ -106,6 +106,14 @@ func resourceMAASVMHostMachine() *schema.Resource {
 					},
 				},
 			},
+			"owner_data": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Key/value owner data set on the VM host machine once it is allocated, e.g. by a `maas_instance`. Keys removed from this map are removed from the machine.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"pinned_cores": {
 				Type:        schema.TypeInt,
 				Optional:    true,
@@ -206,6 +214,11 @@ func resourceVMHostMachineRead(ctx context.Context, d *schema.ResourceData, meta
 		"zone":     machine.Zone.Name,
 		"pool":     machine.Pool.Name,
 	}
+
+	// The owner data of an unallocated machine is pending, keep the configured one
+	if machine.Owner != "" {
+		tfState["owner_data"] = getMachineOwnerData(machine)
+	}
 	if err := setTerraformState(d, tfState); err != nil {
 		return diag.FromErr(err)
 	}
@@ -217,10 +230,20 @@ func resourceVMHostMachineUpdate(ctx context.Context, d *schema.ResourceData, me
 	client := meta.(*ClientConfig).Client
 
 	// Update VM host machine
-	if _, err := client.Machine.Update(d.Id(), getVMHostMachineUpdateParams(d), map[string]any{}); err != nil {
+	machine, err := client.Machine.Update(d.Id(), getVMHostMachineUpdateParams(d), map[string]any{})
+	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	// MAAS only keeps the owner data of allocated machines, so the owner data of
+	// a composed machine is applied once it is allocated, e.g. by a maas_instance
+	if machine.Owner != "" && d.HasChange("owner_data") {
+		o, n := d.GetChange("owner_data")
+		if err := setMachineOwnerData(client, d.Id(), o.(map[string]any), n.(map[string]any)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	return resourceVMHostMachineRead(ctx, d, meta)
 }
 
@@ -0,0 +1,395 @@
+package maas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// vmfsDatastore is a VMFS datastore of a machine, as returned by MAAS.
+type vmfsDatastore struct {
+	Devices    []vmfsDatastoreDevice `json:"devices"`
+	Filesystem struct {
+		FSType     string `json:"fstype"`
+		MountPoint string `json:"mount_point"`
+	} `json:"filesystem"`
+	Name     string `json:"name"`
+	SystemID string `json:"system_id"`
+	UUID     string `json:"uuid"`
+	ID       int    `json:"id"`
+	Size     int64  `json:"size"`
+}
+
+type vmfsDatastoreDevice struct {
+	Name     string `json:"name"`
+	ID       int    `json:"id"`
+	DeviceID int    `json:"device_id"`
+}
+
+func resourceMAASVMFSDatastore() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Provides a resource to manage MAAS VMFS datastores of VMware ESXi machines, and construct them from block devices and partitions.",
+		CreateContext: resourceMAASVMFSDatastoreCreate,
+		ReadContext:   resourceMAASVMFSDatastoreRead,
+		UpdateContext: resourceMAASVMFSDatastoreUpdate,
+		DeleteContext: resourceMAASVMFSDatastoreDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceMAASVMFSDatastoreImport,
+		},
+		CustomizeDiff: customdiff.Sequence(
+			resolveStorageSelectorsDiff("block_device_selector", "partition_selector"),
+			func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+				// MAAS can only add block devices to a datastore
+				if d.Id() == "" || !d.NewValueKnown("selected_devices") {
+					return nil
+				}
+
+				oldBlockDevices, newBlockDevices := d.GetChange("block_devices")
+				oldSelected, newSelected := d.GetChange("selected_devices")
+				newIDs := mergeStorageIDs(newBlockDevices, newSelected.(map[string]any), "block_devices")
+
+				for _, id := range mergeStorageIDs(oldBlockDevices, oldSelected.(map[string]any), "block_devices") {
+					if !slices.Contains(newIDs, id) {
+						if d.HasChange("block_devices") {
+							return d.ForceNew("block_devices")
+						}
+
+						return d.ForceNew("selected_devices")
+					}
+				}
+
+				return nil
+			},
+		),
+
+		Schema: map[string]*schema.Schema{
+			"block_devices": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Description:  "The list of block device ids to be included in this datastore. MAAS creates a partition spanning each block device. Block devices can be added in place, removing one recreates the datastore.",
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
+			},
+			"block_device_selector": storageSelectorSchema("Selects a block device to be included in this datastore by its stable attributes, rather than by ID.", false, nil),
+			"fs_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The filesystem type of the datastore (e.g. `vmfs6`).",
+			},
+			"machine": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The machine identifier (system ID, hostname, or FQDN) that owns the datastore.",
+			},
+			"mount_point": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The mount point of the datastore.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name for this datastore.",
+			},
+			"partitions": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Description:  "The list of partition ids to be included in this datastore. Partitions can be added and removed in place.",
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
+			},
+			"partition_selector": storageSelectorSchema("Selects a partition to be included in this datastore by the stable attributes of its disk and its number, rather than by ID.", true, nil),
+			"selected_devices":   selectedDevicesSchema(),
+			"size_gigabytes": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The datastore size (GB).",
+			},
+			"uuid": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The datastore UUID. This argument is computed if it's not given.",
+			},
+		},
+	}
+}
+
+func resourceMAASVMFSDatastoreImport(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	idParts := strings.Split(d.Id(), "/")
+
+	if len(idParts) != 2 || idParts[0] == "" || idParts[1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%q), expected MACHINE_ID/VMFS_DATASTORE_ID", d.Id())
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, idParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// we have a dependency on the specific machine in the read function
+	if err := d.Set("machine", machine.SystemID); err != nil {
+		return nil, err
+	}
+
+	datastore, err := getVMFSDatastore(client, machine.SystemID, idParts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(fmt.Sprintf("%v", datastore.ID))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceMAASVMFSDatastoreCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params := url.Values{}
+	params.Set("name", d.Get("name").(string))
+
+	if uuid, ok := d.GetOk("uuid"); ok {
+		params.Set("uuid", uuid.(string))
+	}
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	for _, attr := range []string{"block_devices", "partitions"} {
+		ids, err := references.ids(d, attr)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		for _, id := range ids {
+			params.Add(attr, id)
+		}
+	}
+
+	apiClient, err := nodeObject(client, machine.SystemID, "vmfs-datastores")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	datastore := new(vmfsDatastore)
+	if err := apiClient.Post("", params, func(data []byte) error {
+		return json.Unmarshal(data, datastore)
+	}); err != nil {
+		return diag.Errorf("Could not create VMFS datastore: %v", err)
+	}
+
+	d.SetId(fmt.Sprintf("%v", datastore.ID))
+
+	return resourceMAASVMFSDatastoreRead(ctx, d, meta)
+}
+
+func resourceMAASVMFSDatastoreRead(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	datastore, err := getVMFSDatastoreByID(client, machine.SystemID, d.Id())
+	if err != nil {
+		return unsetIfNotFoundError(d, err)
+	}
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// MAAS creates a partition on each block device of the datastore, report the
+	// block devices rather than their partitions
+	configuredBlockDevices, err := references.ids(d, "block_devices")
+	if err != nil {
+		configuredBlockDevices = previousStorageIDs(d, "block_devices")
+	}
+
+	blockDevices := []string{}
+	partitions := []string{}
+
+	for _, device := range datastore.Devices {
+		parent := fmt.Sprintf("%v", device.DeviceID)
+		if device.DeviceID != 0 && slices.Contains(configuredBlockDevices, parent) {
+			if !slices.Contains(blockDevices, parent) {
+				blockDevices = append(blockDevices, parent)
+			}
+
+			continue
+		}
+
+		if device.DeviceID != 0 {
+			partitions = append(partitions, fmt.Sprintf("%v", device.ID))
+		} else {
+			blockDevices = append(blockDevices, fmt.Sprintf("%v", device.ID))
+		}
+	}
+
+	selected := map[string]any{}
+	blockDevices = references.attribute(d, "block_devices", blockDevices, selected)
+	partitions = references.attribute(d, "partitions", partitions, selected)
+
+	tfState := map[string]any{
+		"block_devices":    blockDevices,
+		"fs_type":          datastore.Filesystem.FSType,
+		"machine":          datastore.SystemID,
+		"mount_point":      datastore.Filesystem.MountPoint,
+		"name":             datastore.Name,
+		"partitions":       partitions,
+		"selected_devices": selected,
+		"size_gigabytes":   int(math.Round(float64(datastore.Size) / GigaBytes)),
+		"uuid":             datastore.UUID,
+	}
+
+	if err := setTerraformState(d, tfState); err != nil {
+		return diag.Errorf("Could not set VMFS datastore state: %v", err)
+	}
+
+	return nil
+}
+
+func resourceMAASVMFSDatastoreUpdate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	params := url.Values{}
+	params.Set("name", d.Get("name").(string))
+	params.Set("uuid", d.Get("uuid").(string))
+
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	blockDevices, err := references.ids(d, "block_devices")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	for _, device := range blockDevices {
+		if !slices.Contains(previousStorageIDs(d, "block_devices"), device) {
+			params.Add("add_block_devices", device)
+		}
+	}
+
+	partitions, err := references.ids(d, "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	oldPartitions := previousStorageIDs(d, "partitions")
+
+	for _, partition := range partitions {
+		if !slices.Contains(oldPartitions, partition) {
+			params.Add("add_partitions", partition)
+		}
+	}
+
+	for _, partition := range oldPartitions {
+		if !slices.Contains(partitions, partition) {
+			params.Add("remove_partitions", partition)
+		}
+	}
+
+	apiClient, err := nodeObject(client, machine.SystemID, "vmfs-datastore", d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := apiClient.Put(params, func(data []byte) error { return nil }); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMAASVMFSDatastoreRead(ctx, d, meta)
+}
+
+func resourceMAASVMFSDatastoreDelete(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if _, err := strconv.Atoi(d.Id()); err != nil {
+		return diag.FromErr(err)
+	}
+
+	apiClient, err := nodeObject(client, machine.SystemID, "vmfs-datastore", d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := apiClient.Delete(); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func getVMFSDatastoreByID(client *client.Client, machineID string, id string) (*vmfsDatastore, error) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, err
+	}
+
+	apiClient, err := nodeObject(client, machineID, "vmfs-datastore", id)
+	if err != nil {
+		return nil, err
+	}
+
+	datastore := new(vmfsDatastore)
+	err = apiClient.Get("", url.Values{}, func(data []byte) error {
+		return json.Unmarshal(data, datastore)
+	})
+
+	return datastore, err
+}
+
+func getVMFSDatastore(client *client.Client, machineID string, identifier string) (*vmfsDatastore, error) {
+	apiClient, err := nodeObject(client, machineID, "vmfs-datastores")
+	if err != nil {
+		return nil, err
+	}
+
+	var datastores []vmfsDatastore
+	if err := apiClient.Get("", url.Values{}, func(data []byte) error {
+		return json.Unmarshal(data, &datastores)
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, datastore := range datastores {
+		if fmt.Sprintf("%v", datastore.ID) == identifier || datastore.Name == identifier {
+			return &datastore, nil
+		}
+	}
+
+	return nil, fmt.Errorf("VMFS datastore %v was not found on machine %v", identifier, machineID)
+}
@@ -0,0 +1,74 @@
+package maas_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"terraform-provider-maas/maas/testutils"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceMAASVMFSDatastore_basic(t *testing.T) {
+	machine := os.Getenv("TF_ACC_VMFS_DATASTORE_MACHINE")
+	name := "tf-datastore"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testutils.PreCheck(t, []string{"TF_ACC_VMFS_DATASTORE_MACHINE"}) },
+		Providers:    testutils.TestAccProviders,
+		CheckDestroy: func(s *terraform.State) error { return nil },
+		ErrorCheck:   func(err error) error { return err },
+		Steps: []resource.TestStep{
+			// Test initial creation
+			{
+				Config: testAccMAASVMFSDatastore(machine, name, []string{"maas_block_device.bd1.id"}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_vmfs_datastore.test", "name", name),
+					resource.TestCheckResourceAttr("maas_vmfs_datastore.test", "block_devices.#", "1"),
+					resource.TestCheckResourceAttr("maas_vmfs_datastore.test", "fs_type", "vmfs6"),
+					resource.TestCheckResourceAttrSet("maas_vmfs_datastore.test", "uuid"),
+					resource.TestCheckResourceAttrSet("maas_vmfs_datastore.test", "size_gigabytes"),
+				),
+			},
+			// Test growing the datastore in place
+			{
+				Config: testAccMAASVMFSDatastore(machine, name, []string{"maas_block_device.bd1.id", "maas_block_device.bd2.id"}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("maas_vmfs_datastore.test", "block_devices.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+func testAccMAASVMFSDatastore(machine string, name string, blockDevices []string) string {
+	return fmt.Sprintf(`
+data "maas_machine" "machine" {
+  hostname = %q
+}
+
+resource "maas_block_device" "bd1" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfvmfs1"
+  size_gigabytes = 25
+  block_size     = 512
+  id_path        = "/dev/tfvmfs1"
+}
+
+resource "maas_block_device" "bd2" {
+  machine        = data.maas_machine.machine.id
+  name           = "tfvmfs2"
+  size_gigabytes = 25
+  block_size     = 512
+  id_path        = "/dev/tfvmfs2"
+}
+
+resource "maas_vmfs_datastore" "test" {
+  machine       = data.maas_machine.machine.id
+  name          = %q
+  block_devices = [%s]
+}
+`, machine, name, strings.Join(blockDevices, ", "))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/canonical/gomaasclient/client"
 	"github.com/canonical/gomaasclient/entity"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -24,15 +25,20 @@ func resourceMAASVolumeGroup() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceMAASVolumeGroupImport,
 		},
+		CustomizeDiff: customdiff.Sequence(
+			resolveStorageSelectorsDiff("block_device_selector", "partition_selector"),
+			validateVolumeGroupCapacity,
+		),
 
 		Schema: map[string]*schema.Schema{
 			"block_devices": {
 				Type:         schema.TypeSet,
 				Optional:     true,
 				Elem:         &schema.Schema{Type: schema.TypeString},
-				Description:  "The list of block device ids to be included in this volume group.\n*Note*: For the boot disk, a partition should be supplied instead, as MAAS would otherwise automatically create one.",
-				AtLeastOneOf: []string{"block_devices", "partitions"},
+				Description:  "The list of block device ids to be included in this volume group. Block devices can be added and removed in place.\n*Note*: For the boot disk, a partition should be supplied instead, as MAAS would otherwise automatically create one.",
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
 			},
+			"block_device_selector": storageSelectorSchema("Selects a block device to be included in this volume group by its stable attributes, rather than by ID.", false, nil),
 			"machine": {
 				Type:        schema.TypeString,
 				Required:    true,
@@ -48,9 +54,11 @@ func resourceMAASVolumeGroup() *schema.Resource {
 				Type:         schema.TypeSet,
 				Optional:     true,
 				Elem:         &schema.Schema{Type: schema.TypeString},
-				Description:  "The list of partition ids to be included in this volume group.",
-				AtLeastOneOf: []string{"block_devices", "partitions"},
+				Description:  "The list of partition ids to be included in this volume group. Partitions can be added and removed in place.",
+				AtLeastOneOf: []string{"block_devices", "block_device_selector", "partitions", "partition_selector"},
 			},
+			"partition_selector": storageSelectorSchema("Selects a partition to be included in this volume group by the stable attributes of its disk and its number, rather than by ID.", true, nil),
+			"selected_devices":   selectedDevicesSchema(),
 			"size_gigabytes": {
 				Type:        schema.TypeFloat,
 				Computed:    true,
@@ -100,8 +108,20 @@ func resourceMAASVolumeGroupCreate(ctx context.Context, d *schema.ResourceData,
 		return diag.FromErr(err)
 	}
 
-	blockDevices := convertToStringSlice(d.Get("block_devices").(*schema.Set).List())
-	partitions := convertToStringSlice(d.Get("partitions").(*schema.Set).List())
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	blockDevices, err := references.ids(d, "block_devices")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	partitions, err := references.ids(d, "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	bootDisk := fmt.Sprintf("%v", machine.BootDisk.ID)
 	if slices.Contains(blockDevices, bootDisk) {
@@ -144,13 +164,23 @@ func resourceMAASVolumeGroupRead(ctx context.Context, d *schema.ResourceData, me
 
 	blockDevices, partitions := findVolumeGroupDevices(volumeGroup)
 
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	selected := map[string]any{}
+	blockDevices = references.attribute(d, "block_devices", blockDevices, selected)
+	partitions = references.attribute(d, "partitions", partitions, selected)
+
 	tfState := map[string]interface{}{
-		"block_devices":  blockDevices,
-		"machine":        volumeGroup.SystemID,
-		"name":           volumeGroup.Name,
-		"partitions":     partitions,
-		"size_gigabytes": int(math.Round(float64(volumeGroup.Size) / GigaBytes)),
-		"uuid":           volumeGroup.UUID,
+		"block_devices":    blockDevices,
+		"machine":          volumeGroup.SystemID,
+		"name":             volumeGroup.Name,
+		"partitions":       partitions,
+		"selected_devices": selected,
+		"size_gigabytes":   int(math.Round(float64(volumeGroup.Size) / GigaBytes)),
+		"uuid":             volumeGroup.UUID,
 	}
 
 	if err := setTerraformState(d, tfState); err != nil {
@@ -173,26 +203,37 @@ func resourceMAASVolumeGroupUpdate(ctx context.Context, d *schema.ResourceData,
 		return diag.FromErr(err)
 	}
 
-	var addBlockDevices []string
+	references, err := getStorageReferences(client, machine.SystemID, d, "block_devices", "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	var removeBlockDevices []string
+	newBlockDevices, err := references.ids(d, "block_devices")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	newPartitions, err := references.ids(d, "partitions")
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	if d.HasChange("block_devices") {
-		oldBlockDevices, newBlockDevices := d.GetChange("block_devices")
+	oldBlockDevices := previousStorageIDs(d, "block_devices")
+	oldPartitions := previousStorageIDs(d, "partitions")
 
-		oldDeviceList := convertToStringSlice(oldBlockDevices.(*schema.Set).List())
-		newDeviceList := convertToStringSlice(newBlockDevices.(*schema.Set).List())
+	var addBlockDevices []string
 
-		for _, device := range newDeviceList {
-			if !slices.Contains(oldDeviceList, device) {
-				addBlockDevices = append(addBlockDevices, device)
-			}
+	var removeBlockDevices []string
+
+	for _, device := range newBlockDevices {
+		if !slices.Contains(oldBlockDevices, device) {
+			addBlockDevices = append(addBlockDevices, device)
 		}
+	}
 
-		for _, device := range oldDeviceList {
-			if !slices.Contains(newDeviceList, device) {
-				removeBlockDevices = append(removeBlockDevices, device)
-			}
+	for _, device := range oldBlockDevices {
+		if !slices.Contains(newBlockDevices, device) {
+			removeBlockDevices = append(removeBlockDevices, device)
 		}
 	}
 
@@ -200,22 +241,15 @@ func resourceMAASVolumeGroupUpdate(ctx context.Context, d *schema.ResourceData,
 
 	var removePartitions []string
 
-	if d.HasChange("partitions") {
-		oldPartitions, newPartitions := d.GetChange("partitions")
-
-		oldPartitionList := convertToStringSlice(oldPartitions.(*schema.Set).List())
-		newPartitionList := convertToStringSlice(newPartitions.(*schema.Set).List())
-
-		for _, partition := range newPartitionList {
-			if !slices.Contains(oldPartitionList, partition) {
-				addPartitions = append(addPartitions, partition)
-			}
+	for _, partition := range newPartitions {
+		if !slices.Contains(oldPartitions, partition) {
+			addPartitions = append(addPartitions, partition)
 		}
+	}
 
-		for _, partition := range oldPartitionList {
-			if !slices.Contains(newPartitionList, partition) {
-				removePartitions = append(removePartitions, partition)
-			}
+	for _, partition := range oldPartitions {
+		if !slices.Contains(newPartitions, partition) {
+			removePartitions = append(removePartitions, partition)
 		}
 	}
 
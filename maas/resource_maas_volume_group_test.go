@@ -3,6 +3,7 @@ package maas_test
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"terraform-provider-maas/maas"
@@ -53,6 +54,19 @@ func TestAccResourceMAASVolumeGroup_basic(t *testing.T) {
 					resource.TestCheckResourceAttrPair("maas_volume_group.test", "partitions.0", "maas_block_device.bd1", "partitions.0.id"),
 				)...),
 			},
+			// A logical volume uses the space of both members
+			{
+				Config: testAccMAASVolumeGroup(machine, name, []string{"maas_block_device.bd2.id"}, []string{"maas_block_device.bd1.partitions.0.id"}) + testAccMAASVolumeGroupLogicalVolume(30),
+				Check: resource.ComposeTestCheckFunc(append(baseChecks,
+					resource.TestCheckResourceAttr("maas_logical_volume.test", "size_gigabytes", "30"),
+				)...),
+			},
+			// The remaining member cannot hold the logical volume
+			{
+				Config:      testAccMAASVolumeGroup(machine, name, []string{}, []string{"maas_block_device.bd1.partitions.0.id"}) + testAccMAASVolumeGroupLogicalVolume(30),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`block_devices: the logical volumes use .* but the volume group would have .* is missing`),
+			},
 			// Test import
 			{
 				ResourceName:      "maas_volume_group.test",
@@ -113,6 +127,17 @@ resource "maas_volume_group" "test" {
 `, machine, name, strings.Join(blockDevices, ", "), strings.Join(partitions, ", "))
 }
 
+func testAccMAASVolumeGroupLogicalVolume(size int) string {
+	return fmt.Sprintf(`
+resource "maas_logical_volume" "test" {
+  machine        = data.maas_machine.machine.id
+  name           = "tf-vg-lv"
+  volume_group   = maas_volume_group.test.id
+  size_gigabytes = %d
+}
+`, size)
+}
+
 func testAccCheckMAASVolumeGroupExists(rn string, volumeGroup *entity.VolumeGroup) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
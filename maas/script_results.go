@@ -0,0 +1,144 @@
+package maas
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+)
+
+// Script result statuses, as defined by MAAS.
+const (
+	scriptStatusFailed                = 3
+	scriptStatusTimedOut              = 4
+	scriptStatusAborted               = 5
+	scriptStatusFailedInstalling      = 8
+	scriptStatusFailedApplyingNetconf = 10
+)
+
+// nodeScriptResult is the result of a script run on a node.
+type nodeScriptResult struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Status     int    `json:"status"`
+	StatusName string `json:"status_name"`
+	ExitStatus *int   `json:"exit_status"`
+	Started    string `json:"started"`
+	Ended      string `json:"ended"`
+	Runtime    string `json:"runtime"`
+	Parameters any    `json:"parameters"`
+	Output     string `json:"output"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Result     string `json:"result"`
+}
+
+// nodeScriptResultSet is a set of script results, e.g. the results of a testing run.
+type nodeScriptResultSet struct {
+	ID         int                `json:"id"`
+	Type       int                `json:"type"`
+	TypeName   string             `json:"type_name"`
+	Status     int                `json:"status"`
+	StatusName string             `json:"status_name"`
+	Started    string             `json:"started"`
+	Ended      string             `json:"ended"`
+	Runtime    string             `json:"runtime"`
+	Results    []nodeScriptResult `json:"results"`
+}
+
+// getNodeScriptResultSet returns a script result set of a node, with the output of
+// the scripts.
+func getNodeScriptResultSet(c *client.Client, systemID string, resultSet string) (*nodeScriptResultSet, error) {
+	result := new(nodeScriptResultSet)
+
+	err := nodeResults(c, systemID, resultSet, url.Values{"include_output": {"true"}}, result)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result.Results, func(i, j int) bool {
+		return result.Results[i].ID < result.Results[j].ID
+	})
+
+	return result, nil
+}
+
+// getNodeLatestScriptResultSet returns the latest script result set of the given type
+// (commissioning, testing, installation or release) of a node, with the output of
+// the scripts. The filters restrict the results to the given script names or tags.
+func getNodeLatestScriptResultSet(c *client.Client, systemID string, resultType string, filters []string) (*nodeScriptResultSet, error) {
+	params := url.Values{
+		"type":           {resultType},
+		"include_output": {"true"},
+	}
+	if len(filters) > 0 {
+		params.Set("filters", strings.Join(filters, ","))
+	}
+
+	var resultSets []nodeScriptResultSet
+
+	if err := nodeResults(c, systemID, "", params, &resultSets); err != nil {
+		return nil, err
+	}
+
+	if len(resultSets) == 0 {
+		return nil, fmt.Errorf("no %s script results found for node (%s)", resultType, systemID)
+	}
+
+	latest := slices.MaxFunc(resultSets, func(a, b nodeScriptResultSet) int {
+		return a.ID - b.ID
+	})
+
+	sort.Slice(latest.Results, func(i, j int) bool {
+		return latest.Results[i].ID < latest.Results[j].ID
+	})
+
+	return &latest, nil
+}
+
+// decodeScriptOutput decodes a script output, which MAAS returns base64 encoded.
+func decodeScriptOutput(output string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(output)
+}
+
+// isFailed returns whether the script did not complete successfully.
+func (r *nodeScriptResult) isFailed() bool {
+	switch r.Status {
+	case scriptStatusFailed,
+		scriptStatusTimedOut,
+		scriptStatusAborted,
+		scriptStatusFailedInstalling,
+		scriptStatusFailedApplyingNetconf:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitStatus returns the exit status of the script, or -1 if it did not exit.
+func (r *nodeScriptResult) exitStatus() int {
+	if r.ExitStatus == nil {
+		return -1
+	}
+
+	return *r.ExitStatus
+}
+
+// outputDigest returns the SHA-256 digest of the script output, which MAAS returns
+// base64 encoded.
+func (r *nodeScriptResult) outputDigest() (string, error) {
+	output, err := decodeScriptOutput(r.Output)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(output)
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,411 @@
+package maas
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// storageMember is a block device or partition used to build a RAID or a volume group.
+type storageMember struct {
+	attr    string
+	name    string
+	usedFor string
+	size    int64
+	inUse   bool
+}
+
+// machineStorageDevices are the block devices and partitions of a machine, by ID.
+type machineStorageDevices struct {
+	blockDevices map[string]storageMember
+	partitions   map[string]storageMember
+}
+
+// getMachineStorageDevices returns the current block devices and partitions of a machine.
+func getMachineStorageDevices(client *client.Client, systemID string) (*machineStorageDevices, error) {
+	blockDevices, err := client.BlockDevices.Get(systemID)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := &machineStorageDevices{
+		blockDevices: map[string]storageMember{},
+		partitions:   map[string]storageMember{},
+	}
+
+	for _, b := range blockDevices {
+		devices.blockDevices[fmt.Sprintf("%v", b.ID)] = storageMember{
+			name:    b.Name,
+			usedFor: b.UsedFor,
+			size:    b.Size,
+			inUse:   len(b.Partitions) > 0 || b.Filesystem.FSType != "",
+		}
+
+		for _, p := range b.Partitions {
+			devices.partitions[fmt.Sprintf("%v", p.ID)] = storageMember{
+				name:    strings.TrimPrefix(p.Path, "/dev/disk/by-dname/"),
+				usedFor: p.UsedFor,
+				size:    p.Size,
+				inUse:   p.FileSystem.FSType != "",
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// members returns the block devices or partitions of the given attribute and of its
+// selectors. The ones added by the change must exist and be unused.
+func (m *machineStorageDevices) members(d *schema.ResourceDiff, attr string, partitions bool) ([]storageMember, error) {
+	devices, kind := m.blockDevices, "block device"
+	if partitions {
+		devices, kind = m.partitions, "partition"
+	}
+
+	oldValue, newValue := d.GetChange(attr)
+	oldSelected, newSelected := d.GetChange("selected_devices")
+	current := mergeStorageIDs(oldValue, oldSelected.(map[string]any), attr)
+
+	var members []storageMember
+
+	for _, id := range mergeStorageIDs(newValue, newSelected.(map[string]any), attr) {
+		member, err := getStorageMember(devices, kind, attr, id, d.Id() != "" && slices.Contains(current, id))
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// getStorageMember returns the device of the given attribute, which must exist and be
+// unused unless it is already a member.
+func getStorageMember(devices map[string]storageMember, kind string, attr string, id string, isMember bool) (storageMember, error) {
+	member, ok := devices[id]
+	if !ok {
+		return member, fmt.Errorf("%s: %s (%s) was not found on the machine", attr, kind, id)
+	}
+
+	if member.inUse && !isMember {
+		return member, fmt.Errorf("%s: %s %s (%s) is already used: %s", attr, kind, member.name, id, member.usedFor)
+	}
+
+	member.attr = attr
+
+	return member, nil
+}
+
+// getStorageDiffMachine returns the machine storage devices for a capacity check, or nil
+// if the check must be skipped because some of the given attributes are not known yet.
+func getStorageDiffMachine(d *schema.ResourceDiff, meta any, attrs ...string) (*machineStorageDevices, string, error) {
+	for _, attr := range append(attrs, "machine", "selected_devices") {
+		if !d.NewValueKnown(attr) {
+			return nil, "", nil
+		}
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return nil, "", err
+	}
+
+	devices, err := getMachineStorageDevices(client, machine.SystemID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return devices, machine.SystemID, nil
+}
+
+// changedStorageAttr returns the first of the given attributes, or of their selectors,
+// changed by the diff.
+func changedStorageAttr(d *schema.ResourceDiff, attrs ...string) string {
+	for _, attr := range attrs {
+		for _, key := range []string{attr, storageSelectorAttr(attr)} {
+			if d.HasChange(key) {
+				return key
+			}
+		}
+	}
+
+	return "selected_devices"
+}
+
+// formatGigaBytes formats a size in bytes as GB, with a single decimal if needed.
+func formatGigaBytes(size int64) string {
+	return strings.TrimSuffix(strconv.FormatFloat(float64(size)/GigaBytes, 'f', 1, 64), ".0") + " GB"
+}
+
+// roundGigaBytes rounds a size in bytes to the nearest GB.
+func roundGigaBytes(size int64) int64 {
+	return int64(math.Round(float64(size) / GigaBytes))
+}
+
+// validateBlockDeviceCapacity checks that the partitions of a block device fit on it.
+func validateBlockDeviceCapacity(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	if !d.NewValueKnown("partitions") || !d.NewValueKnown("size_gigabytes") || !d.HasChanges("partitions", "size_gigabytes") {
+		return nil
+	}
+
+	size := int64(d.Get("size_gigabytes").(int)) * GigaBytes
+
+	// The partitions of an existing physical disk are limited by its real size
+	if d.NewValueKnown("machine") && d.NewValueKnown("name") {
+		client := meta.(*ClientConfig).Client
+
+		machine, err := getMachine(client, d.Get("machine").(string))
+		if err != nil {
+			return err
+		}
+
+		blockDevice, err := findBlockDevice(client, machine.SystemID, d.Get("name").(string))
+		if err != nil {
+			return err
+		}
+
+		if blockDevice != nil && blockDevice.Type == "physical" && blockDevice.Size < size {
+			size = blockDevice.Size
+		}
+	}
+
+	var required int64
+
+	for _, p := range d.Get("partitions").([]any) {
+		partition, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		required += int64(partition["size_gigabytes"].(int)) * GigaBytes
+	}
+
+	if required > size {
+		return fmt.Errorf("partitions: the partitions require %s but the block device has %s, %s is missing", formatGigaBytes(required), formatGigaBytes(size), formatGigaBytes(required-size))
+	}
+
+	return nil
+}
+
+// validateRAIDCapacity checks that the RAID members exist and are unused, that the
+// spares can replace them and that an existing RAID does not shrink.
+func validateRAIDCapacity(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	attrs := []string{"block_devices", "partitions", "spare_devices", "spare_partitions"}
+	if d.Id() != "" && !d.HasChanges(append(attrs, "level", "selected_devices")...) {
+		return nil
+	}
+
+	devices, systemID, err := getStorageDiffMachine(d, meta, append(attrs, "level")...)
+	if err != nil || devices == nil {
+		return err
+	}
+
+	var active, spares []storageMember
+
+	for _, attr := range attrs {
+		members, err := devices.members(d, attr, strings.HasSuffix(attr, "partitions"))
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(attr, "spare_") {
+			spares = append(spares, members...)
+		} else {
+			active = append(active, members...)
+		}
+	}
+
+	level := d.Get("level").(string)
+	if err := verifyRAIDDevicesLevel(level, len(active), len(spares)); err != nil {
+		return fmt.Errorf("level: %w", err)
+	}
+
+	var current int64
+
+	// A new level replaces the RAID
+	if d.Id() != "" && !d.HasChange("level") {
+		id, err := strconv.Atoi(d.Id())
+		if err != nil {
+			return err
+		}
+
+		raid, err := meta.(*ClientConfig).Client.RAID.Get(systemID, id)
+		if err != nil {
+			return err
+		}
+
+		current = int64(raid.Size)
+	}
+
+	if err := checkRAIDCapacity(changedStorageAttr(d, attrs...), level, active, spares, current); err != nil {
+		return err
+	}
+
+	// MAAS deducts the RAID metadata from the size of its members
+	return d.SetNewComputed("size_gigabytes")
+}
+
+// checkRAIDCapacity checks that the spares can replace the active members of a RAID
+// and that the data of an existing RAID, of the given size, fit on these members.
+func checkRAIDCapacity(attr string, level string, active []storageMember, spares []storageMember, current int64) error {
+	// MAAS sizes the RAID after its smallest member, compare the members in GB to
+	// ignore the partition alignment
+	smallest := slices.MinFunc(active, func(a, b storageMember) int { return cmp.Compare(a.size, b.size) })
+
+	for _, spare := range spares {
+		if roundGigaBytes(spare.size) < roundGigaBytes(smallest.size) {
+			return fmt.Errorf("%s: spare %s (%s) cannot replace a RAID member, %s is missing",
+				spare.attr, spare.name, formatGigaBytes(spare.size), formatGigaBytes(smallest.size-spare.size))
+		}
+	}
+
+	// The data must fit on the remaining members, after the parity or mirror
+	// overhead of the level
+	size := int64(float64(smallest.size) * raidDataDevices(level, len(active)))
+	if current > 0 && roundGigaBytes(size) < roundGigaBytes(current) {
+		return fmt.Errorf("%s: RAID %s of %d devices would hold %s but the RAID has %s, %s is missing",
+			attr, level, len(active), formatGigaBytes(size), formatGigaBytes(current), formatGigaBytes(current-size))
+	}
+
+	return nil
+}
+
+// raidDataDevices returns how many of the active devices of a RAID hold data, the
+// others holding parity or mirrored data. RAID 10 mirrors every block once, so an
+// odd number of devices holds a half device of data.
+func raidDataDevices(level string, count int) float64 {
+	switch level {
+	case "1":
+		return 1
+	case "5":
+		return float64(count - 1)
+	case "6":
+		return float64(count - 2)
+	case "10":
+		return float64(count) / 2
+	default:
+		return float64(count)
+	}
+}
+
+// validateVolumeGroupCapacity checks that the volume group members exist and are
+// unused, and that the remaining members can hold the logical volumes.
+func validateVolumeGroupCapacity(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	attrs := []string{"block_devices", "partitions"}
+	if d.Id() != "" && !d.HasChanges(append(attrs, "selected_devices")...) {
+		return nil
+	}
+
+	devices, systemID, err := getStorageDiffMachine(d, meta, attrs...)
+	if err != nil || devices == nil {
+		return err
+	}
+
+	var size int64
+
+	for _, attr := range attrs {
+		members, err := devices.members(d, attr, attr == "partitions")
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			size += member.size
+		}
+	}
+
+	if d.Id() != "" {
+		id, err := strconv.Atoi(d.Id())
+		if err != nil {
+			return err
+		}
+
+		volumeGroup, err := meta.(*ClientConfig).Client.VolumeGroup.Get(systemID, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkVolumeGroupCapacity(changedStorageAttr(d, attrs...), size, volumeGroup.UsedSize); err != nil {
+			return err
+		}
+	}
+
+	return d.SetNewComputed("size_gigabytes")
+}
+
+// checkVolumeGroupCapacity checks that the members of a volume group, of the given
+// size, can hold its logical volumes.
+func checkVolumeGroupCapacity(attr string, size int64, used int64) error {
+	if used > size {
+		return fmt.Errorf("%s: the logical volumes use %s but the volume group would have %s, %s is missing", attr, formatGigaBytes(used), formatGigaBytes(size), formatGigaBytes(used-size))
+	}
+
+	return nil
+}
+
+// validateLogicalVolumeCapacity checks that the volume group has enough free space
+// for the logical volume.
+func validateLogicalVolumeCapacity(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	if !d.HasChange("size_gigabytes") {
+		return nil
+	}
+
+	for _, attr := range []string{"machine", "volume_group", "size_gigabytes"} {
+		if !d.NewValueKnown(attr) {
+			return nil
+		}
+	}
+
+	client := meta.(*ClientConfig).Client
+
+	machine, err := getMachine(client, d.Get("machine").(string))
+	if err != nil {
+		return err
+	}
+
+	volumeGroups, err := client.VolumeGroups.Get(machine.SystemID)
+	if err != nil {
+		return err
+	}
+
+	identifier := d.Get("volume_group").(string)
+
+	// The volume group may be created by the same apply
+	i := slices.IndexFunc(volumeGroups, func(vg entity.VolumeGroup) bool {
+		return fmt.Sprintf("%v", vg.ID) == identifier || vg.Name == identifier
+	})
+	if i < 0 {
+		return nil
+	}
+
+	var previous int64
+
+	oldSize, newSize := d.GetChange("size_gigabytes")
+	if d.Id() != "" {
+		previous = int64(oldSize.(int)) * GigaBytes
+	}
+
+	return checkLogicalVolumeCapacity(&volumeGroups[i], previous, int64(newSize.(int))*GigaBytes)
+}
+
+// checkLogicalVolumeCapacity checks that the volume group, where the logical volume
+// currently uses the previous size, has the required size available.
+func checkLogicalVolumeCapacity(volumeGroup *entity.VolumeGroup, previous int64, required int64) error {
+	available := volumeGroup.AvailableSize + previous
+	if required > available {
+		return fmt.Errorf("size_gigabytes: the logical volume requires %s but volume group %s has %s available, %s is missing", formatGigaBytes(required), volumeGroup.Name, formatGigaBytes(available), formatGigaBytes(required-available))
+	}
+
+	return nil
+}
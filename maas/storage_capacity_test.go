@@ -0,0 +1,289 @@
+package maas
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStorageMembers(attr string, sizes ...float64) []storageMember {
+	members := make([]storageMember, len(sizes))
+	for i, size := range sizes {
+		members[i] = storageMember{attr: attr, name: "sd" + string(rune('a'+i)), size: int64(size * GigaBytes)}
+	}
+
+	return members
+}
+
+func TestRAIDDataDevices(t *testing.T) {
+	testCases := []struct {
+		level    string
+		count    int
+		expected float64
+	}{
+		{level: "0", count: 2, expected: 2},
+		{level: "0", count: 4, expected: 4},
+		{level: "1", count: 2, expected: 1},
+		{level: "1", count: 3, expected: 1},
+		{level: "5", count: 3, expected: 2},
+		{level: "5", count: 4, expected: 3},
+		{level: "6", count: 4, expected: 2},
+		{level: "6", count: 5, expected: 3},
+		{level: "10", count: 3, expected: 1.5},
+		{level: "10", count: 4, expected: 2},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("RAID %s of %d devices", testCase.level, testCase.count), func(t *testing.T) {
+			assert.Equal(t, testCase.expected, raidDataDevices(testCase.level, testCase.count))
+		})
+	}
+}
+
+func TestGetStorageMember(t *testing.T) {
+	devices := map[string]storageMember{
+		"1": {name: "sda", size: 10 * GigaBytes},
+		"2": {name: "sdb", size: 10 * GigaBytes, usedFor: "ext4 formatted filesystem mounted at /", inUse: true},
+	}
+
+	testCases := []struct {
+		name     string
+		attr     string
+		id       string
+		isMember bool
+		err      string
+	}{
+		{
+			name: "unused device",
+			attr: "block_devices",
+			id:   "1",
+		},
+		{
+			name:     "device used by the same RAID",
+			attr:     "block_devices",
+			id:       "2",
+			isMember: true,
+		},
+		{
+			name: "device used elsewhere",
+			attr: "spare_devices",
+			id:   "2",
+			err:  "spare_devices: block device sdb (2) is already used: ext4 formatted filesystem mounted at /",
+		},
+		{
+			name: "missing device",
+			attr: "block_devices",
+			id:   "3",
+			err:  "block_devices: block device (3) was not found on the machine",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			member, err := getStorageMember(devices, "block device", testCase.attr, testCase.id, testCase.isMember)
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.attr, member.attr)
+		})
+	}
+}
+
+func TestCheckRAIDCapacity(t *testing.T) {
+	testCases := []struct {
+		name    string
+		attr    string
+		level   string
+		active  []storageMember
+		spares  []storageMember
+		current float64
+		err     string
+	}{
+		{
+			name:   "new RAID with a spare",
+			attr:   "block_devices",
+			level:  "1",
+			active: testStorageMembers("block_devices", 10, 10),
+			spares: testStorageMembers("spare_devices", 10),
+		},
+		{
+			name:   "spare within the partition alignment",
+			attr:   "block_devices",
+			level:  "1",
+			active: testStorageMembers("block_devices", 10, 10),
+			spares: testStorageMembers("spare_partitions", 9.99),
+		},
+		{
+			name:   "spare too small",
+			attr:   "block_devices",
+			level:  "5",
+			active: testStorageMembers("block_devices", 10, 10, 10),
+			spares: testStorageMembers("spare_partitions", 8),
+			err:    "spare_partitions: spare sda (8 GB) cannot replace a RAID member, 2 GB is missing",
+		},
+		{
+			name:    "RAID 0 with its metadata",
+			attr:    "block_devices",
+			level:   "0",
+			active:  testStorageMembers("block_devices", 10, 10),
+			current: 19.9,
+		},
+		{
+			name:    "RAID 1 member replaced by a smaller one",
+			attr:    "partitions",
+			level:   "1",
+			active:  testStorageMembers("partitions", 10, 8),
+			current: 10,
+			err:     "partitions: RAID 1 of 2 devices would hold 8 GB but the RAID has 10 GB, 2 GB is missing",
+		},
+		{
+			name:    "RAID 5 member removed",
+			attr:    "block_devices",
+			level:   "5",
+			active:  testStorageMembers("block_devices", 10, 10, 10),
+			current: 30,
+			err:     "block_devices: RAID 5 of 3 devices would hold 20 GB but the RAID has 30 GB, 10 GB is missing",
+		},
+		{
+			name:    "RAID 5 member moved to the spares",
+			attr:    "spare_devices",
+			level:   "5",
+			active:  testStorageMembers("block_devices", 10, 10, 10),
+			spares:  testStorageMembers("spare_devices", 10),
+			current: 30,
+			err:     "spare_devices: RAID 5 of 3 devices would hold 20 GB but the RAID has 30 GB, 10 GB is missing",
+		},
+		{
+			name:    "RAID 6 member added",
+			attr:    "block_devices",
+			level:   "6",
+			active:  testStorageMembers("block_devices", 10, 10, 10, 10, 10),
+			current: 20,
+		},
+		{
+			name:    "RAID 10 of an odd number of devices",
+			attr:    "block_devices",
+			level:   "10",
+			active:  testStorageMembers("block_devices", 10, 10, 10),
+			current: 15,
+		},
+		{
+			name:    "RAID 10 member removed",
+			attr:    "block_devices",
+			level:   "10",
+			active:  testStorageMembers("block_devices", 10, 10, 10),
+			current: 20,
+			err:     "block_devices: RAID 10 of 3 devices would hold 15 GB but the RAID has 20 GB, 5 GB is missing",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := checkRAIDCapacity(testCase.attr, testCase.level, testCase.active, testCase.spares, int64(testCase.current*GigaBytes))
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckVolumeGroupCapacity(t *testing.T) {
+	testCases := []struct {
+		name string
+		attr string
+		size float64
+		used float64
+		err  string
+	}{
+		{
+			name: "member added",
+			attr: "block_devices",
+			size: 70,
+			used: 20,
+		},
+		{
+			name: "logical volumes use all the members",
+			attr: "partitions",
+			size: 20,
+			used: 20,
+		},
+		{
+			name: "member removed",
+			attr: "block_devices",
+			size: 20,
+			used: 25,
+			err:  "block_devices: the logical volumes use 25 GB but the volume group would have 20 GB, 5 GB is missing",
+		},
+		{
+			name: "member replaced by a selector",
+			attr: "selected_devices",
+			size: 20,
+			used: 22.5,
+			err:  "selected_devices: the logical volumes use 22.5 GB but the volume group would have 20 GB, 2.5 GB is missing",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := checkVolumeGroupCapacity(testCase.attr, int64(testCase.size*GigaBytes), int64(testCase.used*GigaBytes))
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckLogicalVolumeCapacity(t *testing.T) {
+	volumeGroup := &entity.VolumeGroup{Name: "vg0", AvailableSize: 5 * GigaBytes}
+
+	testCases := []struct {
+		name     string
+		previous int64
+		required int64
+		err      string
+	}{
+		{
+			name:     "new logical volume",
+			required: 5,
+		},
+		{
+			name:     "new logical volume too large",
+			required: 6,
+			err:      "size_gigabytes: the logical volume requires 6 GB but volume group vg0 has 5 GB available, 1 GB is missing",
+		},
+		{
+			name:     "logical volume grown",
+			previous: 4,
+			required: 9,
+		},
+		{
+			name:     "logical volume grown too much",
+			previous: 4,
+			required: 10,
+			err:      "size_gigabytes: the logical volume requires 10 GB but volume group vg0 has 9 GB available, 1 GB is missing",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := checkLogicalVolumeCapacity(volumeGroup, testCase.previous*GigaBytes, testCase.required*GigaBytes)
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package maas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/canonical/gomaasclient/client"
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// errStoragePartitionNotFound is returned when the partition of a selector does not
+// exist yet, it may be created by the same apply.
+var errStoragePartitionNotFound = errors.New("partition not found")
+
+// storageSelector selects a physical disk of a machine by its stable attributes, which
+// survive the disks being renamed after a recommissioning, and optionally one of its
+// partitions.
+type storageSelector struct {
+	IDPath           string
+	Model            string
+	Serial           string
+	WWN              string
+	Tags             []string
+	MinSizeGigabytes int
+	MaxSizeGigabytes int
+	Partition        int
+}
+
+// storageSelectorSchema returns the schema of a list of selectors. The selectors of
+// partitions also select a partition of the selected disk. A selector alternative to a
+// single ID is given the attributes it is exclusive with.
+func storageSelectorSchema(description string, partition bool, exactlyOneOf []string) *schema.Schema {
+	s := withStorageSelectorSchema(map[string]*schema.Schema{})
+
+	if partition {
+		s["partition"] = &schema.Schema{
+			Type:         schema.TypeInt,
+			Required:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "The number of the partition on the selected disk, starting at 1.",
+		}
+	}
+
+	selector := &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: description + " The disk must match all the attributes set on the selector, and the selector must match exactly one disk of the machine.",
+		Elem:        &schema.Resource{Schema: s},
+	}
+
+	if exactlyOneOf != nil {
+		selector.MaxItems = 1
+		selector.ExactlyOneOf = exactlyOneOf
+	}
+
+	return selector
+}
+
+// withStorageSelectorSchema adds the attributes selecting a disk to the given schema.
+func withStorageSelectorSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	maps.Copy(s, map[string]*schema.Schema{
+		"id_path": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The ID path of the disk (e.g. `/dev/disk/by-id/nvme-Samsung_SSD_970_S4EWNX0N123456`).",
+		},
+		"max_size_gigabytes": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "The maximum size of the disk (GB).",
+		},
+		"min_size_gigabytes": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "The minimum size of the disk (GB).",
+		},
+		"model": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The model of the disk.",
+		},
+		"serial": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The serial number of the disk.",
+		},
+		"tags": {
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags the disk must have.",
+		},
+		"wwn": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The World Wide Name of the disk (e.g. `0x5000c500a1b2c3d4`), as found in its `/dev/disk/by-id/wwn-` ID path.",
+		},
+	})
+
+	return s
+}
+
+// selectedDevicesSchema returns the schema of the selectors resolution of a resource.
+func selectedDevicesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeMap,
+		Computed:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Description: "The IDs of the block devices and partitions selected by each selector, keyed by `<selector block>.<index>` (e.g. `block_device_selector.0`).",
+	}
+}
+
+func expandStorageSelector(m map[string]any) storageSelector {
+	s := storageSelector{
+		IDPath:           m["id_path"].(string),
+		Model:            m["model"].(string),
+		Serial:           m["serial"].(string),
+		WWN:              m["wwn"].(string),
+		MinSizeGigabytes: m["min_size_gigabytes"].(int),
+		MaxSizeGigabytes: m["max_size_gigabytes"].(int),
+	}
+
+	if tags, ok := m["tags"].(*schema.Set); ok {
+		s.Tags = sortedStrings(convertToStringSlice(tags.List()))
+	}
+
+	if partition, ok := m["partition"].(int); ok {
+		s.Partition = partition
+	}
+
+	return s
+}
+
+func (s storageSelector) String() string {
+	var criteria []string
+
+	for _, c := range [][2]string{{"id_path", s.IDPath}, {"model", s.Model}, {"serial", s.Serial}, {"wwn", s.WWN}} {
+		if c[1] != "" {
+			criteria = append(criteria, fmt.Sprintf("%s %q", c[0], c[1]))
+		}
+	}
+
+	if len(s.Tags) > 0 {
+		criteria = append(criteria, fmt.Sprintf("tags %q", s.Tags))
+	}
+
+	if s.MinSizeGigabytes > 0 {
+		criteria = append(criteria, fmt.Sprintf("at least %d GB", s.MinSizeGigabytes))
+	}
+
+	if s.MaxSizeGigabytes > 0 {
+		criteria = append(criteria, fmt.Sprintf("at most %d GB", s.MaxSizeGigabytes))
+	}
+
+	return strings.Join(criteria, ", ")
+}
+
+func (s storageSelector) matches(b *entity.BlockDevice) bool {
+	size := int(math.Round(float64(b.Size) / GigaBytes))
+
+	switch {
+	case b.Type != "physical":
+		return false
+	case s.IDPath != "" && b.IDPath != s.IDPath:
+		return false
+	case s.Model != "" && b.Model != s.Model:
+		return false
+	case s.Serial != "" && b.Serial != s.Serial:
+		return false
+	case s.WWN != "" && !strings.HasSuffix(b.IDPath, "/wwn-"+s.WWN):
+		return false
+	case s.MinSizeGigabytes > 0 && size < s.MinSizeGigabytes:
+		return false
+	case s.MaxSizeGigabytes > 0 && size > s.MaxSizeGigabytes:
+		return false
+	}
+
+	for _, tag := range s.Tags {
+		if !slices.Contains(b.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// selectStorageDevice returns the ID of the disk, or of the partition, selected
+// among the block devices of a machine.
+func selectStorageDevice(blockDevices []entity.BlockDevice, s storageSelector) (string, error) {
+	if s.String() == "" {
+		return "", fmt.Errorf("at least one of id_path, model, serial, wwn, tags, min_size_gigabytes or max_size_gigabytes must be set")
+	}
+
+	var matches []*entity.BlockDevice
+
+	for i := range blockDevices {
+		if s.matches(&blockDevices[i]) {
+			matches = append(matches, &blockDevices[i])
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no disk matches %s", s)
+	}
+
+	if len(matches) > 1 {
+		names := make([]string, len(matches))
+		for i, b := range matches {
+			names[i] = b.Name
+		}
+
+		return "", fmt.Errorf("%d disks match %s: %s, the selector must match exactly one disk", len(matches), s, strings.Join(names, ", "))
+	}
+
+	disk := matches[0]
+	if s.Partition == 0 {
+		return fmt.Sprintf("%v", disk.ID), nil
+	}
+
+	// The partitions are numbered in the order of their IDs, see getBlockDevicePartitionsTFState
+	partitions := slices.Clone(disk.Partitions)
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+
+	if s.Partition > len(partitions) {
+		return "", fmt.Errorf("disk %s matching %s has no partition %d: %w", disk.Name, s, s.Partition, errStoragePartitionNotFound)
+	}
+
+	return fmt.Sprintf("%v", partitions[s.Partition-1].ID), nil
+}
+
+// resolveStorageSelectors returns the IDs selected by each selector of the attribute,
+// keyed by `<attribute>.<index>`.
+func resolveStorageSelectors(blockDevices []entity.BlockDevice, attr string, selectors []any) (map[string]string, error) {
+	selected := map[string]string{}
+
+	for i, v := range selectors {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s.%d", attr, i)
+
+		id, err := selectStorageDevice(blockDevices, expandStorageSelector(m))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		for k, other := range selected {
+			if other == id {
+				return nil, fmt.Errorf("%s: selects the same device as %s", key, k)
+			}
+		}
+
+		selected[key] = id
+	}
+
+	return selected, nil
+}
+
+// resolveStorageSelectorsDiff plans the devices selected by the given selector
+// attributes, so that a disk matching a selector after a recommissioning shows up as a
+// change. The selectors are resolved again when applying.
+func resolveStorageSelectorsDiff(attrs ...string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+		configured := false
+
+		for _, attr := range attrs {
+			if !d.NewValueKnown(attr) || !d.NewValueKnown("machine") {
+				return d.SetNewComputed("selected_devices")
+			}
+
+			configured = configured || len(d.Get(attr).([]any)) > 0
+		}
+
+		if !configured {
+			if len(d.Get("selected_devices").(map[string]any)) > 0 {
+				return d.SetNew("selected_devices", map[string]any{})
+			}
+
+			return nil
+		}
+
+		client := meta.(*ClientConfig).Client
+
+		machine, err := getMachine(client, d.Get("machine").(string))
+		if err != nil {
+			return err
+		}
+
+		blockDevices, err := client.BlockDevices.Get(machine.SystemID)
+		if err != nil {
+			return err
+		}
+
+		planned := map[string]any{}
+
+		for _, attr := range attrs {
+			selected, err := resolveStorageSelectors(blockDevices, attr, d.Get(attr).([]any))
+			if errors.Is(err, errStoragePartitionNotFound) {
+				return d.SetNewComputed("selected_devices")
+			}
+
+			if err != nil {
+				return err
+			}
+
+			for k, id := range selected {
+				planned[k] = id
+			}
+		}
+
+		if maps.Equal(planned, d.Get("selected_devices").(map[string]any)) {
+			return nil
+		}
+
+		return d.SetNew("selected_devices", planned)
+	}
+}
+
+// storageReferences resolves the block devices and partitions referenced by a
+// resource, by ID or through selectors.
+type storageReferences struct {
+	blockDevices []entity.BlockDevice
+}
+
+// getStorageReferences returns the references of a resource with the given ID
+// attributes. The block devices of the machine are only fetched if selectors are set.
+func getStorageReferences(client *client.Client, systemID string, d *schema.ResourceData, attrs ...string) (*storageReferences, error) {
+	for _, attr := range attrs {
+		if len(d.Get(storageSelectorAttr(attr)).([]any)) == 0 {
+			continue
+		}
+
+		blockDevices, err := client.BlockDevices.Get(systemID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &storageReferences{blockDevices: blockDevices}, nil
+	}
+
+	return &storageReferences{}, nil
+}
+
+// storageSelectorAttr returns the selector attribute of an ID attribute, e.g.
+// `block_device_selector` for `block_devices`.
+func storageSelectorAttr(attr string) string {
+	return strings.TrimSuffix(attr, "s") + "_selector"
+}
+
+// ids returns the IDs of the attribute and the IDs selected by its selectors, which
+// are resolved again against the current disks of the machine.
+func (r *storageReferences) ids(d *schema.ResourceData, attr string) ([]string, error) {
+	selected, err := resolveStorageSelectors(r.blockDevices, storageSelectorAttr(attr), d.Get(storageSelectorAttr(attr)).([]any))
+	if err != nil {
+		return nil, err
+	}
+
+	planned := map[string]any{}
+	for k, id := range selected {
+		planned[k] = id
+	}
+
+	return mergeStorageIDs(d.Get(attr), planned, attr), nil
+}
+
+// attribute assigns the members of a resource to the selectors of the attribute that
+// select them, and returns the members that are not selected.
+func (r *storageReferences) attribute(d *schema.ResourceData, attr string, members []string, selected map[string]any) []string {
+	for i, v := range d.Get(storageSelectorAttr(attr)).([]any) {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		id, err := selectStorageDevice(r.blockDevices, expandStorageSelector(m))
+		if err != nil || !slices.Contains(members, id) {
+			continue
+		}
+
+		selected[fmt.Sprintf("%s.%d", storageSelectorAttr(attr), i)] = id
+		members = slices.DeleteFunc(slices.Clone(members), func(member string) bool { return member == id })
+	}
+
+	return members
+}
+
+// previousStorageIDs returns the IDs of the attribute and the IDs selected by its
+// selectors, as they were before the change.
+func previousStorageIDs(d *schema.ResourceData, attr string) []string {
+	oldIDs, _ := d.GetChange(attr)
+	oldSelected, _ := d.GetChange("selected_devices")
+
+	return mergeStorageIDs(oldIDs, oldSelected.(map[string]any), attr)
+}
+
+// mergeStorageIDs returns the IDs of a set, or a single ID, followed by the IDs
+// selected by the selectors of the attribute.
+func mergeStorageIDs(ids any, selected map[string]any, attr string) []string {
+	var merged []string
+
+	switch ids := ids.(type) {
+	case *schema.Set:
+		merged = convertToStringSlice(ids.List())
+	case string:
+		if ids != "" {
+			merged = append(merged, ids)
+		}
+	}
+
+	prefix := storageSelectorAttr(attr) + "."
+
+	for _, k := range slices.Sorted(maps.Keys(selected)) {
+		if id, ok := selected[k].(string); ok && strings.HasPrefix(k, prefix) && !slices.Contains(merged, id) {
+			merged = append(merged, id)
+		}
+	}
+
+	return merged
+}
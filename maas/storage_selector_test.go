@@ -0,0 +1,149 @@
+package maas
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/gomaasclient/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStorageSelectorBlockDevices() []entity.BlockDevice {
+	return []entity.BlockDevice{
+		{
+			ID:     1,
+			Name:   "sda",
+			Type:   "physical",
+			IDPath: "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4",
+			Model:  "Samsung SSD 870",
+			Serial: "S4EVNF0M123456",
+			Size:   500 * GigaBytes,
+			Tags:   []string{"ssd"},
+			Partitions: []entity.BlockDevicePartition{
+				{ID: 12},
+				{ID: 11},
+			},
+		},
+		{
+			ID:     2,
+			Name:   "sdb",
+			Type:   "physical",
+			IDPath: "/dev/disk/by-id/wwn-0x5000c500a1b2c3d5",
+			Model:  "Samsung SSD 870",
+			Serial: "S4EVNF0M654321",
+			Size:   1000 * GigaBytes,
+			Tags:   []string{"ssd"},
+		},
+		{
+			ID:     3,
+			Name:   "sdc",
+			Type:   "physical",
+			IDPath: "/dev/disk/by-id/wwn-0x5000c500a1b2c3d6",
+			Model:  "ST4000NM0035",
+			Serial: "ZC1A2B3C",
+			Size:   4000 * GigaBytes,
+			Tags:   []string{"hdd", "rotary"},
+		},
+		{
+			ID:   4,
+			Name: "md0",
+			Type: "virtual",
+			Size: 500 * GigaBytes,
+			Tags: []string{"ssd"},
+		},
+	}
+}
+
+func TestSelectStorageDevice(t *testing.T) {
+	testCases := []struct {
+		name     string
+		selector storageSelector
+		id       string
+		err      string
+	}{
+		{
+			name:     "by serial",
+			selector: storageSelector{Serial: "S4EVNF0M654321"},
+			id:       "2",
+		},
+		{
+			name:     "by wwn",
+			selector: storageSelector{WWN: "0x5000c500a1b2c3d6"},
+			id:       "3",
+		},
+		{
+			name:     "by model and size",
+			selector: storageSelector{Model: "Samsung SSD 870", MinSizeGigabytes: 600},
+			id:       "2",
+		},
+		{
+			name:     "by tags",
+			selector: storageSelector{Tags: []string{"hdd", "rotary"}},
+			id:       "3",
+		},
+		{
+			name:     "virtual devices are ignored",
+			selector: storageSelector{Tags: []string{"ssd"}, MaxSizeGigabytes: 500},
+			id:       "1",
+		},
+		{
+			name:     "partition in order of IDs",
+			selector: storageSelector{Serial: "S4EVNF0M123456", Partition: 1},
+			id:       "11",
+		},
+		{
+			name:     "no criteria",
+			selector: storageSelector{Partition: 1},
+			err:      "at least one of id_path, model, serial, wwn, tags, min_size_gigabytes or max_size_gigabytes must be set",
+		},
+		{
+			name:     "no match",
+			selector: storageSelector{Model: "ST4000NM0035", MaxSizeGigabytes: 1000},
+			err:      `no disk matches model "ST4000NM0035", at most 1000 GB`,
+		},
+		{
+			name:     "several matches",
+			selector: storageSelector{Tags: []string{"ssd"}},
+			err:      `2 disks match tags ["ssd"]: sda, sdb, the selector must match exactly one disk`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			id, err := selectStorageDevice(testStorageSelectorBlockDevices(), testCase.selector)
+			if testCase.err != "" {
+				assert.EqualError(t, err, testCase.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.id, id)
+		})
+	}
+}
+
+func TestSelectStorageDeviceMissingPartition(t *testing.T) {
+	_, err := selectStorageDevice(testStorageSelectorBlockDevices(), storageSelector{Serial: "S4EVNF0M654321", Partition: 1})
+
+	assert.True(t, errors.Is(err, errStoragePartitionNotFound), "expected errStoragePartitionNotFound, got %v", err)
+}
+
+func TestResolveStorageSelectors(t *testing.T) {
+	selector := func(serial string) map[string]any {
+		return map[string]any{
+			"id_path":            "",
+			"max_size_gigabytes": 0,
+			"min_size_gigabytes": 0,
+			"model":              "",
+			"serial":             serial,
+			"wwn":                "",
+		}
+	}
+
+	selected, err := resolveStorageSelectors(testStorageSelectorBlockDevices(), "block_device_selector", []any{selector("S4EVNF0M123456"), selector("ZC1A2B3C")})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"block_device_selector.0": "1", "block_device_selector.1": "3"}, selected)
+
+	_, err = resolveStorageSelectors(testStorageSelectorBlockDevices(), "block_device_selector", []any{selector("ZC1A2B3C"), selector("ZC1A2B3C")})
+	assert.EqualError(t, err, "block_device_selector.1: selects the same device as block_device_selector.0")
+}
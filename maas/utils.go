@@ -3,9 +3,13 @@ package maas
 import (
 	"encoding/base64"
 	"fmt"
+	"net"
 	"net/mail"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/canonical/gomaasclient/client"
@@ -87,6 +91,31 @@ func isElementIPAddress(i any, p cty.Path) diag.Diagnostics {
 	return diags
 }
 
+func isDuration(i any, p cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	attr := p[len(p)-1].(cty.GetAttrStep)
+
+	v, ok := i.(string)
+	if !ok {
+		return append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("expected type of %q to be string", attr.Name),
+			AttributePath: p,
+		})
+	}
+
+	if _, err := time.ParseDuration(v); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("expected %s to be a valid duration, got: %s", attr.Name, v),
+			AttributePath: p,
+		})
+	}
+
+	return diags
+}
+
 func isEmailAddress(i any, p cty.Path) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -250,6 +279,25 @@ func checkSemverConstraint(currentVersion, semverConstraint string) error {
 	return nil
 }
 
+// getAddressHost returns the host of an address given as a URL or as host[:port],
+// e.g. the power address of a machine.
+func getAddressHost(address string) string {
+	if net.ParseIP(address) != nil {
+		return address
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "//" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
+}
+
 // optionalStringPtr returns a pointer to the given string, or nil if the string is empty.
 func optionalStringPtr(value string) *string {
 	if value == "" {
@@ -272,6 +320,11 @@ func unsetIfNotFoundError(d *schema.ResourceData, err error) diag.Diagnostics {
 }
 
 // isNotFoundError checks if the given error is a 404 Not Found error.
+func isNotFoundError(err error) bool {
+	return strings.Contains(err.Error(), "404 Not Found")
+}
+
+// isMachineInPermittedState checks if the machine storage and network can be configured.
 func isMachineInPermittedState(machine *entity.Machine) bool {
 	switch machine.Status {
 	case
@@ -285,3 +338,109 @@ func isMachineInPermittedState(machine *entity.Machine) bool {
 		return false
 	}
 }
+
+// getMachineOwnerData returns the owner data of a machine as a string map.
+func getMachineOwnerData(machine *entity.Machine) map[string]string {
+	ownerData := map[string]string{}
+
+	if data, ok := machine.OwnerData.(map[string]any); ok {
+		for k, v := range data {
+			ownerData[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return ownerData
+}
+
+// setMachineOwnerData applies the difference between the old and new owner data
+// of a machine. Keys missing from the new owner data are removed.
+func setMachineOwnerData(client *client.Client, systemID string, oldData map[string]any, newData map[string]any) error {
+	params := map[string]string{}
+
+	// MAAS removes the keys set to an empty value
+	for k := range oldData {
+		if _, ok := newData[k]; !ok {
+			params[k] = ""
+		}
+	}
+
+	for k, v := range newData {
+		params[k] = v.(string)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	_, err := client.Machine.SetWorkloadAnnotations(systemID, params)
+
+	return err
+}
+
+// machineOwnerDataMatches checks if the machine owner data contains all the
+// key/value pairs of the given filter.
+func machineOwnerDataMatches(machine *entity.Machine, filter map[string]any) bool {
+	ownerData := getMachineOwnerData(machine)
+
+	for k, v := range filter {
+		if value, ok := ownerData[k]; !ok || value != v.(string) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// runConcurrently calls fn for each index in [0, n), running at most parallelism
+// calls at the same time. The returned slice holds the error of each call.
+func runConcurrently(parallelism int, n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range min(parallelism, n) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := range n {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
+
+// newValuesKnown returns whether all the values of the schema s, nested blocks
+// included, are known in the diff. The keys of s are prefixed with prefix.
+func newValuesKnown(d *schema.ResourceDiff, prefix string, s map[string]*schema.Schema) bool {
+	for k, v := range s {
+		key := prefix + k
+		if !d.NewValueKnown(key) {
+			return false
+		}
+
+		elem, ok := v.Elem.(*schema.Resource)
+		if !ok || v.Type != schema.TypeList {
+			continue
+		}
+
+		for i := range d.Get(key).([]any) {
+			if !newValuesKnown(d, fmt.Sprintf("%s.%d.", key, i), elem.Schema) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -3,6 +3,7 @@ package maas
 import (
 	"fmt"
 	"reflect"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -353,6 +354,27 @@ func TestListAsString(t *testing.T) {
 	}
 }
 
+func TestGetAddressHost(t *testing.T) {
+	testCases := []struct {
+		address string
+		host    string
+	}{
+		{address: "10.0.0.1", host: "10.0.0.1"},
+		{address: "10.0.0.1:8443", host: "10.0.0.1"},
+		{address: "chassis.example.com", host: "chassis.example.com"},
+		{address: "https://admin@chassis.example.com:8443/api", host: "chassis.example.com"},
+		{address: "qemu+ssh://ubuntu@10.0.0.10/system", host: "10.0.0.10"},
+		{address: "fd00::1", host: "fd00::1"},
+		{address: "[fd00::1]:5000", host: "fd00::1"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.address, func(t *testing.T) {
+			assert.Equal(t, testCase.host, getAddressHost(testCase.address))
+		})
+	}
+}
+
 func TestOptionalStringPtr(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -385,3 +407,62 @@ func TestOptionalStringPtr(t *testing.T) {
 		})
 	}
 }
+
+func TestRunConcurrently(t *testing.T) {
+	testCases := []struct {
+		name        string
+		parallelism int
+		n           int
+	}{
+		{
+			name:        "no items",
+			parallelism: 2,
+			n:           0,
+		},
+		{
+			name:        "more items than workers",
+			parallelism: 2,
+			n:           5,
+		},
+		{
+			name:        "more workers than items",
+			parallelism: 8,
+			n:           3,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var running, maxRunning atomic.Int32
+
+			errs := runConcurrently(testCase.parallelism, testCase.n, func(i int) error {
+				current := running.Add(1)
+				defer running.Add(-1)
+
+				for {
+					previous := maxRunning.Load()
+					if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+
+				if i%2 == 1 {
+					return fmt.Errorf("item %d", i)
+				}
+
+				return nil
+			})
+
+			assert.Len(t, errs, testCase.n)
+			assert.LessOrEqual(t, int(maxRunning.Load()), testCase.parallelism)
+
+			for i, err := range errs {
+				if i%2 == 1 {
+					assert.EqualError(t, err, fmt.Sprintf("item %d", i))
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+}